@@ -0,0 +1,263 @@
+// Package blame finds the commit that introduced each line of a file by
+// walking its history and following unchanged lines into the parents.
+package blame
+
+import (
+	"container/heap"
+	"fmt"
+
+	"github.com/ayushsarode/orb/internal/diff"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/worktree"
+)
+
+// Line is one line of the blamed file and where it came from
+type Line struct {
+	Commit    string // the commit that introduced the line
+	Path      string // the file's path in that commit
+	OrigLine  int    // line number in that commit's version, from 1
+	FinalLine int    // line number in the blamed version, from 1
+	Text      string // the line, including its newline if it has one
+
+	// Boundary is set when the commit has no parents, so the line may be
+	// older than the history goes back
+	Boundary bool
+}
+
+// Options narrow down what is blamed
+type Options struct {
+	// Start and End limit the blame to a range of lines, from 1 and
+	// inclusive; 0 means the start or end of the file
+	Start int
+	End   int
+
+	// FollowRenames keeps following lines into a parent where the file had
+	// another name
+	FollowRenames bool
+
+	// Diff is how a version's lines are matched up with its parent's
+	Diff diff.Options
+}
+
+// origin is a file as one commit has it
+type origin struct {
+	commit string
+	path   string
+}
+
+// pending is a line of the blamed file not attributed yet, with its line
+// index in the version of the suspect holding it
+type pending struct {
+	final int
+	line  int
+}
+
+// suspect is a version of the file that may have introduced some lines
+type suspect struct {
+	origin
+	commit  *objects.Commit
+	blob    string
+	lines   []string
+	pending []pending
+}
+
+// File blames the lines of path as it is in commit
+func File(commit, path string, opts Options) ([]Line, error) {
+	start, err := newSuspect(origin{commit, path})
+	if err != nil {
+		return nil, err
+	}
+	if start == nil {
+		return nil, fmt.Errorf("no such path '%s' in %s", path, commit)
+	}
+
+	first, last := 1, len(start.lines)
+	if opts.Start > 0 {
+		first = opts.Start
+	}
+	if opts.End > 0 && opts.End < last {
+		last = opts.End
+	}
+	// without -L an empty file simply has nothing to blame
+	ranged := opts.Start > 0 || opts.End > 0
+	if ranged && (first > len(start.lines) || first > last) {
+		return nil, fmt.Errorf("file %s has only %d lines", path, len(start.lines))
+	}
+	for i := first - 1; i < last; i++ {
+		start.pending = append(start.pending, pending{final: i, line: i})
+	}
+
+	result := make([]Line, len(start.lines))
+	queue := &suspectQueue{start}
+	queued := map[origin]*suspect{start.origin: start}
+
+	for queue.Len() > 0 {
+		s := heap.Pop(queue).(*suspect)
+		delete(queued, s.origin)
+
+		remaining, err := passToParents(s, queue, queued, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range remaining {
+			result[p.final] = Line{
+				Commit:    s.origin.commit,
+				Path:      s.path,
+				OrigLine:  p.line + 1,
+				FinalLine: p.final + 1,
+				Text:      start.lines[p.final],
+				Boundary:  len(s.commit.Parents) == 0,
+			}
+		}
+	}
+	return result[first-1 : last], nil
+}
+
+// newSuspect reads a file as a commit has it; nil means the commit has
+// no such file
+func newSuspect(o origin) (*suspect, error) {
+	commit, err := objects.ReadCommit(o.commit)
+	if err != nil {
+		return nil, err
+	}
+	entry, found, err := objects.LookupPath(commit.Tree, o.path)
+	if err != nil {
+		return nil, err
+	}
+	if !found || entry.IsTree() {
+		return nil, nil
+	}
+	content, err := diff.ReadBlob(entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &suspect{origin: o, commit: commit, blob: entry.Hash, lines: diff.SplitLines(string(content))}, nil
+}
+
+// passToParents hands every pending line the parents also have to them,
+// in parent order, and returns the lines none of them has: those the
+// suspect introduced
+func passToParents(s *suspect, queue *suspectQueue, queued map[origin]*suspect, opts Options) ([]pending, error) {
+	remaining := s.pending
+	for _, parent := range s.commit.Parents {
+		if len(remaining) == 0 {
+			break
+		}
+		if !objects.Exists(parent) {
+			// history ends here, e.g. after a shallow fetch
+			continue
+		}
+
+		p, err := newSuspect(origin{parent, s.path})
+		if err != nil {
+			return nil, err
+		}
+		if p == nil && opts.FollowRenames {
+			if p, err = renamedSuspect(s, parent); err != nil {
+				return nil, err
+			}
+		}
+		if p == nil {
+			continue
+		}
+
+		// lines the parent has unchanged came from further back
+		kept := make([]int, len(s.lines))
+		for i := range kept {
+			kept[i] = -1
+		}
+		if p.blob == s.blob {
+			for i := range kept {
+				kept[i] = i
+			}
+		} else {
+			i, j := 0, 0
+			for _, edit := range diff.Compare(p.lines, s.lines, opts.Diff) {
+				switch edit.Op {
+				case diff.Equal:
+					kept[j] = i
+					i++
+					j++
+				case diff.Delete:
+					i++
+				case diff.Insert:
+					j++
+				}
+			}
+		}
+
+		var passed, left []pending
+		for _, line := range remaining {
+			if at := kept[line.line]; at >= 0 {
+				passed = append(passed, pending{final: line.final, line: at})
+			} else {
+				left = append(left, line)
+			}
+		}
+		remaining = left
+		if len(passed) == 0 {
+			continue
+		}
+
+		// several children can lead to the same version of the file
+		if existing, ok := queued[p.origin]; ok {
+			existing.pending = append(existing.pending, passed...)
+			continue
+		}
+		p.pending = passed
+		queued[p.origin] = p
+		heap.Push(queue, p)
+	}
+	return remaining, nil
+}
+
+// renamedSuspect looks for the file under another name in a parent, the
+// way diff finds renames: a file the parent has and the suspect's commit
+// doesn't, with the same or similar enough content
+func renamedSuspect(s *suspect, parent string) (*suspect, error) {
+	parentCommit, err := objects.ReadCommit(parent)
+	if err != nil {
+		return nil, err
+	}
+	before, err := worktree.Files(parentCommit.Tree)
+	if err != nil {
+		return nil, err
+	}
+	after, err := worktree.Files(s.commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := diff.RenameOptions{Threshold: diff.DefaultRenameThreshold}
+	changes, _, err := diff.DetectRenames(diff.Files(before, after), opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		if change.Status == diff.Renamed && change.Path == s.path {
+			return newSuspect(origin{parent, change.OldPath})
+		}
+	}
+	return nil, nil
+}
+
+// suspectQueue hands out the newest commit first, so that every child of
+// a commit has passed its lines on before the commit itself is looked at
+type suspectQueue []*suspect
+
+func (q suspectQueue) Len() int { return len(q) }
+
+func (q suspectQueue) Less(i, j int) bool {
+	return q[i].commit.Committer.When.After(q[j].commit.Committer.When)
+}
+
+func (q suspectQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *suspectQueue) Push(x any) { *q = append(*q, x.(*suspect)) }
+
+func (q *suspectQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,539 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+// files keeping the state of a bisection
+const (
+	bisectStartFile    = ".orb/BISECT_START"        // where HEAD was when it started
+	bisectLogFile      = ".orb/BISECT_LOG"          // the commands so far, for log and replay
+	bisectExpectedFile = ".orb/BISECT_EXPECTED_REV" // the commit checked out for testing
+)
+
+// bisectRefs holds the marks: refs/bisect/bad, refs/bisect/good-<hash> and
+// refs/bisect/skip-<hash>
+const bisectRefs = "refs/bisect/"
+
+// how far a bisection has got after a mark
+const (
+	bisectWaiting = iota // good or bad commits still missing
+	bisectTesting        // a commit is checked out for testing
+	bisectFound          // the first bad commit is known
+	bisectStuck          // only skipped commits are left
+)
+
+func newBisectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bisect <command>",
+		Short: "Find the commit that introduced a bug by binary search",
+		Long: `Find the first bad commit between a known bad and known good commits.
+Start with "orb bisect start", mark a bad and a good commit, and orb
+checks out the commit halfway between them. Test it and mark it good or
+bad (or skip it if it can't be tested), and repeat until the first bad
+commit is found. "orb bisect run <cmd>" does the testing with a command.
+"orb bisect reset" goes back to where it started.`,
+	}
+
+	cmd.AddCommand(newBisectStartCommand())
+	cmd.AddCommand(newBisectMarkCommand("bad", "new", "Mark a commit (HEAD by default) as having the bug"))
+	cmd.AddCommand(newBisectMarkCommand("good", "old", "Mark commits (HEAD by default) as free of the bug"))
+	cmd.AddCommand(newBisectMarkCommand("skip", "", "Mark commits (HEAD by default) as impossible to test"))
+	cmd.AddCommand(newBisectResetCommand())
+	cmd.AddCommand(newBisectLogCommand())
+	cmd.AddCommand(newBisectReplayCommand())
+	cmd.AddCommand(newBisectRunCommand())
+
+	return cmd
+}
+
+func newBisectStartCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "start [<bad> [<good>...]]",
+		Short: "Start bisecting, optionally with a bad and good commits",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := bisectStart(); err != nil {
+				return err
+			}
+			for i, rev := range args {
+				term := "good"
+				if i == 0 {
+					term = "bad"
+				}
+				if err := bisectMark(term, rev); err != nil {
+					return err
+				}
+			}
+			_, err := bisectNext()
+			return err
+		},
+	}
+}
+
+func newBisectMarkCommand(term, alias, short string) *cobra.Command {
+	use := term + " [<rev>...]"
+	if term == "bad" {
+		use = term + " [<rev>]"
+	}
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireBisecting(); err != nil {
+				return err
+			}
+			if term == "bad" && len(args) > 1 {
+				return fmt.Errorf("'orb bisect bad' can take only one argument")
+			}
+			if len(args) == 0 {
+				args = []string{"HEAD"}
+			}
+			for _, rev := range args {
+				if err := bisectMark(term, rev); err != nil {
+					return err
+				}
+			}
+			_, err := bisectNext()
+			return err
+		},
+	}
+	if alias != "" {
+		cmd.Aliases = []string{alias}
+	}
+	return cmd
+}
+
+func newBisectResetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reset [<commit>]",
+		Short: "Stop bisecting and go back to where it started, or to <commit>",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if requireBisecting() != nil {
+				fmt.Println("We are not bisecting.")
+				return nil
+			}
+			target := ""
+			if len(args) > 0 {
+				target = args[0]
+			}
+			return bisectReset(target)
+		},
+	}
+}
+
+func newBisectLogCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "log",
+		Short: "Show what has been marked so far",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			content, err := os.ReadFile(bisectLogFile)
+			if err != nil {
+				return fmt.Errorf("we are not bisecting")
+			}
+			os.Stdout.Write(content)
+			return nil
+		},
+	}
+}
+
+func newBisectReplayCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "replay <logfile>",
+		Short: "Redo the marks saved from \"orb bisect log\"",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot read '%s' for replaying: %w", args[0], err)
+			}
+			defer file.Close()
+
+			// like git, a bisection under way is reset first, so the replay
+			// starts from where that one did
+			if requireBisecting() == nil {
+				if err := bisectReset(""); err != nil {
+					return err
+				}
+			}
+
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				fields := strings.Fields(scanner.Text())
+				if len(fields) < 3 || (fields[0] != "orb" && fields[0] != "git") || fields[1] != "bisect" {
+					continue
+				}
+				term, revs := fields[2], fields[3:]
+				switch term {
+				case "start":
+					err = bisectStart()
+					for i := 0; err == nil && i < len(revs); i++ {
+						if i == 0 {
+							err = bisectMark("bad", revs[i])
+						} else {
+							err = bisectMark("good", revs[i])
+						}
+					}
+				case "bad", "new", "good", "old", "skip":
+					if term == "new" {
+						term = "bad"
+					} else if term == "old" {
+						term = "good"
+					}
+					for _, rev := range revs {
+						if err = bisectMark(term, rev); err != nil {
+							break
+						}
+					}
+				default:
+					err = fmt.Errorf("'%s'?? what are you talking about?", term)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				return fmt.Errorf("reading '%s': %w", args[0], err)
+			}
+
+			if err := requireBisecting(); err != nil {
+				return err
+			}
+			_, err = bisectNext()
+			return err
+		},
+	}
+}
+
+func newBisectRunCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run <cmd> [<arg>...]",
+		Short: "Test each commit with a command until the first bad one is found",
+		Long: `Run a command on every commit bisect checks out and mark the commit by
+its exit status: 0 is good, 125 means the commit can't be tested and is
+skipped, and anything else from 1 to 127 is bad. An exit status of 128 or
+more stops the bisection.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireBisecting(); err != nil {
+				return err
+			}
+			command := strings.Join(args, " ")
+
+			for {
+				fmt.Printf("running '%s'\n", command)
+				// like git, extra arguments reach the command through "$@" so
+				// their quoting survives
+				shellArgs := []string{"-c", args[0]}
+				if len(args) > 1 {
+					shellArgs = append([]string{"-c", args[0] + ` "$@"`}, args...)
+				}
+				run := exec.Command("sh", shellArgs...)
+				run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+				code := 0
+				if err := run.Run(); err != nil {
+					exitErr, ok := err.(*exec.ExitError)
+					if !ok {
+						return fmt.Errorf("running '%s': %w", command, err)
+					}
+					code = exitErr.ExitCode()
+				}
+
+				term := "bad"
+				switch {
+				case code == 0:
+					term = "good"
+				case code == 125:
+					term = "skip"
+				case code < 0 || code >= 128:
+					return fmt.Errorf("bisect run failed: exit code %d from '%s' is < 0 or >= 128", code, command)
+				}
+
+				// the command must leave HEAD where bisect put it, or the
+				// verdict would go to the wrong commit
+				if expected := bisectExpected(); expected != "" {
+					if head, _ := refs.GetHead(); head != expected {
+						return fmt.Errorf("bisect run failed: '%s' moved HEAD away from %s", command, shortHash(expected))
+					}
+				}
+
+				if err := bisectMark(term, "HEAD"); err != nil {
+					return err
+				}
+				state, err := bisectNext()
+				if err != nil {
+					return err
+				}
+				switch state {
+				case bisectFound:
+					fmt.Println("bisect found first bad commit")
+					return nil
+				case bisectStuck:
+					return fmt.Errorf("bisect run cannot continue any more")
+				case bisectWaiting:
+					return fmt.Errorf("bisect run failed: a good and a bad commit are needed first")
+				}
+			}
+		},
+	}
+
+	// the command's own flags aren't for us
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
+
+// requireBisecting fails unless a bisection has been started
+func requireBisecting() error {
+	if _, err := os.Stat(bisectStartFile); err != nil {
+		return fmt.Errorf("you need to start by \"orb bisect start\"")
+	}
+	return nil
+}
+
+// bisectStart begins a new bisection from where HEAD is, forgetting the
+// marks of any earlier one. Restarting keeps the earlier start point, so
+// reset still goes back to where it all began rather than to a commit
+// bisect checked out.
+func bisectStart() error {
+	start := refs.GetCurrentBranch()
+	if start == "" {
+		head, err := refs.GetHead()
+		if err != nil || head == "" {
+			return fmt.Errorf("bad HEAD - a commit is needed to bisect")
+		}
+		start = head
+	}
+	if earlier, err := os.ReadFile(bisectStartFile); err == nil {
+		start = strings.TrimSpace(string(earlier))
+	}
+
+	if err := bisectClean(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(bisectStartFile, []byte(start+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", bisectStartFile, err)
+	}
+	return appendBisectLog("orb bisect start\n")
+}
+
+// bisectMark records a commit as bad, good or skipped
+func bisectMark(term, rev string) error {
+	hash, err := revision.Resolve(rev)
+	if err != nil {
+		return fmt.Errorf("bad rev input: %s", rev)
+	}
+	commit, err := revision.PeelToCommit(hash)
+	if err != nil {
+		return err
+	}
+
+	ref := bisectRefs + term + "-" + commit
+	if term == "bad" {
+		ref = bisectRefs + "bad"
+	}
+	tx := refs.NewTransaction()
+	tx.Update(ref, commit, "", "bisect: "+term)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("marking %s: %w", shortHash(commit), err)
+	}
+	if err := os.Remove(bisectExpectedFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", bisectExpectedFile, err)
+	}
+
+	return appendBisectLog(fmt.Sprintf("# %s: [%s] %s\norb bisect %s %s\n", term, commit, commitSubject(commit), term, commit))
+}
+
+// bisectMarks reads the marks made so far
+func bisectMarks() (bad string, good, skip []string, err error) {
+	names, err := refs.ListRefs(bisectRefs)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	for _, name := range names {
+		hash, err := refs.ReadRef(name)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		switch rest := strings.TrimPrefix(name, bisectRefs); {
+		case rest == "bad":
+			bad = hash
+		case strings.HasPrefix(rest, "good-"):
+			good = append(good, hash)
+		case strings.HasPrefix(rest, "skip-"):
+			skip = append(skip, hash)
+		}
+	}
+	return bad, good, skip, nil
+}
+
+// bisectNext works out what the marks so far leave to test and checks out
+// the next commit to try, or reports the first bad commit
+func bisectNext() (int, error) {
+	bad, good, skip, err := bisectMarks()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case bad == "" && len(good) == 0:
+		fmt.Println("status: waiting for both good and bad commits")
+		return bisectWaiting, nil
+	case bad == "":
+		fmt.Printf("status: waiting for bad commit, %d good commit%s known\n", len(good), pluralSuffix(len(good)))
+		return bisectWaiting, nil
+	case len(good) == 0:
+		fmt.Println("status: waiting for good commit(s), bad commit known")
+		return bisectWaiting, nil
+	}
+
+	for _, hash := range good {
+		ancestor, err := revision.IsAncestor(hash, bad)
+		if err != nil {
+			return 0, err
+		}
+		if !ancestor {
+			return 0, fmt.Errorf("the good commit %s is not an ancestor of the bad commit %s;\nmaybe you mistook good and bad revs?", shortHash(hash), shortHash(bad))
+		}
+	}
+
+	step, err := revision.Bisect(bad, good, skip)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case step.FirstBad != "":
+		commit, err := objects.ReadCommit(step.FirstBad)
+		if err != nil {
+			return 0, err
+		}
+		fmt.Printf("%s is the first bad commit\n", step.FirstBad)
+		lines := formatCommit(step.FirstBad, commit, commitFormat{name: "medium"}, nil)
+		diffLines, err := commitDiffLines(commit, nil, diffOptions{stat: true, lines: configDiffOptions(), renames: configRenames("diff")})
+		if err != nil {
+			return 0, err
+		}
+		if len(diffLines) > 0 {
+			lines = append(append(lines, ""), diffLines...)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return bisectFound, appendBisectLog(fmt.Sprintf("# first bad commit: [%s] %s\n", step.FirstBad, commit.Subject()))
+
+	case step.Next == "":
+		fmt.Println("There are only 'skip'ped commits left to test.")
+		fmt.Println("The first bad commit could be any of:")
+		for _, hash := range step.Skipped {
+			fmt.Println(hash)
+		}
+		fmt.Println("We cannot bisect more!")
+		return bisectStuck, nil
+	}
+
+	fmt.Printf("Bisecting: %d revision%s left to test after this (roughly %d step%s)\n",
+		step.Remaining, pluralSuffix(step.Remaining), step.Steps, pluralSuffix(step.Steps))
+
+	reason := fmt.Sprintf("checkout: moving from %s to %s", describeHead(), step.Next)
+	if err := moveWorkTree(step.Next, false); err != nil {
+		return 0, err
+	}
+	if err := refs.UpdateHead(step.Next, reason); err != nil {
+		return 0, fmt.Errorf("checking out %s: %w", shortHash(step.Next), err)
+	}
+	if err := os.WriteFile(bisectExpectedFile, []byte(step.Next+"\n"), 0644); err != nil {
+		return 0, fmt.Errorf("writing %s: %w", bisectExpectedFile, err)
+	}
+	fmt.Printf("[%s] %s\n", step.Next, commitSubject(step.Next))
+	return bisectTesting, nil
+}
+
+// bisectReset checks out where the bisection started, or target, and
+// ends the bisection
+func bisectReset(target string) error {
+	if target == "" {
+		start, err := os.ReadFile(bisectStartFile)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", bisectStartFile, err)
+		}
+		target = strings.TrimSpace(string(start))
+	}
+
+	var err error
+	if _, refErr := refs.ReadRef("refs/heads/" + target); refErr == nil {
+		err = switchToBranch(target, false)
+	} else {
+		err = detachHead(target, false)
+	}
+	if err != nil {
+		return fmt.Errorf("could not check out original HEAD '%s': %w", target, err)
+	}
+	return bisectClean()
+}
+
+// bisectExpected returns the commit bisect last checked out for testing,
+// or "" once it has been marked
+func bisectExpected() string {
+	content, err := os.ReadFile(bisectExpectedFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// bisectClean removes the marks and state files, leaving HEAD alone
+func bisectClean() error {
+	names, err := refs.ListRefs(bisectRefs)
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		tx := refs.NewTransaction()
+		for _, name := range names {
+			tx.Delete(name, "", "bisect: reset")
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("removing bisect marks: %w", err)
+		}
+	}
+
+	for _, file := range []string{bisectStartFile, bisectLogFile, bisectExpectedFile} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// appendBisectLog adds lines to the bisect log
+func appendBisectLog(lines string) error {
+	file, err := os.OpenFile(bisectLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", bisectLogFile, err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(lines); err != nil {
+		return fmt.Errorf("writing %s: %w", bisectLogFile, err)
+	}
+	return nil
+}
+
+// pluralSuffix is "s" unless n is one
+func pluralSuffix(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/blame"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+// blameDateLayout is how blame shows when a line was written
+const blameDateLayout = "2006-01-02 15:04:05 -0700"
+
+func newBlameCommand() *cobra.Command {
+	var (
+		lineRange     string
+		porcelain     bool
+		followRenames bool
+		lines         diffFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "blame [-L <start>,<end>] [--porcelain] [--follow-renames] [-w] [<rev>] [--] <file>",
+		Short: "Show which commit last changed each line of a file",
+		Long: `Show each line of a file as of <rev> (HEAD by default) with the commit
+that introduced it, its author and date. Lines are followed back through
+the parents for as long as they stay unchanged, so a line is blamed on the
+commit that wrote it rather than the last one to touch the file.
+
+-L limits the output to some lines: "10,20", "10,+5" (five lines from 10)
+or "10," (10 to the end). --follow-renames keeps following lines into
+commits where the file had another name. --porcelain prints a format
+meant for scripts, with the commit details on their own lines. -w lets a
+line that only changed its whitespace keep its older commit.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rev, path := "HEAD", ""
+			switch dash := cmd.ArgsLenAtDash(); {
+			case dash >= 0 && dash <= 1 && len(args) == dash+1:
+				if dash == 1 {
+					rev = args[0]
+				}
+				path = args[dash]
+			case dash < 0 && len(args) == 1:
+				path = args[0]
+			case dash < 0 && len(args) == 2:
+				rev, path = args[0], args[1]
+			default:
+				return fmt.Errorf("expected [<rev>] [--] <file>")
+			}
+			path = cleanPathspecs([]string{path})[0]
+
+			hash, err := revision.Resolve(rev)
+			if err != nil {
+				return fmt.Errorf("bad revision '%s': %w", rev, err)
+			}
+			commit, err := revision.PeelToCommit(hash)
+			if err != nil {
+				return err
+			}
+
+			opts := blame.Options{FollowRenames: followRenames}
+			if opts.Diff, err = lines.options(cmd); err != nil {
+				return err
+			}
+			if lineRange != "" {
+				if opts.Start, opts.End, err = parseLineRange(lineRange); err != nil {
+					return err
+				}
+			}
+
+			lines, err := blame.File(commit, path, opts)
+			if err != nil {
+				return err
+			}
+
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+
+			commits := make(map[string]*objects.Commit)
+			for _, line := range lines {
+				if _, ok := commits[line.Commit]; ok {
+					continue
+				}
+				if commits[line.Commit], err = objects.ReadCommit(line.Commit); err != nil {
+					return err
+				}
+			}
+
+			if porcelain {
+				writeBlamePorcelain(out, lines, commits)
+			} else {
+				writeBlame(out, lines, commits, path)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&lineRange, "lines", "L", "", "Only blame these lines: <start>,<end> or <start>,+<count>")
+	cmd.Flags().BoolVar(&porcelain, "porcelain", false, "Print a format meant for scripts")
+	cmd.Flags().BoolVar(&followRenames, "follow-renames", false, "Follow lines into commits where the file had another name")
+	cmd.Flags().StringVar(&lines.algorithm, "diff-algorithm", "", "Diff algorithm: myers, patience or histogram")
+	cmd.Flags().BoolVarP(&lines.ignoreAllSpace, "ignore-all-space", "w", false, "Ignore whitespace when following lines back")
+
+	return cmd
+}
+
+// parseLineRange reads "<start>,<end>", "<start>,+<count>", "<start>," or
+// ",<end>"; an end of 0 means the end of the file
+func parseLineRange(value string) (int, int, error) {
+	bad := fmt.Errorf("invalid line range '%s'", value)
+
+	startText, endText, _ := strings.Cut(value, ",")
+	start, end := 1, 0
+	var err error
+	if startText != "" {
+		if start, err = strconv.Atoi(startText); err != nil || start < 1 {
+			return 0, 0, bad
+		}
+	}
+	if count, ok := strings.CutPrefix(endText, "+"); ok {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return 0, 0, bad
+		}
+		end = start + n - 1
+	} else if endText != "" {
+		if end, err = strconv.Atoi(endText); err != nil || end < start {
+			return 0, 0, bad
+		}
+	}
+	return start, end, nil
+}
+
+// writeBlame prints the human-readable blame: the commit, the file's name
+// if it was different back then, the author, the date and the line
+func writeBlame(out *bufio.Writer, lines []blame.Line, commits map[string]*objects.Commit, path string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	showPath := false
+	authorWidth, pathWidth := 0, 0
+	for _, line := range lines {
+		if line.Path != path {
+			showPath = true
+		}
+		authorWidth = max(authorWidth, len(commits[line.Commit].Author.Name))
+		pathWidth = max(pathWidth, len(line.Path))
+	}
+	numberWidth := len(strconv.Itoa(lines[len(lines)-1].FinalLine))
+
+	for _, line := range lines {
+		id := line.Commit[:8]
+		if line.Boundary {
+			id = "^" + line.Commit[:7]
+		}
+		if showPath {
+			id += fmt.Sprintf(" %-*s", pathWidth, line.Path)
+		}
+		author := commits[line.Commit].Author
+		fmt.Fprintf(out, "%s (%-*s %s %*d) %s\n", id, authorWidth, author.Name,
+			author.When.Format(blameDateLayout), numberWidth, line.FinalLine, strings.TrimSuffix(line.Text, "\n"))
+	}
+}
+
+// writeBlamePorcelain prints blame for scripts, as git does: a header line
+// "<hash> <orig-line> <final-line> [<group-size>]" per line, the commit's
+// details the first time the commit shows up and the line after a tab
+func writeBlamePorcelain(out *bufio.Writer, lines []blame.Line, commits map[string]*objects.Commit) {
+	shown := make(map[string]bool)
+
+	for i := 0; i < len(lines); {
+		// a group is a run of lines that sit together in the commit too
+		end := i + 1
+		for end < len(lines) && lines[end].Commit == lines[i].Commit && lines[end].Path == lines[i].Path &&
+			lines[end].OrigLine == lines[end-1].OrigLine+1 {
+			end++
+		}
+
+		first := lines[i]
+		fmt.Fprintf(out, "%s %d %d %d\n", first.Commit, first.OrigLine, first.FinalLine, end-i)
+		if !shown[first.Commit] {
+			shown[first.Commit] = true
+			commit := commits[first.Commit]
+			for _, person := range []struct {
+				role string
+				sig  objects.Signature
+			}{{"author", commit.Author}, {"committer", commit.Committer}} {
+				fmt.Fprintf(out, "%s %s\n", person.role, person.sig.Name)
+				fmt.Fprintf(out, "%s-mail <%s>\n", person.role, person.sig.Email)
+				fmt.Fprintf(out, "%s-time %d\n", person.role, person.sig.When.Unix())
+				fmt.Fprintf(out, "%s-tz %s\n", person.role, person.sig.When.Format("-0700"))
+			}
+			fmt.Fprintf(out, "summary %s\n", commit.Subject())
+			if first.Boundary {
+				fmt.Fprintln(out, "boundary")
+			}
+		}
+		fmt.Fprintf(out, "filename %s\n", first.Path)
+
+		for j := i; j < end; j++ {
+			if j > i {
+				fmt.Fprintf(out, "%s %d %d\n", lines[j].Commit, lines[j].OrigLine, lines[j].FinalLine)
+			}
+			fmt.Fprintf(out, "\t%s\n", strings.TrimSuffix(lines[j].Text, "\n"))
+		}
+		i = end
+	}
+}
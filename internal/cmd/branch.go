@@ -2,79 +2,439 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
+	"github.com/ayushsarode/orb/internal/config"
+	"github.com/ayushsarode/orb/internal/objects"
 	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
 	"github.com/spf13/cobra"
 )
 
 func newBranchCommand() *cobra.Command {
+	var (
+		deleteBranch  bool
+		forceDelete   bool
+		moveBranch    bool
+		forceMove     bool
+		verbose       int
+		setUpstream   string
+		unsetUpstream bool
+		listRemotes   bool
+		listAll       bool
+	)
+
 	cmd := &cobra.Command{
-		Use:   "branch [branchname]",
+		Use:   "branch [branchname] [start-point]",
 		Short: "List, create, or delete branches",
+		Long: `With no arguments, list branches. With a name, create a branch at
+HEAD or at the given start point (any revision).
+
+  orb branch -d <name>                 delete a merged branch (-D to force)
+  orb branch -m [<old>] <new>          rename a branch (-M to overwrite)
+  orb branch -u <upstream> [<name>]    set the upstream of a branch
+  orb branch -v / -vv                  show tip commits and upstream status
+  orb branch -r / -a                   list remote-tracking / all branches`,
+		Args: cobra.MaximumNArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case deleteBranch || forceDelete:
+				return deleteBranches(args, forceDelete)
+			case moveBranch || forceMove:
+				return renameBranch(args, forceMove)
+			case setUpstream != "":
+				return setBranchUpstream(args, setUpstream)
+			case unsetUpstream:
+				return unsetBranchUpstream(args)
+			}
 
 			// if its just "orb branch" then return list of branches
 			if len(args) == 0 {
-				return listBranches()
+				return listBranches(!listRemotes || listAll, listRemotes || listAll, verbose)
 			}
 
-			// create a new branch
-			branchName := args[0]
+			return createBranch(args)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&deleteBranch, "delete", "d", false, "Delete a branch that is fully merged")
+	cmd.Flags().BoolVarP(&forceDelete, "force-delete", "D", false, "Delete a branch even if it is not merged")
+	cmd.Flags().BoolVarP(&moveBranch, "move", "m", false, "Rename a branch")
+	cmd.Flags().BoolVarP(&forceMove, "force-move", "M", false, "Rename a branch even if the new name exists")
+	cmd.Flags().CountVarP(&verbose, "verbose", "v", "Show tip commits; twice to also show upstream status")
+	cmd.Flags().StringVarP(&setUpstream, "set-upstream-to", "u", "", "Set the upstream of a branch")
+	cmd.Flags().BoolVar(&unsetUpstream, "unset-upstream", false, "Remove the upstream of a branch")
+	cmd.Flags().BoolVarP(&listRemotes, "remotes", "r", false, "List remote-tracking branches")
+	cmd.Flags().BoolVarP(&listAll, "all", "a", false, "List both local and remote-tracking branches")
+
+	return cmd
+}
+
+// createBranch creates a branch from HEAD or from the given start point
+func createBranch(args []string) error {
+	branchName := args[0]
+	if err := refs.CheckBranchName(branchName); err != nil {
+		return err
+	}
+
+	startPoint := "HEAD"
+	if len(args) > 1 {
+		startPoint = args[1]
+	}
+
+	// get the commit the branch should start at
+	start, err := revision.Resolve(startPoint)
+	if err != nil {
+		return fmt.Errorf("not a valid start point '%s': %w", startPoint, err)
+	}
+
+	// create the branch (a ref pointing to the start commit)
+	tx := refs.NewTransaction()
+	tx.Create("refs/heads/"+branchName, start, "branch: Created from "+startPoint)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("creating branch: %w", err)
+	}
+
+	fmt.Printf("Created branch '%s'\n", branchName)
+	return nil
+}
+
+// deleteBranches removes branches, refusing unmerged ones unless forced
+func deleteBranches(names []string, force bool) error {
+	if len(names) == 0 {
+		return fmt.Errorf("branch name required")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	currentBranch := refs.GetCurrentBranch()
+	head, _ := refs.GetHead()
+
+	for _, name := range names {
+		if name == currentBranch {
+			return fmt.Errorf("cannot delete branch '%s' checked out", name)
+		}
+
+		hash, err := refs.ReadRef("refs/heads/" + name)
+		if err != nil {
+			return fmt.Errorf("branch '%s' not found", name)
+		}
+
+		if !force {
+			// merged means reachable from the upstream, or from HEAD if there is none
+			mergedInto := head
+			if _, upstreamRef, ok := branchUpstream(cfg, name); ok {
+				if upstreamHash, err := refs.ReadRef(upstreamRef); err == nil {
+					mergedInto = upstreamHash
+				}
+			}
 
-			// get current HEAD commit
-			head, err := refs.GetHead()
-			if err != nil {
-				return fmt.Errorf("getting HEAD: %w", err)
+			merged := false
+			if mergedInto != "" {
+				merged, err = revision.IsAncestor(hash, mergedInto)
+				if err != nil {
+					return fmt.Errorf("checking whether '%s' is merged: %w", name, err)
+				}
+			}
+			if !merged {
+				return fmt.Errorf("the branch '%s' is not fully merged.\nIf you are sure you want to delete it, run 'orb branch -D %s'", name, name)
 			}
+		}
+
+		tx := refs.NewTransaction()
+		tx.Delete("refs/heads/"+name, hash, "branch: deleted")
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("deleting branch '%s': %w", name, err)
+		}
 
-			// create the branch (a ref pointing to the current commit)
-			if err := refs.UpdateRef("refs/heads/"+branchName, head); err != nil {
-				return fmt.Errorf("creating branch: %w", err)
+		// saved right away, so a later branch that can't go doesn't leave
+		// this one's config behind
+		if cfg.RemoveSection("branch." + name) {
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("saving config: %w", err)
 			}
+		}
+		fmt.Printf("Deleted branch %s (was %s).\n", name, shortHash(hash))
+	}
+	return nil
+}
 
-			fmt.Printf("Created branch '%s'\n", branchName)
-			return nil
-		},
+// renameBranch renames a branch (the current one if only the new name is given),
+// moving its reflog and config with it
+func renameBranch(args []string, force bool) error {
+	var oldName, newName string
+	switch len(args) {
+	case 1:
+		oldName, newName = refs.GetCurrentBranch(), args[0]
+		if oldName == "" {
+			return fmt.Errorf("not on a branch; name the branch to rename")
+		}
+	case 2:
+		oldName, newName = args[0], args[1]
+	default:
+		return fmt.Errorf("branch name required")
 	}
 
-	return cmd
+	if err := refs.CheckBranchName(newName); err != nil {
+		return err
+	}
+	if _, err := refs.ReadRef("refs/heads/" + oldName); err != nil {
+		return fmt.Errorf("branch '%s' not found", oldName)
+	}
+	if _, err := refs.ReadRef("refs/heads/" + newName); err == nil && !force {
+		return fmt.Errorf("a branch named '%s' already exists", newName)
+	}
+
+	oldRef, newRef := "refs/heads/"+oldName, "refs/heads/"+newName
+	reason := fmt.Sprintf("Branch: renamed %s to %s", oldRef, newRef)
+	if err := refs.RenameRef(oldRef, newRef, force, reason); err != nil {
+		return fmt.Errorf("renaming branch: %w", err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	cfg.RemoveSection("branch." + newName)
+	cfg.RenameSection("branch."+oldName, "branch."+newName)
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+
+	fmt.Printf("Renamed branch '%s' to '%s'\n", oldName, newName)
+	return nil
 }
 
-func listBranches() error {
+// setBranchUpstream records which branch another one tracks
+func setBranchUpstream(args []string, upstream string) error {
+	branch, err := branchArgument(args)
+	if err != nil {
+		return err
+	}
+
+	// a remote-tracking branch like origin/main, or a local branch
+	var remote, merge string
+	if _, err := refs.ReadRef("refs/remotes/" + upstream); err == nil {
+		name, branchPart, ok := strings.Cut(upstream, "/")
+		if !ok {
+			return fmt.Errorf("invalid upstream '%s'", upstream)
+		}
+		remote, merge = name, "refs/heads/"+branchPart
+	} else if _, err := refs.ReadRef("refs/heads/" + upstream); err == nil {
+		remote, merge = ".", "refs/heads/"+upstream
+	} else {
+		return fmt.Errorf("the requested upstream branch '%s' does not exist", upstream)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	cfg.Set("branch."+branch+".remote", remote)
+	cfg.Set("branch."+branch+".merge", merge)
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+
+	fmt.Printf("branch '%s' set up to track '%s'.\n", branch, upstream)
+	return nil
+}
+
+// unsetBranchUpstream forgets a branch's upstream
+func unsetBranchUpstream(args []string) error {
+	branch, err := branchArgument(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if _, _, ok := branchUpstream(cfg, branch); !ok {
+		return fmt.Errorf("branch '%s' has no upstream information", branch)
+	}
+
+	cfg.Unset("branch." + branch + ".remote")
+	cfg.Unset("branch." + branch + ".merge")
+	return cfg.Save()
+}
+
+// branchArgument returns the branch named in args, or the current branch
+func branchArgument(args []string) (string, error) {
+	if len(args) > 0 {
+		if _, err := refs.ReadRef("refs/heads/" + args[0]); err != nil {
+			return "", fmt.Errorf("branch '%s' not found", args[0])
+		}
+		return args[0], nil
+	}
+	branch := refs.GetCurrentBranch()
+	if branch == "" {
+		return "", fmt.Errorf("HEAD is detached; name the branch")
+	}
+	return branch, nil
+}
+
+// branchUpstream looks up the upstream of a branch, returning its short
+// name (e.g. "origin/main") and the ref that holds its value
+func branchUpstream(cfg *config.Config, branch string) (string, string, bool) {
+	remote := cfg.Get("branch." + branch + ".remote")
+	merge := cfg.Get("branch." + branch + ".merge")
+	if remote == "" || merge == "" {
+		return "", "", false
+	}
+
+	mergeBranch := strings.TrimPrefix(merge, "refs/heads/")
+	if remote == "." {
+		return mergeBranch, merge, true
+	}
+	short := remote + "/" + mergeBranch
+	return short, "refs/remotes/" + short, true
+}
+
+// branchListing is one line of "orb branch" output
+type branchListing struct {
+	display string
+	ref     string
+	local   string // branch name for local branches, used for upstream lookups
+	current bool
+}
+
+func listBranches(showLocal, showRemote bool, verbose int) error {
 	// get current branch
-	currentBranch := ""
-	headContent, err := os.ReadFile(".orb/HEAD")
-	if err == nil {
-		head := strings.TrimSpace(string(headContent))
-		if strings.HasPrefix(head, "ref: refs/heads/") {
-			currentBranch = strings.TrimPrefix(head, "ref: refs/heads/")
+	currentBranch := refs.GetCurrentBranch()
+
+	var listings []branchListing
+
+	if showLocal {
+		names, err := refs.ListRefs("refs/heads/")
+		if err != nil {
+			return fmt.Errorf("reading branches: %w", err)
+		}
+		for _, ref := range names {
+			name := strings.TrimPrefix(ref, "refs/heads/")
+			listings = append(listings, branchListing{display: name, ref: ref, local: name, current: name == currentBranch})
 		}
 	}
 
-	// read branch directory
-	branches, err := os.ReadDir(".orb/refs/heads")
+	if showRemote {
+		names, err := refs.ListRefs("refs/remotes/")
+		if err != nil {
+			return fmt.Errorf("reading remote-tracking branches: %w", err)
+		}
+		for _, ref := range names {
+			display := strings.TrimPrefix(ref, "refs/remotes/")
+			if showLocal {
+				display = "remotes/" + display
+			}
+			// e.g. "origin/HEAD -> origin/main"
+			if target, err := refs.ReadSymbolicRef(ref); err == nil {
+				display += " -> " + refs.ShortenRef(target)
+			}
+			listings = append(listings, branchListing{display: display, ref: ref})
+		}
+	}
+
+	width := 0
+	for _, l := range listings {
+		width = max(width, len(l.display))
+	}
+
+	// every ref is read once up front instead of once per branch
+	decorations, err := refs.LoadDecorations()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		return err
+	}
+	color := useColor()
+
+	var cfg *config.Config
+	if verbose > 1 {
+		loaded, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
 		}
-		return fmt.Errorf("reading branches directory: %w", err)
+		cfg = loaded
 	}
 
 	// listing branches
-	for _, branch := range branches {
-		if branch.IsDir() {
-			continue 
+	for _, l := range listings {
+		marker := "  "
+		if l.current {
+			marker = "* "
+		}
+
+		// the current branch is green and remote-tracking ones red, like git
+		display := l.display
+		switch {
+		case l.current:
+			display = colorize(color, colorGreen, display)
+		case l.local == "":
+			display = colorize(color, colorRed, display)
+		}
+		if verbose > 0 {
+			display += strings.Repeat(" ", width-len(l.display))
+		}
+
+		if verbose == 0 {
+			fmt.Printf("%s%s\n", marker, display)
+			continue
+		}
+
+		hash, ok := decorations.Hash(l.ref)
+		if !ok {
+			fmt.Printf("%s%s (unreadable ref)\n", marker, display)
+			continue
 		}
 
-		branchName := branch.Name()
-		if branchName == currentBranch {
-			fmt.Printf("* %s\n", branchName)
-		} else {
-			fmt.Printf("  %s\n", branchName)
+		tracking := ""
+		if cfg != nil && l.local != "" {
+			tracking = upstreamStatus(cfg, l.local, hash)
 		}
+
+		fmt.Printf("%s%s %s %s%s\n", marker, display, shortHash(hash), tracking, commitSubject(hash))
 	}
 
 	return nil
 }
+
+// upstreamStatus formats "[origin/main: ahead 1, behind 2] " for branch -vv
+func upstreamStatus(cfg *config.Config, branch, hash string) string {
+	short, ref, ok := branchUpstream(cfg, branch)
+	if !ok {
+		return ""
+	}
+
+	upstreamHash, err := refs.ReadRef(ref)
+	if err != nil {
+		return fmt.Sprintf("[%s: gone] ", short)
+	}
+
+	ahead, behind, err := revision.AheadBehind(hash, upstreamHash)
+	if err != nil {
+		return fmt.Sprintf("[%s] ", short)
+	}
+
+	var counts []string
+	if ahead > 0 {
+		counts = append(counts, fmt.Sprintf("ahead %d", ahead))
+	}
+	if behind > 0 {
+		counts = append(counts, fmt.Sprintf("behind %d", behind))
+	}
+	if len(counts) == 0 {
+		return fmt.Sprintf("[%s] ", short)
+	}
+	return fmt.Sprintf("[%s: %s] ", short, strings.Join(counts, ", "))
+}
+
+// commitSubject returns the first line of a commit's message
+func commitSubject(hash string) string {
+	commit, err := objects.ReadCommit(hash)
+	if err != nil {
+		return ""
+	}
+	return commit.Subject()
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+func newCatFileCommand() *cobra.Command {
+	var (
+		showType   bool
+		showSize   bool
+		pretty     bool
+		exists     bool
+		batch      bool
+		batchCheck bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "cat-file (-t | -s | -p | -e | <type>) <object>",
+		Short: "Show the type, size or content of objects",
+		Long: `Show what is stored for an object: its type with -t, its size with -s or
+its content with -p. "cat-file <type> <object>" prints the content only if
+the object has that type. -e only checks the object exists, through the
+exit status.
+
+--batch and --batch-check read one object name per line from stdin and
+print "<hash> <type> <size>" for each, followed by the content with --batch.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if batch || batchCheck {
+				if len(args) > 0 {
+					return fmt.Errorf("--batch takes its objects from stdin")
+				}
+				return catFileBatch(os.Stdin, os.Stdout, batch)
+			}
+
+			modes := 0
+			for _, set := range []bool{showType, showSize, pretty, exists} {
+				if set {
+					modes++
+				}
+			}
+			switch {
+			case modes > 1:
+				return fmt.Errorf("only one of -t, -s, -p and -e can be used")
+			case modes == 1 && len(args) != 1:
+				return fmt.Errorf("expected exactly one object")
+			case modes == 0 && len(args) != 2:
+				return fmt.Errorf("expected <type> <object>, or one of -t, -s, -p and -e")
+			}
+
+			spec := args[len(args)-1]
+			hash, err := revision.ResolveObject(spec)
+			if err == nil && !objects.Exists(hash) {
+				err = fmt.Errorf("object %s not found", hash)
+			}
+			if err != nil {
+				if exists {
+					os.Exit(1)
+				}
+				return fmt.Errorf("not a valid object name %s", spec)
+			}
+			if exists {
+				return nil
+			}
+
+			objType, content, err := objects.ReadObject(hash)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case showType:
+				fmt.Println(objType)
+			case showSize:
+				fmt.Println(len(content))
+			case pretty:
+				os.Stdout.Write(content)
+			default:
+				// "cat-file commit v1.0" peels the tag to get a commit
+				want := args[0]
+				if objType != want {
+					peeled, peeledType, err := objects.Peel(hash)
+					if err != nil || peeledType != want {
+						return fmt.Errorf("%s is a %s, not a %s", spec, objType, want)
+					}
+					if _, content, err = objects.ReadObject(peeled); err != nil {
+						return err
+					}
+				}
+				os.Stdout.Write(content)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&showType, "type", "t", false, "Show the object's type")
+	cmd.Flags().BoolVarP(&showSize, "size", "s", false, "Show the object's size")
+	cmd.Flags().BoolVarP(&pretty, "pretty", "p", false, "Print the object's content")
+	cmd.Flags().BoolVarP(&exists, "exists", "e", false, "Exit with zero status if the object exists")
+	cmd.Flags().BoolVar(&batch, "batch", false, "Print type, size and content of each object named on stdin")
+	cmd.Flags().BoolVar(&batchCheck, "batch-check", false, "Print type and size of each object named on stdin")
+
+	return cmd
+}
+
+// catFileBatch answers one object name per input line, printing
+// "<name> missing" for names that don't resolve
+func catFileBatch(in io.Reader, w io.Writer, withContent bool) error {
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		spec := strings.TrimSpace(scanner.Text())
+		if spec == "" {
+			continue
+		}
+
+		hash, err := revision.ResolveObject(spec)
+		if err != nil {
+			fmt.Fprintf(out, "%s missing\n", spec)
+			continue
+		}
+		objType, content, err := objects.ReadObject(hash)
+		if err != nil {
+			fmt.Fprintf(out, "%s missing\n", spec)
+			continue
+		}
+
+		fmt.Fprintf(out, "%s %s %d\n", hash, objType, len(content))
+		if withContent {
+			out.Write(content)
+			fmt.Fprintln(out)
+		}
+	}
+	return scanner.Err()
+}
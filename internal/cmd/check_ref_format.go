@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+func newCheckRefFormatCommand() *cobra.Command {
+	var opts refs.RefFormatOptions
+	var branch bool
+
+	cmd := &cobra.Command{
+		Use:   "check-ref-format <refname>",
+		Short: "Ensure that a reference name is well formed",
+		Long: `Check that a ref name follows the same rules as git check-ref-format.
+Exits with an error if the name is invalid. With --normalize the
+normalized name is printed, with --branch the branch name is printed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			if branch {
+				if err := refs.CheckBranchName(name); err != nil {
+					return err
+				}
+				fmt.Println(name)
+				return nil
+			}
+
+			if err := refs.CheckRefFormat(name, opts); err != nil {
+				return err
+			}
+
+			if opts.Normalize {
+				fmt.Println(refs.NormalizeRefName(name))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.AllowOneLevel, "allow-onelevel", false, "Allow names with a single component")
+	cmd.Flags().BoolVar(&opts.RefspecPattern, "refspec-pattern", false, "Allow a single '*' wildcard")
+	cmd.Flags().BoolVar(&opts.Normalize, "normalize", false, "Collapse slashes and print the normalized name")
+	cmd.Flags().BoolVar(&branch, "branch", false, "Check the name as a branch name")
+
+	return cmd
+}
@@ -2,82 +2,88 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
 	"github.com/spf13/cobra"
 )
 
 func newCheckoutCommand() *cobra.Command {
-	var createBranch bool
+	var (
+		createBranch bool
+		force        bool
+	)
 
 	cmd := &cobra.Command{
-		Use:   "checkout [branch/commit]",
+		Use:   "checkout [-b] <branch/commit> | checkout [<rev>] -- <path>...",
 		Short: "Switch branches or restore working tree files",
+		Long: `Switch to a branch, or detach HEAD at any other commit, updating the
+index and working tree to match.
+
+With paths after "--", HEAD doesn't move: the files are restored from the
+index, or from <rev> when one is given, in which case they are staged too.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// "checkout [<rev>] -- <paths>" restores files instead
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				if createBranch {
+					return fmt.Errorf("-b can't be used with paths")
+				}
+				paths := cleanPathspecs(args[dash:])
+				if len(paths) == 0 {
+					return fmt.Errorf("you must specify path(s) to restore")
+				}
+				switch dash {
+				case 0:
+					return restorePaths("", paths, false, true, true)
+				case 1:
+					return restorePaths(args[0], paths, true, true, true)
+				}
+				return fmt.Errorf("only one revision can be given before '--'")
+			}
+
 			if len(args) == 0 {
 				return fmt.Errorf("you must specify a branch name or commit hash")
 			}
+			if len(args) > 2 || (len(args) == 2 && !createBranch) {
+				return fmt.Errorf("too many arguments; put paths after '--'")
+			}
 
 			target := args[0]
 
-			 // try to get the reference first; if it succeeds, it's a valid branch or tag
-			_, err := refs.GetRef(target)
-			branchExists := err == nil
-
-			// If -b flag is specified, create a new branch
+			// If -b flag is specified, create a new branch at HEAD or the start point
 			if createBranch {
-				if branchExists {
-					return fmt.Errorf("branch '%s' already exists", target)
-				}
-
-				// Get current HEAD commit
-				head, err := refs.GetHead()
-				if err != nil {
-					return fmt.Errorf("getting HEAD: %w", err)
-				}
-
-				// create new branch + target is the name of branch added to refs/heads with current HEAD
-				if err := refs.UpdateRef("refs/heads/"+target, head); err != nil {
-					return fmt.Errorf("creating branch: %w", err)
+				start := "HEAD"
+				if len(args) == 2 {
+					start = args[1]
 				}
-
-				fmt.Printf("Created branch '%s'\n", target)
-				branchExists = true
+				return switchToNewBranch(target, start, force)
 			}
 
-			// If it's a branch, update HEAD to point to it
-			if branchExists {
-				if err := refs.UpdateHead(target); err != nil {
-					return fmt.Errorf("switching to branch: %w", err)
-				}
-				fmt.Printf("Switched to branch '%s'\n", target)
-				return nil
+			// a branch is checked out as itself; anything else that names
+			// a commit (hash, tag, HEAD@{1}, ...) detaches HEAD
+			if _, err := refs.ReadRef("refs/heads/" + target); err == nil {
+				return switchToBranch(target, force)
 			}
-
-			// If it's not a branch, check if it's a commit hash
-			if len(target) == 40 {
-				// Verify this is a valid commit
-				hashPath := filepath.Join(".orb/objects", target[:2], target[2:])
-				if _, err := os.Stat(hashPath); err != nil {
-					return fmt.Errorf("commit '%s' does not exist", target)
-				}
-
-				// Set HEAD to point directly to the commit (detached HEAD)
-				if err := refs.UpdateHead(target); err != nil {
-					return fmt.Errorf("checking out commit: %w", err)
-				}
-
-				fmt.Printf("Note: you are in 'detached HEAD' state at %s\n", target[:7])
-				return nil
+			if _, err := revision.Resolve(target); err != nil {
+				return fmt.Errorf("'%s' is not a branch or commit: %w", target, err)
 			}
-
-			return fmt.Errorf("'%s' is not a branch or commit hash", target)
+			return detachHead(target, force)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&createBranch, "create-branch", "b", false, "Create and checkout a new branch")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Throw away local changes that are in the way")
 
 	return cmd
 }
+
+// describeHead names where HEAD currently is: the branch, or the commit when detached
+func describeHead() string {
+	if branch := refs.GetCurrentBranch(); branch != "" {
+		return branch
+	}
+	if head, err := refs.GetHead(); err == nil {
+		return head
+	}
+	return "HEAD"
+}
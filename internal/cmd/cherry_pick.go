@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newCherryPickCommand() *cobra.Command {
+	return newSequencerCommand(actionPick,
+		"cherry-pick [-x] [-m <parent>] <commit>... | cherry-pick (--continue | --skip | --abort)",
+		"Apply the changes of existing commits",
+		`Apply the change each commit introduces on top of HEAD and record it as
+a new commit with the original message and author. "A..B" picks the
+commits of a range, oldest first. -x adds a line naming the original
+commit, which helps when backporting between release branches.
+
+The change is merged in three ways, so a commit that doesn't apply stops
+with conflicts. Resolve them, "orb add" the files and run
+"orb cherry-pick --continue"; --skip drops the commit instead, and --abort
+goes back to where the cherry-pick started.`)
+}
@@ -80,13 +80,31 @@ func newCloneCommand() *cobra.Command {
 				return fmt.Errorf("failed to fetch refs from remote: %w", err)
 			}
 
+			// Pick out the branches to track. The remote decides these names
+			// and hashes, so don't trust them blindly
+			heads := make(map[string]string)
+			for name, hash := range remoteRefs {
+				if !strings.HasPrefix(name, "refs/heads/") {
+					continue
+				}
+				if err := refs.CheckRefFormat(name, refs.RefFormatOptions{}); err != nil {
+					fmt.Printf("Warning: ignoring remote ref: %v\n", err)
+					continue
+				}
+				if !isObjectHash(hash) {
+					fmt.Printf("Warning: ignoring remote ref %s: bad object name '%s'\n", name, hash)
+					continue
+				}
+				heads[name] = hash
+			}
+
 			// Look for the main ref (main or master)
 			mainRef := "refs/heads/main"
-			mainCommit, ok := remoteRefs[mainRef]
+			mainCommit, ok := heads[mainRef]
 			if !ok {
 				// Try master branch as fallback
 				mainRef = "refs/heads/master"
-				mainCommit, ok = remoteRefs[mainRef]
+				mainCommit, ok = heads[mainRef]
 				if !ok {
 					_ = os.Chdir(originalWorkDir)
 					return fmt.Errorf("remote repository has no 'main' or 'master' branch")
@@ -100,7 +118,15 @@ func newCloneCommand() *cobra.Command {
 
 			// Fetch objects
 			fmt.Println("Fetching objects...")
+			// Every tracked branch needs its objects, not just the default one
 			wants := []string{mainCommit}
+			seen := map[string]bool{mainCommit: true}
+			for _, hash := range heads {
+				if !seen[hash] {
+					seen[hash] = true
+					wants = append(wants, hash)
+				}
+			}
 			haves := []string{} // We have no objects yet
 
 			packData, err := remote.FetchObjects(wants, haves)
@@ -121,16 +147,23 @@ func newCloneCommand() *cobra.Command {
 			// Update refs to point to the fetched commit
 			localBranchName := defaultBranch
 			localRef := fmt.Sprintf("refs/heads/%s", localBranchName)
+			reason := "clone: from " + url
 
-			if err := refs.UpdateRef(localRef, mainCommit); err != nil {
-				_ = os.Chdir(originalWorkDir)
-				return fmt.Errorf("failed to update ref '%s': %w", localRef, err)
+			// Record every remote branch, the local branch and HEAD in one
+			// transaction so a failure never leaves a half-cloned set of refs
+			tx := refs.NewTransaction()
+			for name, hash := range heads {
+				trackingRef := fmt.Sprintf("refs/remotes/%s/%s", remoteName, strings.TrimPrefix(name, "refs/heads/"))
+				tx.Create(trackingRef, hash, reason)
 			}
+			tx.Create(localRef, mainCommit, reason)
 
 			// Point HEAD to our default branch
-			if err := refs.UpdateHead(localRef); err != nil {
+			tx.SetSymbolic("HEAD", localRef, reason)
+
+			if err := tx.Commit(); err != nil {
 				_ = os.Chdir(originalWorkDir)
-				return fmt.Errorf("failed to update HEAD: %w", err)
+				return fmt.Errorf("failed to update refs: %w", err)
 			}
 
 			// Update configuration to remember the remote
@@ -140,10 +173,7 @@ func newCloneCommand() *cobra.Command {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			cfg.Remotes = append(cfg.Remotes, transport.RemoteConfig{
-				Name: remoteName,
-				URL:  url,
-			})
+			cfg.AddRemote(remoteName, url)
 
 			if err := config.SaveConfig(cfg); err != nil {
 				_ = os.Chdir(originalWorkDir)
@@ -182,3 +212,16 @@ func newCloneCommand() *cobra.Command {
 
 	return cmd
 }
+
+// isObjectHash reports whether s is a full lowercase object hash
+func isObjectHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/config"
+)
+
+// ANSI escapes for the colors git uses
+const (
+	colorReset      = "\x1b[m"
+	colorYellow     = "\x1b[33m"
+	colorGreen      = "\x1b[32m"
+	colorRed        = "\x1b[31m"
+	colorBoldCyan   = "\x1b[1;36m"
+	colorBoldGreen  = "\x1b[1;32m"
+	colorBoldRed    = "\x1b[1;31m"
+	colorBoldYellow = "\x1b[1;33m"
+)
+
+// useColor decides from color.ui whether to color output: "always" does,
+// "never" and "false" don't, and "auto", "true" or no setting color only
+// when writing to a terminal
+func useColor() bool {
+	value := "auto"
+	if cfg, err := config.LoadConfig(); err == nil {
+		value = strings.ToLower(cfg.GetString("color.ui", "auto"))
+	}
+
+	switch value {
+	case "always":
+		return true
+	case "never", "false", "no", "off", "0":
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether a file is a terminal rather than a pipe or file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps text in a color when coloring is enabled
+func colorize(enabled bool, color, text string) string {
+	if !enabled || text == "" {
+		return text
+	}
+	return color + text + colorReset
+}
@@ -3,35 +3,77 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
-	"time"
+	"regexp"
+	"sort"
+	"strings"
 
-	"github.com/ayushsarode/orb/internal/config"
+	"github.com/ayushsarode/orb/internal/ident"
 	"github.com/ayushsarode/orb/internal/index"
 	"github.com/ayushsarode/orb/internal/objects"
 	"github.com/ayushsarode/orb/internal/refs"
 	"github.com/spf13/cobra"
 )
 
+// commitEditMsgFile holds the message while the editor is open, like git's COMMIT_EDITMSG
+const commitEditMsgFile = ".orb/COMMIT_EDITMSG"
+
 func newCommitCommand() *cobra.Command {
 	var message string
+	var messageFile string
+	var author string
+	var date string
+	var amend bool
+	var all bool
+	var allowEmpty bool
+	var signoff bool
+	var trailers []string
 
 	cmd := &cobra.Command{
 		Use:   "commit",
 		Short: "Record changes to the repository",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if message == "" {
-				return fmt.Errorf("commit message cannot be empty")
+			if cmd.Flags().Changed("message") && messageFile != "" {
+				return fmt.Errorf("options '-m' and '-F' cannot be used together")
 			}
 
 			idx, err := index.LoadIndex()
 			if err != nil {
 				return fmt.Errorf("loading index: %w", err)
 			}
+			if idx.HasConflicts() {
+				return fmt.Errorf("committing is not possible because you have unmerged files:\n\t%s\nfix them up in the work tree, then use 'orb add/rm <file>' to mark the resolution",
+					strings.Join(idx.ConflictPaths(), "\n\t"))
+			}
+
+			// -a stages every tracked file that changed or disappeared
+			if all {
+				if err := stageTrackedChanges(idx); err != nil {
+					return err
+				}
+			}
+
+			// get the current HEAD commit (if any)
+			// if im on a branch, the current branch commit is my parent commit here
+			headHash := ""
+			if head, err := refs.GetHead(); err == nil {
+				headHash = head
+			}
 
-			if len(idx.Entries) == 0 {
-				return fmt.Errorf("nothing to commit")
+			// an amended commit replaces HEAD, so it takes over HEAD's parents
+			var parents []string
+			var amended *objects.Commit
+			if amend {
+				if headHash == "" {
+					return fmt.Errorf("you have nothing to amend")
+				}
+				if amended, err = objects.ReadCommit(headHash); err != nil {
+					return err
+				}
+				parents = amended.Parents
+			} else if headHash != "" {
+				parents = []string{headHash}
 			}
 
 			// Create a tree object from the index
@@ -41,138 +83,355 @@ func newCommitCommand() *cobra.Command {
 				return fmt.Errorf("writing tree object: %w", err)
 			}
 
-			// get the current HEAD commit (if any)
-			// if im on a branch, the current branch commit is my parent commit here
-			parentHash := ""
-			head, err := refs.GetHead()
-			if err == nil {
-				parentHash = head
+			// refuse to record a commit that changes nothing, unless asked to
+			if !allowEmpty {
+				empty, err := treeMatchesParent(treeHash, parents, len(idx.Entries) == 0)
+				if err != nil {
+					return err
+				}
+				if empty {
+					if amend {
+						return fmt.Errorf("you asked to amend the most recent commit, but doing so would make it empty; use --allow-empty")
+					}
+					return fmt.Errorf("nothing to commit, working tree clean")
+				}
 			}
 
-			// Build commit content
-			commitContent := buildCommitContent(treeHash, parentHash, message)
-			commitHash, err := objects.WriteObject(objects.CommitType, commitContent)
+			// author and committer are resolved separately, so either can be
+			// overridden through the environment without touching the other
+			authorSig, err := commitAuthor(amended, author, date)
 			if err != nil {
-				return fmt.Errorf("writing commit object: %w", err)
+				return err
+			}
+			committerSig, err := ident.Committer()
+			if err != nil {
+				return err
 			}
 
-			// Update the current branch (or HEAD if detached)
-			headContent, err := os.ReadFile(".orb/HEAD")
+			// add the requested trailers, Signed-off-by last like git does
+			if signoff {
+				trailers = append(trailers, fmt.Sprintf("Signed-off-by: %s <%s>", committerSig.Name, committerSig.Email))
+			}
+
+			message, err = commitMessage(cmd.Flags().Changed("message"), message, messageFile, amended, trailers)
 			if err != nil {
-				return fmt.Errorf("reading HEAD: %w", err)
+				return err
 			}
 
-			headRef := string(headContent)
-			if len(headRef) > 5 && headRef[:5] == "ref: " {
-				// HEAD points to a branch
-				branchRef := headRef[5 : len(headRef)-1] // Remove "ref: " and newline
-				if err := refs.UpdateRef(branchRef, commitHash); err != nil {
-					return fmt.Errorf("updating branch reference: %w", err)
+			// -a only touches the index once we know the commit goes ahead
+			if all {
+				if err := idx.Write(); err != nil {
+					return fmt.Errorf("writing index: %w", err)
 				}
-			} else {
-				// Detached HEAD
-				if err := refs.UpdateHead(commitHash); err != nil {
-					return fmt.Errorf("updating HEAD: %w", err)
+			}
+
+			commitHash, err := objects.WriteCommit(&objects.Commit{
+				Tree:      treeHash,
+				Parents:   parents,
+				Author:    authorSig,
+				Committer: committerSig,
+				Message:   message + "\n",
+			})
+			if err != nil {
+				return fmt.Errorf("writing commit object: %w", err)
+			}
+
+			// reflog reason, e.g. "commit: fix typo" or "commit (initial): first"
+			reason := "commit: " + firstLine(message)
+			if amend {
+				reason = "commit (amend): " + firstLine(message)
+			} else if headHash == "" {
+				reason = "commit (initial): " + firstLine(message)
+			}
+
+			// Update the current branch (or HEAD if detached), but only if
+			// it still points at the commit we built on
+			expected := headHash
+			if expected == "" {
+				expected = refs.ZeroHash
+			}
+
+			// the end of HEAD's symbolic chain: the branch (possibly one
+			// without commits yet), or HEAD itself when detached
+			branchRef, _, _ := refs.ResolveRef("HEAD")
+
+			tx := refs.NewTransaction()
+			tx.Update(branchRef, commitHash, expected, reason)
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("updating HEAD: %w", err)
+			}
+
+			// a conflict resolved by hand is committed now, so the stopped
+			// cherry-pick or revert only has the rest left to do
+			for _, name := range []string{"CHERRY_PICK_HEAD", "REVERT_HEAD"} {
+				if err := refs.DeletePseudoRef(name); err != nil {
+					return err
 				}
 			}
+			os.Remove(mergeMsgFile)
 
-			fmt.Printf("[%s] %s\n", commitHash[:7], message)
+			fmt.Printf("[%s] %s\n", commitHash[:7], firstLine(message))
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&message, "message", "m", "", "Commit message")
-	cmd.MarkFlagRequired("message")
+	cmd.Flags().StringVarP(&messageFile, "file", "F", "", "Take the commit message from a file (- for stdin)")
+	cmd.Flags().StringVar(&author, "author", "", "Override the commit author (\"Name <email>\")")
+	cmd.Flags().StringVar(&date, "date", "", "Override the author date")
+	cmd.Flags().BoolVar(&amend, "amend", false, "Replace the tip of the current branch with a new commit")
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Stage modified and deleted tracked files first")
+	cmd.Flags().BoolVar(&allowEmpty, "allow-empty", false, "Allow a commit that records no changes")
+	cmd.Flags().BoolVarP(&signoff, "signoff", "s", false, "Add a Signed-off-by trailer")
+	cmd.Flags().StringArrayVar(&trailers, "trailer", nil, "Add a trailer, e.g. \"Reviewed-by: Name <email>\"")
 
 	return cmd
 }
 
-// buildTreeContent creates the content for a tree object from the index
-func buildTreeContent(idx *index.Index) []byte {
+// stageTrackedChanges refreshes every index entry from the working tree,
+// dropping entries whose files were deleted
+func stageTrackedChanges(idx *index.Index) error {
+	for path, entry := range idx.Entries {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			idx.Remove(path)
+			continue
+		}
 
-	dirMap := make(map[string][]index.Entry)
+		hash, err := objects.WriteBlob(path)
+		if err != nil {
+			return fmt.Errorf("writing blob for %s: %w", path, err)
+		}
+		if hash == entry.ObjectHash {
+			continue
+		}
+		if err := idx.AddFile(path, hash); err != nil {
+			return fmt.Errorf("adding to index: %w", err)
+		}
+	}
+	return nil
+}
 
-	for _, entry := range idx.GetEntries() {
-		dir := filepath.Dir(entry.Path)
-		dirMap[dir] = append(dirMap[dir], entry)
+// treeMatchesParent reports whether a commit with this tree would record no
+// change: it has the first parent's tree, or it is a root commit with nothing in it
+func treeMatchesParent(treeHash string, parents []string, emptyIndex bool) (bool, error) {
+	if len(parents) == 0 {
+		return emptyIndex, nil
 	}
 
-	rootTree := buildDirectoryTree(dirMap, ".")
-	return rootTree
+	parent, err := objects.ReadCommit(parents[0])
+	if err != nil {
+		return false, err
+	}
+	return parent.Tree == treeHash, nil
+}
+
+// commitAuthor resolves the author identity, letting --author and --date
+// override whatever the environment and config say. An amended commit
+// keeps its original author.
+func commitAuthor(amended *objects.Commit, author, date string) (objects.Signature, error) {
+	var sig objects.Signature
+	if amended != nil {
+		sig = amended.Author
+	} else {
+		var err error
+		if sig, err = ident.Author(); err != nil {
+			return sig, err
+		}
+	}
 
+	if author != "" {
+		name, email, err := ident.ParseIdent(author)
+		if err != nil {
+			return sig, fmt.Errorf("invalid --author: %w", err)
+		}
+		sig.Name = name
+		sig.Email = email
+	}
+
+	if date != "" {
+		when, err := ident.ParseDate(date)
+		if err != nil {
+			return sig, fmt.Errorf("invalid --date: %w", err)
+		}
+		sig.When = when
+	}
+
+	return sig, nil
 }
 
-func buildDirectoryTree(dirMap map[string][]index.Entry, path string) []byte {
-	var result []byte
+// commitMessage works out the message from -m, -F or, failing both, the
+// editor (starting from the amended commit's message), then adds trailers
+func commitMessage(haveMessage bool, message, messageFile string, amended *objects.Commit, trailers []string) (string, error) {
+	useEditor := false
+
+	switch {
+	case haveMessage:
+	case messageFile == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("reading message from stdin: %w", err)
+		}
+		message = string(data)
+	case messageFile != "":
+		data, err := os.ReadFile(messageFile)
+		if err != nil {
+			return "", fmt.Errorf("could not read log file '%s': %w", messageFile, err)
+		}
+		message = string(data)
+	default:
+		useEditor = true
+		if amended != nil {
+			message = amended.Message
+		} else if data, err := os.ReadFile(mergeMsgFile); err == nil {
+			// a cherry-pick or revert that stopped left its message behind
+			message = string(data)
+		}
+	}
 
-	// Add entries for this directory
-	// Loop over every entry (file) in this folder (path)
-	for _, entry := range dirMap[path] {
-		if filepath.Dir(entry.Path) != path {
-			continue
+	message, err := addTrailers(cleanupMessage(message, false), trailers)
+	if err != nil {
+		return "", err
+	}
+
+	if useEditor {
+		template := message + "\n\n" +
+			"# Please enter the commit message for your changes. Lines starting\n" +
+			"# with '#' will be ignored, and an empty message aborts the commit.\n"
+		edited, err := editMessage(commitEditMsgFile, template)
+		if err != nil {
+			return "", err
 		}
+		message = cleanupMessage(edited, true)
+	}
 
-		// Format: "mode type hash\tname" (basename only, not full path)
-		name := filepath.Base(entry.Path)
-		line := fmt.Sprintf("100644 blob %s\t%s", entry.ObjectHash, name)
-		result = append(result, []byte(line)...)
-		result = append(result, '\n')
+	if message == "" {
+		return "", fmt.Errorf("aborting commit due to empty commit message")
+	}
+	return message, nil
+}
+
+// trailerLine matches a "Token: value" line in a trailer block
+var trailerLine = regexp.MustCompile(`^[A-Za-z0-9-]+: `)
+
+// addTrailers appends "Key: value" trailers to a message. They join an
+// existing trailer block at the end of the message, or start a new
+// paragraph; a trailer already present is not repeated.
+func addTrailers(message string, trailers []string) (string, error) {
+	if len(trailers) == 0 {
+		return message, nil
 	}
 
-	// Add subdirectories as tree objects
-	for dir := range dirMap {
-		if dir != path && filepath.Dir(dir) == path {
-			// Create a tree for this subdirectory
-			subTree := buildDirectoryTree(dirMap, dir)
-			subTreeHash, _ := objects.WriteObject(objects.TreeType, subTree)
+	lines := strings.Split(message, "\n")
+	blockStart := len(lines)
+	for blockStart > 0 && trailerLine.MatchString(lines[blockStart-1]) {
+		blockStart--
+	}
+	// a trailer block is a whole paragraph of trailers, not the subject line
+	hasBlock := blockStart < len(lines) && blockStart > 0 && lines[blockStart-1] == ""
 
-			// Add entry for this subdirectory
-			name := filepath.Base(dir)
-			line := fmt.Sprintf("040000 tree %s\t%s", subTreeHash, name)
-			result = append(result, []byte(line)...)
-			result = append(result, '\n')
+	existing := make(map[string]bool)
+	if hasBlock {
+		for _, line := range lines[blockStart:] {
+			existing[line] = true
 		}
 	}
 
-	return result
+	var added []string
+	for _, trailer := range trailers {
+		line, err := formatTrailer(trailer)
+		if err != nil {
+			return "", err
+		}
+		if existing[line] {
+			continue
+		}
+		existing[line] = true
+		added = append(added, line)
+	}
+	if len(added) == 0 {
+		return message, nil
+	}
+
+	if message != "" && !hasBlock {
+		message += "\n"
+	}
+	if message != "" {
+		message += "\n"
+	}
+	return message + strings.Join(added, "\n"), nil
 }
 
-func buildCommitContent(treeHash, parentHash, message string) []byte {
-	var content string
+// formatTrailer normalizes "key=value" and "key: value" to "key: value"
+func formatTrailer(trailer string) (string, error) {
+	sep := strings.IndexAny(trailer, ":=")
+	if sep <= 0 {
+		return "", fmt.Errorf("invalid trailer '%s': expected 'key: value' or 'key=value'", trailer)
+	}
 
-	content += fmt.Sprintf("tree %s\n", treeHash)
-	if parentHash != "" {
-		content += fmt.Sprintf("parent %s\n", parentHash)
+	key := strings.TrimSpace(trailer[:sep])
+	value := strings.TrimSpace(trailer[sep+1:])
+	if key == "" || strings.ContainsAny(key, " \t") {
+		return "", fmt.Errorf("invalid trailer key '%s'", key)
 	}
+	return key + ": " + value, nil
+}
 
-	// Load user configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		// Fallback to defaults if config can't be loaded
-		cfg = &config.Config{Values: make(map[string]string)}
+// buildTreeContent creates the content for a tree object from the index,
+// writing the trees of subdirectories along the way
+func buildTreeContent(idx *index.Index) []byte {
+	return buildDirectoryTree(idx.GetEntries(), "")
+}
+
+// buildDirectoryTree builds the tree for the directory prefix from the
+// sorted index entries below it. Entries are ordered like git orders
+// them, so the same index always gives the same tree hash.
+func buildDirectoryTree(entries []index.Entry, prefix string) []byte {
+	type treeLine struct {
+		sortKey string
+		line    string
 	}
+	var lines []treeLine
 
-	// Get user name and email from config, with defaults
-	authorName := cfg.GetString("user.name", "Unknown")
-	authorEmail := cfg.GetString("user.email", "unknown@example.com")
+	for i := 0; i < len(entries); {
+		rel := strings.TrimPrefix(entries[i].Path, prefix)
 
-	// Get local timezone
-	now := time.Now()
-	_, tzOffsetSeconds := now.Zone()
+		dir, _, isNested := strings.Cut(rel, "/")
+		if !isNested {
+			// Format: "mode type hash\tname" (basename only, not full path)
+			lines = append(lines, treeLine{rel, fmt.Sprintf("100644 blob %s\t%s", entries[i].ObjectHash, rel)})
+			i++
+			continue
+		}
 
-	// Format timezone as ±HHMM
-	tzHours := tzOffsetSeconds / 3600
-	tzMinutes := (tzOffsetSeconds % 3600) / 60
-	timezone := fmt.Sprintf("%+03d%02d", tzHours, tzMinutes)
+		// gather everything inside this subdirectory; the entries are
+		// sorted, so they sit next to each other
+		subPrefix := prefix + dir + "/"
+		end := i
+		for end < len(entries) && strings.HasPrefix(entries[end].Path, subPrefix) {
+			end++
+		}
 
-	timestamp := now.Unix()
+		subTree := buildDirectoryTree(entries[i:end], subPrefix)
+		subTreeHash, _ := objects.WriteObject(objects.TreeType, subTree)
+		lines = append(lines, treeLine{dir + "/", fmt.Sprintf("040000 tree %s\t%s", subTreeHash, dir)})
+		i = end
+	}
 
-	author := fmt.Sprintf("%s <%s> %d %s", authorName, authorEmail, timestamp, timezone)
-	committer := author // Use same info for committer
+	// git compares directory names as if they ended in "/"
+	sort.Slice(lines, func(i, j int) bool { return lines[i].sortKey < lines[j].sortKey })
 
-	content += fmt.Sprintf("author %s\n", author)
-	content += fmt.Sprintf("committer %s\n", committer)
-	content += fmt.Sprintf("\n%s\n", message)
+	var result []byte
+	for _, l := range lines {
+		result = append(result, []byte(l.line)...)
+		result = append(result, '\n')
+	}
+	return result
+}
 
-	return []byte(content)
+// firstLine returns the subject line of a commit message
+func firstLine(message string) string {
+	if i := strings.Index(message, "\n"); i >= 0 {
+		return message[:i]
+	}
+	return message
 }
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/ident"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+func newCommitTreeCommand() *cobra.Command {
+	var (
+		parents     []string
+		messages    []string
+		messageFile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "commit-tree <tree> [-p <parent>...] [-m <message>...] [-F <file>]",
+		Short: "Create a commit object from a tree",
+		Long: `Create a commit recording the given tree with the given parents and
+print its hash. No ref is moved; use update-ref for that. The message
+comes from -m (each one a paragraph), -F, or standard input. Author and
+committer are resolved like "orb commit" resolves them.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hash, err := revision.ResolveObject(args[0])
+			if err != nil {
+				return fmt.Errorf("not a valid object name %s", args[0])
+			}
+			tree, err := revision.PeelToTree(hash)
+			if err != nil {
+				return err
+			}
+
+			// a parent may only be given once, like git
+			var parentHashes []string
+			seen := make(map[string]bool)
+			for _, parent := range parents {
+				parentHash, err := revision.Resolve(parent)
+				if err != nil {
+					return fmt.Errorf("not a valid object name %s", parent)
+				}
+				if parentHash, err = revision.PeelToCommit(parentHash); err != nil {
+					return err
+				}
+				if seen[parentHash] {
+					fmt.Fprintf(os.Stderr, "error: duplicate parent %s ignored\n", parentHash)
+					continue
+				}
+				seen[parentHash] = true
+				parentHashes = append(parentHashes, parentHash)
+			}
+
+			var message string
+			switch {
+			case len(messages) > 0:
+				message = strings.Join(messages, "\n\n")
+			case messageFile != "" && messageFile != "-":
+				data, err := os.ReadFile(messageFile)
+				if err != nil {
+					return fmt.Errorf("could not read log file '%s': %w", messageFile, err)
+				}
+				message = string(data)
+			default:
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("reading message from stdin: %w", err)
+				}
+				message = string(data)
+			}
+			if message != "" && !strings.HasSuffix(message, "\n") {
+				message += "\n"
+			}
+
+			author, err := ident.Author()
+			if err != nil {
+				return err
+			}
+			committer, err := ident.Committer()
+			if err != nil {
+				return err
+			}
+
+			commitHash, err := objects.WriteCommit(&objects.Commit{
+				Tree:      tree,
+				Parents:   parentHashes,
+				Author:    author,
+				Committer: committer,
+				Message:   message,
+			})
+			if err != nil {
+				return fmt.Errorf("writing commit object: %w", err)
+			}
+			fmt.Println(commitHash)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&parents, "parent", "p", nil, "A parent of the new commit; repeat for merges")
+	cmd.Flags().StringArrayVarP(&messages, "message", "m", nil, "A paragraph of the commit message")
+	cmd.Flags().StringVarP(&messageFile, "file", "F", "", "Take the commit message from a file (- for stdin)")
+
+	return cmd
+}
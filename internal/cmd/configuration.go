@@ -1,47 +1,274 @@
 package cmd
 
 import (
-    "fmt"
-    "strings"
-    
-    "github.com/ayushsarode/orb/internal/config"
-    "github.com/spf13/cobra"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/config"
+	"github.com/spf13/cobra"
 )
 
 func newConfigCommand() *cobra.Command {
-    cmd := &cobra.Command{
-        Use:   "config [key] [value]",
-        Short: "Get and set repository or global options",
-        Args:  cobra.MinimumNArgs(1),
-        RunE: func(cmd *cobra.Command, args []string) error {
-            cfg, err := config.LoadConfig()
-            if err != nil {
-                return fmt.Errorf("loading config: %w", err)
-            }
-            
-            key := args[0]
-            
-            // Get configuration value
-            if len(args) == 1 {
-                value := cfg.Get(key)
-                if value == "" {
-                    return fmt.Errorf("no value set for %s", key)
-                }
-                fmt.Println(value)
-                return nil
-            }
-            
-            // Set configuration value
-            value := strings.Join(args[1:], " ")
-            cfg.Set(key, value)
-            
-            if err := cfg.Save(); err != nil {
-                return fmt.Errorf("saving config: %w", err)
-            }
-            
-            return nil
-        },
-    }
-    
-    return cmd
-}
\ No newline at end of file
+	var (
+		global, system, local bool
+		list, showOrigin      bool
+		unset, unsetAll       bool
+		getAll, getRegexp     bool
+		add                   bool
+		valueType             string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "config [key] [value]",
+		Short: "Get and set repository or global options",
+		Long: `Read and write configuration. Values are read from the system (/etc/orbconfig),
+global (~/.orbconfig or $XDG_CONFIG_HOME/orb/config) and repository (.orb/config)
+files, later ones winning. Values are written to the repository file unless
+--global or --system is given.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scope := ""
+			for name, chosen := range map[string]bool{config.ScopeGlobal: global, config.ScopeSystem: system, config.ScopeLocal: local} {
+				if !chosen {
+					continue
+				}
+				if scope != "" {
+					return fmt.Errorf("only one config file at a time")
+				}
+				scope = name
+			}
+
+			cfg, err := loadConfigScope(scope)
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+
+			switch {
+			case list:
+				for _, entry := range cfg.Entries() {
+					value, err := typedValue(entry, valueType)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("%s%s=%s\n", origin(entry, showOrigin), entry.Key, value)
+				}
+				return nil
+
+			case getRegexp:
+				if len(args) < 1 {
+					return fmt.Errorf("--get-regexp needs a pattern")
+				}
+				pattern, err := regexp.Compile(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid key pattern: %w", err)
+				}
+				found := false
+				for _, entry := range cfg.Entries() {
+					if !pattern.MatchString(entry.Key) {
+						continue
+					}
+					value, err := typedValue(entry, valueType)
+					if err != nil {
+						return err
+					}
+					found = true
+					fmt.Printf("%s%s %s\n", origin(entry, showOrigin), entry.Key, value)
+				}
+				if !found {
+					return fmt.Errorf("no values match %s", args[0])
+				}
+				return nil
+
+			case unset, unsetAll:
+				if len(args) < 1 {
+					return fmt.Errorf("key required")
+				}
+				if err := checkWritableScope(scope); err != nil {
+					return err
+				}
+				return unsetConfigValue(cfg, args, unsetAll)
+			}
+
+			if len(args) == 0 {
+				return fmt.Errorf("key required")
+			}
+			key := args[0]
+			if !strings.Contains(key, ".") {
+				return fmt.Errorf("key does not contain a section: %s", key)
+			}
+
+			// Get configuration value(s)
+			if len(args) == 1 || getAll {
+				canonical := config.CanonicalKey(key)
+				var matches []config.Entry
+				for _, entry := range cfg.Entries() {
+					if entry.Key == canonical {
+						matches = append(matches, entry)
+					}
+				}
+				if len(matches) == 0 {
+					return fmt.Errorf("no value set for %s", key)
+				}
+				if !getAll {
+					matches = matches[len(matches)-1:]
+				}
+				for _, entry := range matches {
+					value, err := typedValue(entry, valueType)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("%s%s\n", origin(entry, showOrigin), value)
+				}
+				return nil
+			}
+
+			// Set configuration value
+			if err := checkWritableScope(scope); err != nil {
+				return err
+			}
+
+			value := strings.Join(args[1:], " ")
+			if valueType != "path" {
+				// bools and ints are stored in their canonical form, paths as given
+				if value, err = canonicalValue(value, valueType); err != nil {
+					return err
+				}
+			}
+
+			if add {
+				cfg.Add(key, value)
+			} else {
+				if count := countTargetValues(cfg, key); count > 1 {
+					return fmt.Errorf("%s has multiple values; use --unset-all first", key)
+				}
+				cfg.Set(key, value)
+			}
+
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("saving config: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&global, "global", false, "Use the global config file")
+	cmd.Flags().BoolVar(&system, "system", false, "Use the system config file")
+	cmd.Flags().BoolVar(&local, "local", false, "Use the repository config file")
+	cmd.Flags().BoolVarP(&list, "list", "l", false, "List all variables")
+	cmd.Flags().BoolVar(&showOrigin, "show-origin", false, "Show the file each value comes from")
+	cmd.Flags().BoolVar(&unset, "unset", false, "Remove a variable")
+	cmd.Flags().BoolVar(&unsetAll, "unset-all", false, "Remove every value of a multi-valued variable")
+	cmd.Flags().BoolVar(&getAll, "get-all", false, "Print every value of a multi-valued variable")
+	cmd.Flags().BoolVar(&getRegexp, "get-regexp", false, "Print variables whose names match a regular expression")
+	cmd.Flags().BoolVar(&add, "add", false, "Add a value without replacing existing ones")
+	cmd.Flags().StringVar(&valueType, "type", "", "Interpret values as bool, int or path")
+
+	return cmd
+}
+
+// loadConfigScope loads every config file, or only one when a scope was chosen
+func loadConfigScope(scope string) (*config.Config, error) {
+	if scope == "" {
+		return config.LoadConfig()
+	}
+	if scope == config.ScopeLocal {
+		if err := checkWritableScope(scope); err != nil {
+			return nil, err
+		}
+	}
+	return config.LoadScope(scope)
+}
+
+// checkWritableScope makes sure repository config is only written inside a repository
+func checkWritableScope(scope string) error {
+	if scope != "" && scope != config.ScopeLocal {
+		return nil
+	}
+	if _, err := os.Stat(".orb"); err != nil {
+		return fmt.Errorf("not an orb repository; use --global or --system outside a repository")
+	}
+	return nil
+}
+
+// unsetConfigValue removes a variable; with an extra argument only values
+// matching that regular expression are removed
+func unsetConfigValue(cfg *config.Config, args []string, all bool) error {
+	key := args[0]
+
+	count := countTargetValues(cfg, key)
+	if count == 0 {
+		return fmt.Errorf("no value set for %s", key)
+	}
+
+	if len(args) > 1 {
+		pattern, err := regexp.Compile(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid value pattern: %w", err)
+		}
+		cfg.UnsetMatching(key, pattern)
+	} else {
+		if count > 1 && !all {
+			return fmt.Errorf("%s has multiple values; use --unset-all", key)
+		}
+		cfg.Unset(key)
+	}
+
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	return nil
+}
+
+// countTargetValues counts the values of key in the file that would be changed
+func countTargetValues(cfg *config.Config, key string) int {
+	canonical := config.CanonicalKey(key)
+	count := 0
+	for _, entry := range cfg.TargetEntries() {
+		if entry.Key == canonical {
+			count++
+		}
+	}
+	return count
+}
+
+// origin formats the --show-origin prefix
+func origin(entry config.Entry, show bool) string {
+	if !show {
+		return ""
+	}
+	return "file:" + entry.Origin + "\t"
+}
+
+// typedValue formats a value read from the config according to --type
+func typedValue(entry config.Entry, valueType string) (string, error) {
+	if valueType == "bool" && entry.NoValue {
+		return "true", nil
+	}
+	return canonicalValue(entry.Value, valueType)
+}
+
+// canonicalValue checks a value against --type and returns its canonical form
+func canonicalValue(value, valueType string) (string, error) {
+	switch valueType {
+	case "":
+		return value, nil
+	case "bool":
+		b, err := config.ParseBool(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	case "int":
+		n, err := config.ParseInt(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "path":
+		return config.ExpandPath(value), nil
+	}
+	return "", fmt.Errorf("unknown --type '%s': expected bool, int or path", valueType)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/refs"
+)
+
+// How ref names next to commits are shown
+const (
+	decorateShort = "short" // main, origin/main, tag: v1.0
+	decorateFull  = "full"  // refs/heads/main, refs/remotes/origin/main, tag: refs/tags/v1.0
+	decorateNo    = "no"
+)
+
+// parseDecorateStyle reads a --decorate or log.decorate value
+func parseDecorateStyle(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "short", "auto", "true", "yes", "on", "1":
+		return decorateShort, nil
+	case "full":
+		return decorateFull, nil
+	case "no", "false", "off", "0":
+		return decorateNo, nil
+	}
+	return "", fmt.Errorf("invalid --decorate option: %s", value)
+}
+
+// decorationNames renders the refs pointing at a commit the way git shows
+// them, e.g. "HEAD -> main", "tag: v1.0" and "origin/main"
+func decorationNames(decorations []refs.Decoration, style string, color bool) []string {
+	if style == decorateNo {
+		return nil
+	}
+
+	var names []string
+	for _, decoration := range decorations {
+		name := decoration.Ref
+		if style == decorateShort {
+			name = refs.ShortenRef(name)
+		}
+
+		switch decoration.Kind {
+		case refs.DecorationHead:
+			names = append(names, colorize(color, colorBoldCyan, "HEAD"))
+		case refs.DecorationTag:
+			names = append(names, colorize(color, colorBoldYellow, "tag: "+name))
+		case refs.DecorationRemote:
+			names = append(names, colorize(color, colorBoldRed, name))
+		default:
+			name = colorize(color, colorBoldGreen, name)
+			if decoration.Current {
+				name = colorize(color, colorBoldCyan, "HEAD -> ") + name
+			}
+			names = append(names, name)
+		}
+	}
+	return names
+}
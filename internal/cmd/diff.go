@@ -1 +1,641 @@
-package cmd
\ No newline at end of file
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/config"
+	"github.com/ayushsarode/orb/internal/diff"
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/ayushsarode/orb/internal/worktree"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// errCheckFailed is what writeChanges returns, once everything is written,
+// when --check found problems
+var errCheckFailed = errors.New("--check found problems")
+
+// diffOptions say how the commands that show changes show them
+type diffOptions struct {
+	stat       bool
+	numstat    bool
+	shortstat  bool
+	nameOnly   bool
+	nameStatus bool
+	check      bool
+	patch      bool
+
+	// statWidth is the width --stat fits into; 0 asks the terminal
+	statWidth int
+
+	// lines is how lines are compared and how the patch shows them
+	lines diff.Options
+
+	// renames is how renames and copies are found; nil finds none
+	renames *diff.RenameOptions
+}
+
+// outputFlags are the flags that pick what is shown of the changes
+type outputFlags struct {
+	stat       bool
+	numstat    bool
+	shortstat  bool
+	nameOnly   bool
+	nameStatus bool
+	check      bool
+	statWidth  int
+}
+
+// diffFlags are the flags that say how lines are compared and shown
+type diffFlags struct {
+	algorithm         string
+	ignoreAllSpace    bool
+	ignoreSpaceChange bool
+	ignoreBlankLines  bool
+	wordDiff          string
+	wordRegex         string
+}
+
+// renameFlags are the flags that turn rename detection on and off
+type renameFlags struct {
+	findRenames string
+	findCopies  string
+	noRenames   bool
+	limit       int
+}
+
+func newDiffCommand() *cobra.Command {
+	var (
+		cached  bool
+		output  outputFlags
+		patch   bool
+		lines   diffFlags
+		renames renameFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "diff [--cached] [<commit> [<commit>]] [-- <path>...]",
+		Short: "Show changes between commits, the index and the working tree",
+		Long: `Show changes as a patch. With no commits, the working tree is compared
+to the index: what "orb add" would stage. --cached compares the index to
+HEAD, or to <commit>: what "orb commit" would record. One commit compares
+the working tree to it, and two commits (or <a>..<b>) compare the two.
+<a>...<b> shows what <b> changed since it forked from <a>.
+
+Renamed and copied files are shown as such when diff.renames says so,
+which it does for renames by default. -M and -C take a similarity, e.g.
+-M90%, below which files aren't paired.
+
+--stat, --numstat, --shortstat, --name-only and --name-status summarize
+the changes instead of showing the patch; add -p to get both. --check
+reports whitespace errors and conflict markers in added lines and exits
+with status 2 when it finds any.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			revs, paths := args, []string(nil)
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				revs, paths = args[:dash], args[dash:]
+			} else {
+				// without "--", paths start at the first file that isn't a
+				// revision
+				for i, arg := range args {
+					if _, err := revision.Resolve(arg); err != nil && !strings.Contains(arg, "..") {
+						if _, statErr := os.Stat(arg); statErr == nil {
+							revs, paths = args[:i], args[i:]
+							break
+						}
+					}
+				}
+			}
+			paths = cleanPathspecs(paths)
+
+			opts := output.options()
+			opts.patch = patch || !opts.summarized()
+			var err error
+			if opts.lines, err = lines.options(cmd); err != nil {
+				return err
+			}
+			if opts.renames, err = renames.options(cmd, "diff"); err != nil {
+				return err
+			}
+
+			changes, err := diffChanges(revs, cached)
+			if err != nil {
+				return err
+			}
+
+			var shown []diff.Change
+			for _, change := range changes {
+				if matchesPathspec(change.Path, paths) {
+					shown = append(shown, change)
+				}
+			}
+
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+			err = writeChanges(out, shown, opts)
+			exitIfCheckFailed(out, errors.Is(err, errCheckFailed))
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&cached, "cached", false, "Compare the index to HEAD or a commit")
+	cmd.Flags().BoolVar(&cached, "staged", false, "Same as --cached")
+	addOutputFlags(cmd, &output)
+	cmd.Flags().BoolVarP(&patch, "patch", "p", false, "Show the patch, also with --stat and the like")
+	addDiffFlags(cmd, &lines)
+	addRenameFlags(cmd, &renames)
+	parseSimilarities(cmd)
+
+	return cmd
+}
+
+// diffChanges works out the two sides the diff arguments name and lists
+// the files that differ between them
+func diffChanges(revs []string, cached bool) ([]diff.Change, error) {
+	if len(revs) == 1 {
+		if left, right, ok := strings.Cut(revs[0], "..."); ok {
+			return forkChanges(left, right)
+		}
+		if left, right, ok := strings.Cut(revs[0], ".."); ok {
+			revs = []string{left, right}
+		}
+	}
+	for i, rev := range revs {
+		if rev == "" {
+			revs[i] = "HEAD"
+		}
+	}
+
+	switch {
+	case len(revs) == 2 && !cached:
+		oldTree, err := diffTree(revs[0])
+		if err != nil {
+			return nil, err
+		}
+		newTree, err := diffTree(revs[1])
+		if err != nil {
+			return nil, err
+		}
+		return diff.Trees(oldTree, newTree)
+	case len(revs) > 1:
+		return nil, fmt.Errorf("too many revisions: %s", strings.Join(revs, " "))
+	}
+
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return nil, fmt.Errorf("loading index: %w", err)
+	}
+	staged := worktree.IndexFiles(idx)
+
+	if cached {
+		tree := ""
+		if len(revs) == 1 || !headIsUnborn() {
+			rev := "HEAD"
+			if len(revs) == 1 {
+				rev = revs[0]
+			}
+			if tree, err = diffTree(rev); err != nil {
+				return nil, err
+			}
+		}
+		old, err := worktree.Files(tree)
+		if err != nil {
+			return nil, err
+		}
+		return diff.Files(old, staged), nil
+	}
+
+	working, err := workingFiles(staged)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		return diff.Files(staged, working), nil
+	}
+	tree, err := diffTree(revs[0])
+	if err != nil {
+		return nil, err
+	}
+	old, err := worktree.Files(tree)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Files(old, working), nil
+}
+
+// forkChanges lists what right changed since it forked from left
+func forkChanges(left, right string) ([]diff.Change, error) {
+	if left == "" {
+		left = "HEAD"
+	}
+	if right == "" {
+		right = "HEAD"
+	}
+	leftCommit, err := resolveCommitArg(left)
+	if err != nil {
+		return nil, err
+	}
+	rightCommit, err := resolveCommitArg(right)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := revision.MergeBases(leftCommit, rightCommit)
+	if err != nil {
+		return nil, err
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("%s and %s have no common ancestor", left, right)
+	}
+
+	oldTree, err := revision.PeelToTree(bases[0])
+	if err != nil {
+		return nil, err
+	}
+	newTree, err := revision.PeelToTree(rightCommit)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Trees(oldTree, newTree)
+}
+
+// diffTree resolves a diff argument to a tree
+func diffTree(rev string) (string, error) {
+	hash, err := revision.Resolve(rev)
+	if err != nil {
+		return "", fmt.Errorf("bad revision '%s': %w", rev, err)
+	}
+	return revision.PeelToTree(hash)
+}
+
+// workingFiles returns the working tree's version of the tracked files,
+// leaving out the ones that are gone. Files that changed are stored as
+// blobs so the diff can read them like any other version.
+func workingFiles(staged map[string]string) (map[string]string, error) {
+	files := make(map[string]string)
+	for path, stagedHash := range staged {
+		hash, err := worktree.HashFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if hash == "" {
+			continue
+		}
+		if hash != stagedHash && !objects.Exists(hash) {
+			if hash, err = objects.WriteBlob(path); err != nil {
+				return nil, fmt.Errorf("storing %s: %w", path, err)
+			}
+		}
+		files[path] = hash
+	}
+	return files, nil
+}
+
+// writeChanges finds renames among changes and writes them as the
+// options ask, in git's order: the names, the counts, the problems --check
+// finds, then the patch
+func writeChanges(w io.Writer, changes []diff.Change, opts diffOptions) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	changes, err := findRenames(changes, opts.renames)
+	if err != nil {
+		return err
+	}
+
+	if opts.nameOnly || opts.nameStatus {
+		diff.WriteNames(w, changes, opts.nameStatus)
+	}
+	if opts.stat || opts.numstat || opts.shortstat {
+		stats, err := diff.Stats(changes, opts.lines)
+		if err != nil {
+			return err
+		}
+		if opts.numstat {
+			diff.WriteNumstat(w, stats)
+		}
+		if opts.stat {
+			diff.WriteStat(w, stats, opts.statColumns())
+		} else if opts.shortstat {
+			fmt.Fprintln(w, diff.Summary(stats))
+		}
+	}
+
+	var problems []diff.Problem
+	if opts.check {
+		for _, change := range changes {
+			found, err := diff.Check(change, opts.lines)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, found...)
+		}
+		diff.WriteProblems(w, problems)
+	}
+
+	if opts.patch {
+		if opts.summarized() {
+			fmt.Fprintln(w)
+		}
+		for _, change := range changes {
+			if err := diff.WritePatch(w, change, opts.lines); err != nil {
+				return err
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errCheckFailed
+	}
+	return nil
+}
+
+// summarized reports whether anything besides the patch is asked for
+func (o diffOptions) summarized() bool {
+	return o.stat || o.numstat || o.shortstat || o.nameOnly || o.nameStatus || o.check
+}
+
+// statColumns is the width --stat fits into: --stat-width, then COLUMNS,
+// then the terminal's width, then defaultStatWidth
+func (o diffOptions) statColumns() int {
+	if o.statWidth > 0 {
+		return o.statWidth
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if columns := terminalColumns(); columns > 0 {
+		return columns
+	}
+	return defaultStatWidth
+}
+
+// exitIfCheckFailed ends the command with status 2, as git does, when
+// --check found problems
+func exitIfCheckFailed(out *bufio.Writer, failed bool) {
+	if failed {
+		out.Flush()
+		os.Exit(2)
+	}
+}
+
+// addOutputFlags adds the flags that pick what is shown of the changes
+func addOutputFlags(cmd *cobra.Command, f *outputFlags) {
+	cmd.Flags().BoolVar(&f.stat, "stat", false, "Show a diffstat, bars scaled to the terminal")
+	cmd.Flags().IntVar(&f.statWidth, "stat-width", 0, "Fit --stat into this many columns")
+	cmd.Flags().BoolVar(&f.numstat, "numstat", false, "Show added and removed lines per file, tab-separated")
+	cmd.Flags().BoolVar(&f.shortstat, "shortstat", false, "Show only the summary line of --stat")
+	cmd.Flags().BoolVar(&f.nameOnly, "name-only", false, "Show only the names of changed files")
+	cmd.Flags().BoolVar(&f.nameStatus, "name-status", false, "Show the names and status (A, M, D, R, C) of changed files")
+	cmd.Flags().BoolVar(&f.check, "check", false, "Warn about whitespace errors and conflict markers in added lines")
+}
+
+// options turns the flags into diffOptions; the patch is left to the
+// command, which decides whether it is shown by default
+func (f *outputFlags) options() diffOptions {
+	return diffOptions{
+		stat:       f.stat,
+		numstat:    f.numstat,
+		shortstat:  f.shortstat,
+		nameOnly:   f.nameOnly,
+		nameStatus: f.nameStatus,
+		check:      f.check,
+		statWidth:  f.statWidth,
+	}
+}
+
+// addDiffFlags adds the flags that say how lines are compared and shown
+func addDiffFlags(cmd *cobra.Command, f *diffFlags) {
+	cmd.Flags().StringVar(&f.algorithm, "diff-algorithm", "", "Diff algorithm: myers, patience or histogram")
+	cmd.Flags().BoolVarP(&f.ignoreAllSpace, "ignore-all-space", "w", false, "Ignore whitespace when comparing lines")
+	cmd.Flags().BoolVarP(&f.ignoreSpaceChange, "ignore-space-change", "b", false, "Ignore changes in the amount of whitespace")
+	cmd.Flags().BoolVar(&f.ignoreBlankLines, "ignore-blank-lines", false, "Ignore changes whose lines are all blank")
+	cmd.Flags().StringVar(&f.wordDiff, "word-diff", "", "Show changed words: plain, color or porcelain")
+	cmd.Flags().Lookup("word-diff").NoOptDefVal = diff.WordDiffPlain
+	cmd.Flags().StringVar(&f.wordRegex, "word-diff-regex", "", "What a word is for --word-diff; implies --word-diff")
+}
+
+// options works out how to compare and show lines from the flags, falling
+// back to diff.algorithm and diff.wordRegex
+func (f *diffFlags) options(cmd *cobra.Command) (diff.Options, error) {
+	opts := configDiffOptions()
+
+	var err error
+	if f.algorithm != "" {
+		if opts.Algorithm, err = diff.ParseAlgorithm(f.algorithm); err != nil {
+			return opts, err
+		}
+	}
+	opts.IgnoreAllSpace = f.ignoreAllSpace
+	opts.IgnoreSpaceChange = f.ignoreSpaceChange
+	opts.IgnoreBlankLines = f.ignoreBlankLines
+
+	if f.wordDiff != "" {
+		if opts.WordDiff, err = diff.ParseWordDiff(f.wordDiff); err != nil {
+			return opts, err
+		}
+	}
+	if f.wordRegex != "" {
+		if opts.WordRegex, err = regexp.Compile(f.wordRegex); err != nil {
+			return opts, fmt.Errorf("invalid --word-diff-regex: %w", err)
+		}
+		if !cmd.Flags().Changed("word-diff") {
+			opts.WordDiff = diff.WordDiffPlain
+		}
+	}
+	return opts, nil
+}
+
+// configDiffOptions reads diff.algorithm and diff.wordRegex; settings that
+// don't parse are ignored
+func configDiffOptions() diff.Options {
+	var opts diff.Options
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return opts
+	}
+	if algorithm, err := diff.ParseAlgorithm(cfg.Get("diff.algorithm")); err == nil {
+		opts.Algorithm = algorithm
+	}
+	if pattern := cfg.Get("diff.wordRegex"); pattern != "" {
+		if re, err := regexp.Compile(pattern); err == nil {
+			opts.WordRegex = re
+		}
+	}
+	return opts
+}
+
+// findRenames turns matching deletions and additions into renames and
+// copies, warning when there were too many files to look at
+func findRenames(changes []diff.Change, opts *diff.RenameOptions) ([]diff.Change, error) {
+	if opts == nil {
+		return changes, nil
+	}
+
+	found, needed, err := diff.DetectRenames(changes, *opts)
+	if err != nil {
+		return nil, err
+	}
+	if needed > 0 {
+		fmt.Fprintln(os.Stderr, "warning: inexact rename detection was skipped due to too many files.")
+		fmt.Fprintf(os.Stderr, "warning: you may want to set your diff.renameLimit variable to at least %d and retry the command.\n", needed)
+	}
+	return found, nil
+}
+
+// addRenameFlags adds the rename detection flags to a command
+func addRenameFlags(cmd *cobra.Command, f *renameFlags) {
+	similarity := fmt.Sprintf("%d%%", diff.DefaultRenameThreshold)
+	cmd.Flags().StringVarP(&f.findRenames, "find-renames", "M", "", "Find renames of files at least this similar")
+	cmd.Flags().Lookup("find-renames").NoOptDefVal = similarity
+	cmd.Flags().StringVarP(&f.findCopies, "find-copies", "C", "", "Find copies as well as renames of files at least this similar")
+	cmd.Flags().Lookup("find-copies").NoOptDefVal = similarity
+	cmd.Flags().BoolVar(&f.noRenames, "no-renames", false, "Don't find renames, whatever diff.renames says")
+	cmd.Flags().IntVarP(&f.limit, "rename-limit", "l", 0, "Skip looking for similar files when there are more than this many")
+}
+
+// options works out how to find renames from the flags, falling back to
+// the <section>.renames and diff.renames settings; nil means not at all
+func (f *renameFlags) options(cmd *cobra.Command, section string) (*diff.RenameOptions, error) {
+	opts, on := configRenameOptions(section)
+
+	changed := cmd.Flags().Changed
+	if changed("find-renames") || changed("find-copies") {
+		value := f.findRenames
+		if changed("find-copies") {
+			opts.Copies = true
+			value = f.findCopies
+		}
+		threshold, err := diff.ParseSimilarity(value)
+		if err != nil {
+			return nil, err
+		}
+		opts.Threshold, on = threshold, true
+	}
+	if changed("rename-limit") {
+		opts.Limit = f.limit
+	}
+
+	if f.noRenames || !on {
+		return nil, nil
+	}
+	return &opts, nil
+}
+
+// configRenameOptions reads how to find renames from <section>.renames
+// and <section>.renameLimit, falling back to the diff.* settings. Renames
+// are found unless they are turned off, and "copies" finds copies too.
+// The second result says whether to look for renames at all.
+func configRenameOptions(section string) (diff.RenameOptions, bool) {
+	opts := diff.RenameOptions{Threshold: diff.DefaultRenameThreshold, Limit: diff.DefaultRenameLimit}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return opts, true
+	}
+
+	key, limitKey := "diff.renames", "diff.renameLimit"
+	if len(cfg.GetAll(section+".renames")) > 0 {
+		key = section + ".renames"
+	}
+	if len(cfg.GetAll(section+".renameLimit")) > 0 {
+		limitKey = section + ".renameLimit"
+	}
+	opts.Limit = int(cfg.GetInt(limitKey, diff.DefaultRenameLimit))
+
+	switch strings.ToLower(cfg.Get(key)) {
+	case "copy", "copies":
+		opts.Copies = true
+		return opts, true
+	}
+	return opts, cfg.GetBool(key, true)
+}
+
+// configRenames is how to find renames going by the settings alone, nil
+// when they turn it off
+func configRenames(section string) *diff.RenameOptions {
+	opts, on := configRenameOptions(section)
+	if !on {
+		return nil
+	}
+	return &opts
+}
+
+// mergeRenames is how merges find renames, going by merge.renames and
+// then diff.renames. Merges follow renames but not copies.
+func mergeRenames() *diff.RenameOptions {
+	opts := configRenames("merge")
+	if opts != nil {
+		opts.Copies = false
+	}
+	return opts
+}
+
+// similarityArg is -M or -C with a similarity stuck to it, like -M90%
+var similarityArg = regexp.MustCompile(`^-([MC])([0-9]+%?)$`)
+
+// parseSimilarities lets a command with the rename flags take git's
+// -M90% and -C90%. The flag parser only takes an optional value after
+// "=", so the command parses its own flags, once attachSimilarities has
+// rewritten those arguments.
+func parseSimilarities(cmd *cobra.Command) {
+	run := cmd.RunE
+	cmd.DisableFlagParsing = true
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := cmd.Flags().Parse(attachSimilarities(cmd.Flags(), args)); err != nil {
+			return err
+		}
+		if help, _ := cmd.Flags().GetBool("help"); help {
+			return cmd.Help()
+		}
+		return run(cmd, cmd.Flags().Args())
+	}
+}
+
+// attachSimilarities rewrites "-M90%" as "-M=90%" wherever the flags can
+// be, which is anywhere up to "--". It steps over the values of flags that
+// take one, so a value or a path after "--" that looks like -M50 is left
+// alone.
+func attachSimilarities(flags *pflag.FlagSet, args []string) []string {
+	rewritten := append([]string(nil), args...)
+	for i := 0; i < len(rewritten); i++ {
+		arg := rewritten[i]
+		if arg == "--" {
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			if flag := flags.Lookup(name); flag != nil && flag.NoOptDefVal == "" {
+				i++
+			}
+			continue
+		}
+
+		if similarityArg.MatchString(arg) {
+			rewritten[i] = similarityArg.ReplaceAllString(arg, "-$1=$2")
+			continue
+		}
+		// a run of shorthands ends at the first one that takes a value,
+		// which is the rest of the argument or the next one
+		for k := 1; k < len(arg); k++ {
+			flag := flags.ShorthandLookup(arg[k : k+1])
+			if flag == nil || flag.NoOptDefVal != "" {
+				continue
+			}
+			if k == len(arg)-1 {
+				i++
+			}
+			break
+		}
+	}
+	return rewritten
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/config"
+)
+
+// editorCommand picks the editor the same way git does: ORB_EDITOR, then
+// core.editor, then VISUAL and EDITOR, and vi when nothing is set
+func editorCommand() string {
+	if editor := os.Getenv("ORB_EDITOR"); editor != "" {
+		return editor
+	}
+
+	if cfg, err := config.LoadConfig(); err == nil {
+		if editor := cfg.Get("core.editor"); editor != "" {
+			return editor
+		}
+	}
+
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(name); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// runEditor opens path in the editor and waits for it to exit. The editor
+// goes through the shell so values like "code --wait" work.
+func runEditor(editor, path string) error {
+	// ":" is the conventional way to say "don't edit anything"
+	if editor == ":" {
+		return nil
+	}
+
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("there was a problem with the editor '%s': %w", editor, err)
+	}
+	return nil
+}
+
+// editMessage writes template to path, lets the user edit it and returns
+// what they saved
+func editMessage(path, template string) (string, error) {
+	if err := os.WriteFile(path, []byte(template), 0644); err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := runEditor(editorCommand(), path); err != nil {
+		return "", err
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	return string(edited), nil
+}
+
+// cleanupMessage tidies a message the way git's default cleanup does:
+// trailing whitespace goes, runs of blank lines collapse into one,
+// leading and trailing blank lines are dropped, and '#' lines are
+// removed when stripComments is set
+func cleanupMessage(message string, stripComments bool) string {
+	var lines []string
+	blank := false
+
+	for _, line := range strings.Split(message, "\n") {
+		if stripComments && strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/spf13/cobra"
+)
+
+func newHashObjectCommand() *cobra.Command {
+	var (
+		write     bool
+		objType   string
+		stdin     bool
+		literally bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "hash-object [-w] [-t <type>] [--stdin] [<file>...]",
+		Short: "Compute an object's hash and optionally store it",
+		Long: `Print the hash a file's content has as an object of the given type
+(blob by default). With -w the object is also written to the object store.
+--stdin reads the content from standard input instead of a file.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !stdin && len(args) == 0 {
+				return fmt.Errorf("no files given; use --stdin to read from standard input")
+			}
+
+			hash := func(content []byte, name string) error {
+				if !literally {
+					if err := checkObjectContent(objType, content); err != nil {
+						return fmt.Errorf("%s: %w", name, err)
+					}
+				}
+
+				if !write {
+					fmt.Println(objects.HashObject(objType, content))
+					return nil
+				}
+				h, err := objects.WriteObject(objType, content)
+				if err != nil {
+					return err
+				}
+				fmt.Println(h)
+				return nil
+			}
+
+			if stdin {
+				content, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("reading stdin: %w", err)
+				}
+				if err := hash(content, "stdin"); err != nil {
+					return err
+				}
+			}
+
+			for _, path := range args {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("reading file: %w", err)
+				}
+				if err := hash(content, path); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "Write the object into the object store")
+	cmd.Flags().StringVarP(&objType, "type", "t", objects.BlobType, "Object type: blob, tree, commit or tag")
+	cmd.Flags().BoolVar(&stdin, "stdin", false, "Read the content from standard input")
+	cmd.Flags().BoolVar(&literally, "literally", false, "Don't check that the content is a valid object of its type")
+
+	return cmd
+}
+
+// checkObjectContent makes sure content parses as an object of the type,
+// so plumbing can't store a broken commit or tree by accident
+func checkObjectContent(objType string, content []byte) error {
+	var err error
+	switch objType {
+	case objects.BlobType:
+		return nil
+	case objects.TreeType:
+		_, err = objects.ParseTree(content)
+	case objects.CommitType:
+		_, err = objects.ParseCommit(content)
+	case objects.TagType:
+		_, err = objects.ParseTag(content)
+	default:
+		return fmt.Errorf("invalid object type %q", objType)
+	}
+	if err != nil {
+		return fmt.Errorf("not a valid %s object: %w", objType, err)
+	}
+	return nil
+}
@@ -2,224 +2,347 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/ayushsarode/orb/internal/config"
+	"github.com/ayushsarode/orb/internal/diff"
 	"github.com/ayushsarode/orb/internal/objects"
 	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
 	"github.com/spf13/cobra"
 )
 
+// defaultStatWidth is the width --stat output is fitted into when the
+// terminal's width isn't known
+const defaultStatWidth = 80
+
+// logFilter holds the options that decide which walked commits are shown
+type logFilter struct {
+	since  time.Time
+	until  time.Time
+	author *regexp.Regexp
+	grep   *regexp.Regexp
+}
+
 func newLogCommand() *cobra.Command {
-	var quiet bool
+	var (
+		quiet    bool
+		maxCount int
+		since    string
+		until    string
+		author   string
+		grep     string
+		oneline  bool
+		format   string
+		graph    bool
+		output   outputFlags
+		patch    bool
+		decorate string
+		lines    diffFlags
+		renames  renameFlags
+	)
 
 	cmd := &cobra.Command{
-		Use:   "log",
+		Use:   "log [<revision-range>] [-- <path>...]",
 		Short: "Show commit logs",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			startRef := "HEAD"
-			if len(args) > 0 {
-				startRef = args[0]
+			// revisions come before "--" and paths after it
+			revs, paths := args, []string(nil)
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				revs, paths = args[:dash], args[dash:]
+			}
+			if len(revs) == 0 {
+				revs = []string{"HEAD"}
+			}
+
+			walker := revision.NewWalker()
+			walker.Paths = cleanPathspecs(paths)
+			for _, rev := range revs {
+				if err := walker.AddRevision(rev); err != nil {
+					return fmt.Errorf("bad revision '%s': %w", rev, err)
+				}
 			}
 
-			// Get the commit hash
-			commitHash, err := refs.GetRef(startRef)
+			filter, err := newLogFilter(since, until, author, grep)
 			if err != nil {
-				return fmt.Errorf("getting reference: %w", err)
+				return err
 			}
 
-			// Create a map of commit hashes to branch names
-			branchRefs := make(map[string][]string)
+			commitFmt, err := parseCommitFormat(format)
+			if err != nil {
+				return err
+			}
+			if oneline {
+				commitFmt = commitFormat{name: "oneline", abbrev: true}
+			}
+			commitFmt.color = useColor()
 
-			// Read all branches
-			branchFiles, err := os.ReadDir(refs.HeadsDir)
-			if err == nil {
-				for _, file := range branchFiles {
-					if file.IsDir() {
-						continue
-					}
+			// --decorate wins over log.decorate, which defaults to short
+			if !cmd.Flags().Changed("decorate") {
+				decorate = decorateShort
+				if cfg, err := config.LoadConfig(); err == nil {
+					decorate = cfg.GetString("log.decorate", decorateShort)
+				}
+			}
+			decorateStyle, err := parseDecorateStyle(decorate)
+			if err != nil {
+				return err
+			}
 
-					branchName := file.Name()
-					branchPath := filepath.Join(refs.HeadsDir, branchName)
-					branchCommit, err := os.ReadFile(branchPath)
-					if err != nil {
-						continue
-					}
+			decorations, err := refs.LoadDecorations()
+			if err != nil {
+				return err
+			}
 
-					hash := strings.TrimSpace(string(branchCommit))
-					branchRefs[hash] = append(branchRefs[hash], branchName)
-				}
+			diffOpts := output.options()
+			diffOpts.patch = patch
+			if diffOpts.lines, err = lines.options(cmd); err != nil {
+				return err
+			}
+			if diffOpts.renames, err = renames.options(cmd, "diff"); err != nil {
+				return err
 			}
 
-			// commit history
-			for commitHash != "" {
-				objType, content, err := objects.ReadObject(commitHash)
-				if err != nil {
-					fmt.Printf("Warning: Error reading commit %s: %v\n", commitHash, err)
-					if quiet {
-						return nil
-					}
-					break
-				}
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
 
-				if objType != objects.CommitType {
-					fmt.Printf("Warning: Object is not a commit: %s\n", commitHash)
-					break
-				}
+			var g *logGraph
+			if graph {
+				g = &logGraph{}
+			}
 
-				// parse the commit
-				commit, err := parseCommit(string(content))
+			shown, checkFailed := 0, false
+			for maxCount < 0 || shown < maxCount {
+				commit, err := walker.Next()
 				if err != nil {
-					fmt.Printf("Warning: Error parsing commit: %v\n", err)
+					return err
+				}
+				if commit == nil {
 					break
 				}
 
-				fmt.Printf("commit %s", commitHash)
-
-				// branch information if this commit is the head of any branches
-				if branches, ok := branchRefs[commitHash]; ok && len(branches) > 0 {
-					fmt.Printf(" (")
-					for i, branch := range branches {
-						if i > 0 {
-							fmt.Printf(", ")
-						}
-
-						// Highlight current branch
-						headContent, _ := os.ReadFile(refs.HeadFile)
-						currentBranch := ""
-						if headRef := strings.TrimSpace(string(headContent)); strings.HasPrefix(headRef, "ref: refs/heads/") {
-							currentBranch = strings.TrimPrefix(headRef, "ref: refs/heads/")
-						}
-
-						if branch == currentBranch {
-							fmt.Printf("HEAD -> %s", branch)
-						} else {
-							fmt.Printf("%s", branch)
-						}
+				if !filter.matches(commit.Commit) {
+					// the graph still has to move past the commit
+					if g != nil {
+						g.takeCollapse()
+						g.add(commit.Hash, commit.Parents)
 					}
-					fmt.Printf(")")
+					continue
 				}
-				fmt.Printf("\n")
 
-				fmt.Printf("Author: %s\n", commit.Author)
+				names := decorationNames(decorations.For(commit.Hash), decorateStyle, commitFmt.color)
+				lines := formatCommit(commit.Hash, commit.Commit, commitFmt, names)
 
-				// Only format date if it's not zero
-				if !commit.CommitTime.IsZero() {
-					fmt.Printf("Date:   %s\n", commit.CommitTime.Format(time.RFC1123Z))
-				} else {
-					fmt.Printf("Date:   (unknown)\n")
+				if diffOpts.patch || diffOpts.summarized() {
+					diffLines, err := commitDiffLines(commit.Commit, walker.Paths, diffOpts)
+					if errors.Is(err, errCheckFailed) {
+						checkFailed = true
+					} else if err != nil {
+						return err
+					}
+					if len(diffLines) > 0 && commitFmt.multiLine() {
+						lines = append(lines, "")
+					}
+					lines = append(lines, diffLines...)
 				}
 
-				fmt.Printf("\n    %s\n\n", commit.Message)
-
-				// check if parent exists before trying to access it
-				if commit.Parent == "" {
-					break
-				}
+				printLogEntry(out, g, lines, shown > 0 && commitFmt.multiLine(), commit)
+				shown++
+			}
 
-				// Check if parent object exists before continuing
-				parentExists := true
-				parentPath := filepath.Join(".orb/objects", commit.Parent[:2], commit.Parent[2:])
-				if _, err := os.Stat(parentPath); os.IsNotExist(err) {
-					if !quiet {
-						fmt.Printf("Warning: Parent commit %s not found\n", commit.Parent)
+			if !quiet {
+				reported := make(map[string]bool)
+				for _, missing := range walker.Missing {
+					if !reported[missing] {
+						reported[missing] = true
+						fmt.Fprintf(os.Stderr, "Warning: Parent commit %s not found\n", missing)
 					}
-					parentExists = false
 				}
-
-				if !parentExists {
-					break
-				}
-
-				commitHash = commit.Parent
 			}
-
+			exitIfCheckFailed(out, checkFailed)
 			return nil
 		},
 	}
 
 	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress warnings about missing commits")
+	cmd.Flags().IntVarP(&maxCount, "max-count", "n", -1, "Limit the number of commits to output")
+	cmd.Flags().StringVar(&since, "since", "", "Show commits more recent than a date")
+	cmd.Flags().StringVar(&since, "after", "", "Same as --since")
+	cmd.Flags().StringVar(&until, "until", "", "Show commits older than a date")
+	cmd.Flags().StringVar(&until, "before", "", "Same as --until")
+	cmd.Flags().StringVar(&author, "author", "", "Show commits whose author matches a pattern")
+	cmd.Flags().StringVar(&grep, "grep", "", "Show commits whose message matches a pattern")
+	cmd.Flags().BoolVar(&oneline, "oneline", false, "Show each commit on one line")
+	cmd.Flags().StringVar(&format, "format", "", "Pretty-print commits: oneline, short, medium, full, fuller or format:<string>")
+	cmd.Flags().StringVar(&format, "pretty", "", "Same as --format")
+	cmd.Flags().BoolVar(&graph, "graph", false, "Draw the commit history as a graph")
+	addOutputFlags(cmd, &output)
+	cmd.Flags().BoolVarP(&patch, "patch", "p", false, "Show the patch for each commit")
+	cmd.Flags().StringVar(&decorate, "decorate", "", "Show ref names next to commits: short, full or no")
+	cmd.Flags().Lookup("decorate").NoOptDefVal = decorateShort
+	addDiffFlags(cmd, &lines)
+	addRenameFlags(cmd, &renames)
+	parseSimilarities(cmd)
 
 	return cmd
 }
 
-// Commit represents a parsed commit object
-type Commit struct {
-	TreeHash   string
-	Parent     string
-	Author     string
-	Committer  string
-	Message    string
-	CommitTime time.Time
+// newLogFilter parses the filtering options; empty ones match everything
+func newLogFilter(since, until, author, grep string) (*logFilter, error) {
+	filter := &logFilter{}
+	var err error
+
+	if since != "" {
+		if filter.since, err = revision.ParseDate(since); err != nil {
+			return nil, fmt.Errorf("invalid --since date: %w", err)
+		}
+	}
+	if until != "" {
+		if filter.until, err = revision.ParseDate(until); err != nil {
+			return nil, fmt.Errorf("invalid --until date: %w", err)
+		}
+	}
+	if author != "" {
+		if filter.author, err = regexp.Compile(author); err != nil {
+			return nil, fmt.Errorf("invalid --author pattern: %w", err)
+		}
+	}
+	if grep != "" {
+		if filter.grep, err = regexp.Compile(grep); err != nil {
+			return nil, fmt.Errorf("invalid --grep pattern: %w", err)
+		}
+	}
+	return filter, nil
 }
 
-// parseCommit parses the content of a commit object
-func parseCommit(content string) (*Commit, error) {
-	commit := &Commit{}
+// matches reports whether a commit passes every filter. Dates are the
+// committer's, names and emails the author's, like git.
+func (f *logFilter) matches(commit *objects.Commit) bool {
+	when := commit.Committer.When
+	if !f.since.IsZero() && when.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && when.After(f.until) {
+		return false
+	}
+	if f.author != nil && !f.author.MatchString(commit.Author.Name+" <"+commit.Author.Email+">") {
+		return false
+	}
+	if f.grep != nil && !f.grep.MatchString(commit.Message) {
+		return false
+	}
+	return true
+}
 
-	lines := strings.Split(content, "\n")
-	messageStart := -1
+// printLogEntry writes a commit's lines, drawing the graph in front of them
+func printLogEntry(out *bufio.Writer, g *logGraph, lines []string, separate bool, commit *revision.WalkCommit) {
+	if g == nil {
+		if separate {
+			fmt.Fprintln(out)
+		}
+		for _, line := range lines {
+			fmt.Fprintln(out, line)
+		}
+		return
+	}
+
+	if separate {
+		fmt.Fprintln(out, strings.TrimRight(g.padding, " "))
+	}
+	if collapse := g.takeCollapse(); collapse != "" {
+		fmt.Fprintln(out, collapse)
+	}
 
+	row, fanOut := g.add(commit.Hash, commit.Parents)
 	for i, line := range lines {
-		if line == "" {
-			messageStart = i + 1
-			break
+		prefix := g.padding
+		switch {
+		case i == 0:
+			prefix = row
+		case i-1 < len(fanOut):
+			prefix = fanOut[i-1]
 		}
+		fmt.Fprintln(out, strings.TrimRight(prefix+line, " "))
+	}
+	// a one-line entry leaves the fan-out lines to stand on their own
+	for i := len(lines) - 1; i < len(fanOut); i++ {
+		fmt.Fprintln(out, strings.TrimRight(fanOut[i], " "))
+	}
+}
+
+// commitDiffLines renders what a commit changed against its first parent
+// the way opts ask. Merges show nothing, as in git. When --check finds
+// problems the lines come back along with errCheckFailed.
+func commitDiffLines(commit *objects.Commit, paths []string, opts diffOptions) ([]string, error) {
+	if len(commit.Parents) > 1 {
+		return nil, nil
+	}
 
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) != 2 {
-			continue
+	parentTree := ""
+	if len(commit.Parents) == 1 {
+		parent, err := objects.ReadCommit(commit.Parents[0])
+		if err != nil {
+			// the parent isn't here, so there is nothing to compare against
+			return nil, nil
 		}
+		parentTree = parent.Tree
+	}
 
-		key := parts[0]
-		value := parts[1]
-
-		switch key {
-		case "tree":
-			commit.TreeHash = value
-		case "parent":
-			commit.Parent = value
-		case "author":
-			// Extract name and email part, removing the timestamp
-			if idx := strings.LastIndex(value, ">"); idx > 0 {
-				commit.Author = value[:idx+1]
-
-				// Parse timestamp for display in the Date field
-				timestampStr := strings.TrimSpace(value[idx+1:])
-				if ts, err := parseTimestamp(timestampStr); err == nil {
-					commit.CommitTime = ts
-				}
-			} else {
-				commit.Author = value
-			}
-		case "committer":
-			commit.Committer = value
+	changes, err := diff.Trees(parentTree, commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	// with paths given, only their changes are shown
+	var shown []diff.Change
+	for _, change := range changes {
+		if matchesPathspec(change.Path, paths) {
+			shown = append(shown, change)
 		}
 	}
+	if len(shown) == 0 {
+		return nil, nil
+	}
 
-	// Extract the commit message
-	if messageStart >= 0 && messageStart < len(lines) {
-		commit.Message = strings.Join(lines[messageStart:], "\n")
+	var buf bytes.Buffer
+	err = writeChanges(&buf, shown, opts)
+	if err != nil && !errors.Is(err, errCheckFailed) {
+		return nil, err
+	}
+	if buf.Len() == 0 {
+		return nil, err
 	}
 
-	return commit, nil
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), err
 }
 
-// parseTimestamp parses a Git-style timestamp (e.g. "1621234567 +0200") into a time.Time
-func parseTimestamp(timestamp string) (time.Time, error) {
-	parts := strings.Split(timestamp, " ")
-	if len(parts) < 2 {
-		return time.Time{}, fmt.Errorf("invalid timestamp format")
+// cleanPathspecs turns path arguments into clean slash-separated paths
+func cleanPathspecs(paths []string) []string {
+	var cleaned []string
+	for _, path := range paths {
+		cleaned = append(cleaned, filepath.ToSlash(filepath.Clean(path)))
 	}
+	return cleaned
+}
 
-	unixTime, err := strconv.ParseInt(parts[0], 10, 64)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("invalid unix timestamp: %w", err)
+// matchesPathspec reports whether a path is one of the pathspecs or inside
+// one of them; no pathspecs match everything
+func matchesPathspec(path string, specs []string) bool {
+	if len(specs) == 0 {
+		return true
 	}
-
-	return time.Unix(unixTime, 0), nil
+	for _, spec := range specs {
+		if spec == "." || path == spec || strings.HasPrefix(path, spec+"/") {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+)
+
+// logGraph draws the ASCII history graph of log --graph. Every lane is a
+// column of "|" waiting for one commit; a commit takes over its lane with
+// "*", a merge opens lanes for its other parents with "\" and lanes that
+// end up waiting for the same commit are folded together with "/".
+type logGraph struct {
+	lanes    []string
+	padding  string // prefix for the remaining lines of the current commit
+	collapse string // line drawn before the next commit, once lanes have moved
+}
+
+// takeCollapse returns the line folding lanes after the last commit, if any
+func (g *logGraph) takeCollapse() string {
+	line := g.collapse
+	g.collapse = ""
+	return line
+}
+
+// add places a commit in the graph. It returns the prefix for the commit's
+// first line and the lines fanning out to a merge's other parents, which
+// go in front of the following lines.
+func (g *logGraph) add(hash string, parents []string) (string, []string) {
+	col := slices.Index(g.lanes, hash)
+	if col < 0 {
+		g.lanes = append(g.lanes, hash)
+		col = len(g.lanes) - 1
+	}
+	row := g.render(col)
+
+	// a merge's other parents each get a new lane to the right
+	var fanOut []string
+	insertAt := col + 1
+	for _, parent := range parents[min(1, len(parents)):] {
+		if slices.Contains(g.lanes, parent) {
+			continue
+		}
+		fanOut = append(fanOut, g.fanOutLine(insertAt))
+		g.lanes = slices.Insert(g.lanes, insertAt, parent)
+		insertAt++
+	}
+	g.padding = g.render(-1)
+
+	// the lane then waits for the first parent, unless another lane
+	// already does, in which case the two fold into the leftmost one
+	switch {
+	case len(parents) == 0:
+		g.collapse = g.removeLane(col, false)
+	case slices.Contains(g.lanes, parents[0]):
+		other := slices.Index(g.lanes, parents[0])
+		g.lanes[min(col, other)] = parents[0]
+		g.collapse = g.removeLane(max(col, other), true)
+	default:
+		g.lanes[col] = parents[0]
+	}
+
+	return row, fanOut
+}
+
+// render draws one "|" per lane, with "*" in column mark
+func (g *logGraph) render(mark int) string {
+	var b strings.Builder
+	for i := range g.lanes {
+		if i == mark {
+			b.WriteString("* ")
+		} else {
+			b.WriteString("| ")
+		}
+	}
+	return b.String()
+}
+
+// fanOutLine draws a new lane opening at position insertAt, pushing the
+// lanes from there on one column to the right
+func (g *logGraph) fanOutLine(insertAt int) string {
+	line := []byte(strings.Repeat(" ", 2*(len(g.lanes)+1)))
+	for i := range g.lanes {
+		if i < insertAt {
+			line[2*i] = '|'
+		} else {
+			line[2*i+1] = '\\'
+		}
+	}
+	line[2*insertAt-1] = '\\'
+	return string(line)
+}
+
+// removeLane drops a lane and returns the line showing the lanes to its
+// right moving over; joined says the lane merges into its left neighbour
+func (g *logGraph) removeLane(lane int, joined bool) string {
+	line := []byte(strings.Repeat(" ", 2*len(g.lanes)))
+	moved := false
+	for i := range g.lanes {
+		switch {
+		case i < lane:
+			line[2*i] = '|'
+		case i == lane && joined && lane > 0:
+			line[2*i-1] = '/'
+			moved = true
+		case i > lane:
+			line[2*i-1] = '/'
+			moved = true
+		}
+	}
+	g.lanes = slices.Delete(g.lanes, lane, lane+1)
+
+	if !moved {
+		return ""
+	}
+	return strings.TrimRight(string(line), " ")
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+func newLsTreeCommand() *cobra.Command {
+	var (
+		recursive bool
+		nameOnly  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ls-tree [-r] [--name-only] <tree-ish> [<path>...]",
+		Short: "List the contents of a tree object",
+		Long: `List the entries of a tree, or of the tree of a commit or tag, as
+"<mode> <type> <hash>\t<path>". With paths, only entries at or under them
+are listed. -r descends into subdirectories and lists only files.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hash, err := revision.ResolveObject(args[0])
+			if err != nil {
+				return fmt.Errorf("not a valid object name %s", args[0])
+			}
+			tree, err := revision.PeelToTree(hash)
+			if err != nil {
+				return err
+			}
+
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+
+			emit := func(entry objects.TreeEntry, path string) {
+				if nameOnly {
+					fmt.Fprintln(out, path)
+				} else {
+					fmt.Fprintf(out, "%s %s %s\t%s\n", entry.Mode, entry.Type, entry.Hash, path)
+				}
+			}
+			return listTree(tree, "", cleanPathspecs(args[1:]), recursive, emit)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recurse into subdirectories")
+	cmd.Flags().BoolVar(&nameOnly, "name-only", false, "List only the paths")
+
+	return cmd
+}
+
+// listTree walks a tree and hands every entry to emit. Directories that
+// lead to one of the paths are entered even without recursive.
+func listTree(tree, prefix string, paths []string, recursive bool, emit func(objects.TreeEntry, string)) error {
+	entries, err := objects.ReadTree(tree)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := prefix + entry.Name
+		if !matchesPathspec(path, paths) && !leadsToPathspec(path, paths) {
+			continue
+		}
+
+		if entry.IsTree() && (recursive || leadsToPathspec(path, paths)) {
+			if err := listTree(entry.Hash, path+"/", paths, recursive, emit); err != nil {
+				return err
+			}
+			continue
+		}
+		emit(entry, path)
+	}
+	return nil
+}
+
+// leadsToPathspec reports whether a directory contains one of the pathspecs
+func leadsToPathspec(dir string, specs []string) bool {
+	for _, spec := range specs {
+		if len(spec) > len(dir) && spec[:len(dir)+1] == dir+"/" {
+			return true
+		}
+	}
+	return false
+}
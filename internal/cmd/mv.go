@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/spf13/cobra"
+)
+
+func newMvCommand() *cobra.Command {
+	var (
+		force   bool
+		skip    bool
+		dryRun  bool
+		verbose bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "mv [-f] [-k] [-n] [-v] <source>... <destination>",
+		Short: "Move or rename a file or directory",
+		Long: `Move tracked files or directories, updating the index along with the
+working tree so the move is staged. With several sources, or when the
+destination is an existing directory, the sources are moved into it.
+
+An existing destination file is only overwritten with -f. -k skips
+sources that can't be moved instead of stopping.`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			idx, err := index.LoadIndex()
+			if err != nil {
+				return fmt.Errorf("loading index: %w", err)
+			}
+
+			sources := cleanPathspecs(args[:len(args)-1])
+			dest := cleanPathspecs(args[len(args)-1:])[0]
+
+			info, err := os.Stat(dest)
+			destIsDir := err == nil && info.IsDir()
+			if len(sources) > 1 && !destIsDir {
+				return fmt.Errorf("destination '%s' is not a directory", dest)
+			}
+
+			for _, source := range sources {
+				target := dest
+				if destIsDir {
+					target = filepath.ToSlash(filepath.Join(dest, filepath.Base(source)))
+				}
+
+				if err := checkMove(idx, source, target, force); err != nil {
+					if skip {
+						continue
+					}
+					return fmt.Errorf("%w, source=%s, destination=%s", err, source, target)
+				}
+
+				if verbose || dryRun {
+					fmt.Printf("Renaming %s to %s\n", source, target)
+				}
+				if dryRun {
+					continue
+				}
+				if err := moveTracked(idx, source, target); err != nil {
+					return err
+				}
+			}
+
+			if dryRun {
+				return nil
+			}
+			if err := idx.Write(); err != nil {
+				return fmt.Errorf("writing index: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite an existing destination file")
+	cmd.Flags().BoolVarP(&skip, "skip-errors", "k", false, "Skip sources that can't be moved")
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only show what would be moved")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Report each move")
+
+	return cmd
+}
+
+// checkMove makes sure a tracked file or directory can be moved to target
+func checkMove(idx *index.Index, source, target string, force bool) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("bad source")
+	}
+	if len(trackedUnder(idx, source)) == 0 {
+		return fmt.Errorf("not under version control")
+	}
+	if info.IsDir() && (target == source || strings.HasPrefix(target, source+"/")) {
+		return fmt.Errorf("can not move directory into itself")
+	}
+
+	if targetInfo, err := os.Stat(target); err == nil {
+		if info.IsDir() || targetInfo.IsDir() || !force {
+			return fmt.Errorf("destination exists")
+		}
+	}
+	return nil
+}
+
+// moveTracked renames a file or directory on disk and moves the index
+// entries below it along, keeping what was staged for them
+func moveTracked(idx *index.Index, source, target string) error {
+	if dir := filepath.Dir(target); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating directory for %s: %w", target, err)
+		}
+	}
+	if err := os.Rename(source, target); err != nil {
+		return fmt.Errorf("renaming %s failed: %w", source, err)
+	}
+
+	// a forced move replaces whatever was tracked at the target
+	idx.Remove(target)
+	for _, path := range trackedUnder(idx, source) {
+		hash := idx.Entries[path].ObjectHash
+		idx.Remove(path)
+		idx.SetEntry(target+strings.TrimPrefix(path, source), hash)
+	}
+	return nil
+}
+
+// trackedUnder lists the index entries that are path or inside it
+func trackedUnder(idx *index.Index, path string) []string {
+	var paths []string
+	for entry := range idx.Entries {
+		if entry == path || strings.HasPrefix(entry, path+"/") {
+			paths = append(paths, entry)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// gitDateLayout is how git prints dates by default
+const gitDateLayout = "Mon Jan 2 15:04:05 2006 -0700"
+
+// commitFormat is a --pretty/--format choice
+type commitFormat struct {
+	name     string // oneline, short, medium, full, fuller or format
+	template string // the placeholders of a "format:" choice
+	abbrev   bool   // abbreviate the hash on the first line, as --oneline does
+	color    bool   // color the hash and the decorations
+}
+
+// parseCommitFormat understands the built-in format names, "format:..."
+// and "tformat:...", and a bare string containing placeholders
+func parseCommitFormat(value string) (commitFormat, error) {
+	switch value {
+	case "", "medium":
+		return commitFormat{name: "medium"}, nil
+	case "oneline", "short", "full", "fuller":
+		return commitFormat{name: value}, nil
+	}
+
+	for _, prefix := range []string{"format:", "tformat:"} {
+		if template, ok := strings.CutPrefix(value, prefix); ok {
+			return commitFormat{name: "format", template: template}, nil
+		}
+	}
+	if strings.Contains(value, "%") {
+		return commitFormat{name: "format", template: value}, nil
+	}
+	return commitFormat{}, fmt.Errorf("invalid --pretty format: %s", value)
+}
+
+// multiLine reports whether commits in this format are separated by a blank line
+func (f commitFormat) multiLine() bool {
+	return f.name != "oneline" && f.name != "format"
+}
+
+// formatCommit renders a commit in a format, one string per line. The
+// decoration is the list of ref names pointing at the commit.
+func formatCommit(hash string, commit *objects.Commit, format commitFormat, decoration []string) []string {
+	shown := hash
+	if format.abbrev {
+		shown = shortHash(hash)
+	}
+
+	switch format.name {
+	case "oneline":
+		return []string{colorize(format.color, colorYellow, shown) + decorationSuffix(decoration, format.color) + " " + commitTitle(commit.Message)}
+	case "format":
+		return strings.Split(expandPlaceholders(format.template, hash, commit, decoration), "\n")
+	}
+
+	lines := []string{colorize(format.color, colorYellow, "commit "+shown) + decorationSuffix(decoration, format.color)}
+	if len(commit.Parents) > 1 {
+		var short []string
+		for _, parent := range commit.Parents {
+			short = append(short, shortHash(parent))
+		}
+		lines = append(lines, "Merge: "+strings.Join(short, " "))
+	}
+
+	author := commit.Author.Name + " <" + commit.Author.Email + ">"
+	committer := commit.Committer.Name + " <" + commit.Committer.Email + ">"
+	switch format.name {
+	case "short":
+		lines = append(lines, "Author: "+author)
+	case "full":
+		lines = append(lines, "Author: "+author, "Commit: "+committer)
+	case "fuller":
+		lines = append(lines,
+			"Author:     "+author,
+			"AuthorDate: "+commit.Author.When.Format(gitDateLayout),
+			"Commit:     "+committer,
+			"CommitDate: "+commit.Committer.When.Format(gitDateLayout))
+	default:
+		lines = append(lines, "Author: "+author, "Date:   "+commit.Author.When.Format(gitDateLayout))
+	}
+
+	lines = append(lines, "")
+	message := strings.TrimRight(commit.Message, "\n")
+	if format.name == "short" {
+		message = commitTitle(commit.Message)
+	}
+	for _, line := range strings.Split(message, "\n") {
+		lines = append(lines, "    "+line)
+	}
+	return lines
+}
+
+// decorationSuffix formats ref names as " (HEAD -> main, tag: v1)"
+func decorationSuffix(decoration []string, color bool) string {
+	if len(decoration) == 0 {
+		return ""
+	}
+	return colorize(color, colorYellow, " (") +
+		strings.Join(decoration, colorize(color, colorYellow, ", ")) +
+		colorize(color, colorYellow, ")")
+}
+
+// commitTitle returns the subject: the first paragraph of the message
+// joined into one line
+func commitTitle(message string) string {
+	paragraph, _, _ := strings.Cut(strings.TrimLeft(message, "\n"), "\n\n")
+	return strings.Join(strings.Fields(strings.ReplaceAll(paragraph, "\n", " ")), " ")
+}
+
+// commitBody returns the message after the subject paragraph
+func commitBody(message string) string {
+	_, body, _ := strings.Cut(strings.TrimLeft(message, "\n"), "\n\n")
+	return strings.TrimLeft(body, "\n")
+}
+
+// expandPlaceholders fills in a --format template
+func expandPlaceholders(template, hash string, commit *objects.Commit, decoration []string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' || i+1 == len(template) {
+			b.WriteByte(template[i])
+			continue
+		}
+
+		value, width, ok := placeholder(template[i+1:], hash, commit, decoration)
+		if !ok {
+			b.WriteByte('%')
+			continue
+		}
+		b.WriteString(value)
+		i += width
+	}
+	return b.String()
+}
+
+// placeholder expands the placeholder at the start of spec, returning its
+// value and how many characters it used
+func placeholder(spec, hash string, commit *objects.Commit, decoration []string) (string, int, bool) {
+	switch spec[0] {
+	case '%':
+		return "%", 1, true
+	case 'n':
+		return "\n", 1, true
+	case 'H':
+		return hash, 1, true
+	case 'h':
+		return shortHash(hash), 1, true
+	case 'T':
+		return commit.Tree, 1, true
+	case 't':
+		return shortHash(commit.Tree), 1, true
+	case 'P':
+		return strings.Join(commit.Parents, " "), 1, true
+	case 'p':
+		var short []string
+		for _, parent := range commit.Parents {
+			short = append(short, shortHash(parent))
+		}
+		return strings.Join(short, " "), 1, true
+	case 's':
+		return commitTitle(commit.Message), 1, true
+	case 'b':
+		return commitBody(commit.Message), 1, true
+	case 'B':
+		return commit.Message, 1, true
+	case 'd':
+		return decorationSuffix(decoration, false), 1, true
+	case 'D':
+		return strings.Join(decoration, ", "), 1, true
+	case 'a', 'c':
+		if len(spec) < 2 {
+			return "", 0, false
+		}
+		sig := commit.Author
+		if spec[0] == 'c' {
+			sig = commit.Committer
+		}
+		value, ok := signaturePlaceholder(spec[1], sig)
+		return value, 2, ok
+	}
+	return "", 0, false
+}
+
+// signaturePlaceholder expands the second letter of %an, %ce, %ad and friends
+func signaturePlaceholder(field byte, sig objects.Signature) (string, bool) {
+	switch field {
+	case 'n':
+		return sig.Name, true
+	case 'e':
+		return sig.Email, true
+	case 'd':
+		return sig.When.Format(gitDateLayout), true
+	case 'r':
+		return relativeDate(sig.When, time.Now()), true
+	case 't':
+		return fmt.Sprint(sig.When.Unix()), true
+	case 'i':
+		return sig.When.Format("2006-01-02 15:04:05 -0700"), true
+	case 'I':
+		return sig.When.Format(time.RFC3339), true
+	case 's':
+		return sig.When.Format("2006-01-02"), true
+	}
+	return "", false
+}
+
+// relativeDate describes a time the way git's --date=relative does
+func relativeDate(t, now time.Time) string {
+	seconds := int64(now.Sub(t).Seconds())
+	if seconds < 0 {
+		return "in the future"
+	}
+
+	ago := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s ago", n, unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	switch {
+	case seconds < 90:
+		return ago(seconds, "second")
+	case seconds < 90*60:
+		return ago((seconds+30)/60, "minute")
+	case seconds < 36*3600:
+		return ago((seconds+1800)/3600, "hour")
+	}
+
+	days := (seconds + 43200) / 86400
+	switch {
+	case days < 14:
+		return ago(days, "day")
+	case days < 70:
+		return ago((days+3)/7, "week")
+	case days < 365:
+		return ago((days+15)/30, "month")
+	}
+
+	years := days / 365
+	if months := (days%365 + 15) / 30; years < 5 && months > 0 {
+		return fmt.Sprintf("%s, %s", strings.TrimSuffix(ago(years, "year"), " ago"), ago(months, "month"))
+	}
+	return ago((days+183)/365, "year")
+}
@@ -0,0 +1,884 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/config"
+	"github.com/ayushsarode/orb/internal/ident"
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/ayushsarode/orb/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+// rebaseDir holds the state of a rebase in progress
+const rebaseDir = ".orb/rebase-merge"
+
+// todoFile is the list of steps still to do, in the same format the
+// editor is given
+var todoFile = filepath.Join(rebaseDir, "git-rebase-todo")
+
+// todo list commands and their one-letter abbreviations
+var rebaseCommands = map[string]string{
+	"p": "pick", "pick": "pick",
+	"r": "reword", "reword": "reword",
+	"e": "edit", "edit": "edit",
+	"s": "squash", "squash": "squash",
+	"f": "fixup", "fixup": "fixup",
+	"x": "exec", "exec": "exec",
+	"d": "drop", "drop": "drop",
+}
+
+const todoHelp = `
+# Commands:
+# p, pick <commit> = use commit
+# r, reword <commit> = use commit, but edit the commit message
+# e, edit <commit> = use commit, but stop for amending
+# s, squash <commit> = use commit, but meld into previous commit
+# f, fixup <commit> = like "squash", but discard this commit's log message
+# x, exec <command> = run command (the rest of the line) using shell
+# d, drop <commit> = remove commit
+#
+# These lines can be re-ordered; they are executed from top to bottom.
+# If you remove everything, the rebase will be aborted.
+`
+
+// rebaseStep is one line of the todo list
+type rebaseStep struct {
+	command string
+	hash    string // the commit, for everything but exec
+	arg     string // the commit's subject, or the shell command of exec
+}
+
+// rebaseState is a rebase in progress
+type rebaseState struct {
+	headName string // the branch being rebased, or "" for a detached HEAD
+	origHead string
+	onto     string
+	todo     []rebaseStep
+	done     []rebaseStep
+
+	// where the rebase stopped, if it did: the step and "conflict", "edit"
+	// or "exec"
+	stopped       rebaseStep
+	stoppedReason string
+}
+
+func newRebaseCommand() *cobra.Command {
+	var (
+		onto        string
+		interactive bool
+		autosquash  bool
+		cont        bool
+		skip        bool
+		abort       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rebase [-i] [--autosquash] [--onto <newbase>] [<upstream> [<branch>]] | rebase (--continue | --skip | --abort)",
+		Short: "Replay commits on top of another base",
+		Long: `Replay the commits of the current branch that aren't in <upstream> on top
+of it, or on top of <newbase> with --onto, then move the branch to the
+result. With <branch>, that branch is checked out first. Without
+<upstream>, the branch's configured upstream is used.
+
+With -i the list of steps is opened in $ORB_SEQUENCE_EDITOR (or
+sequence.editor, or the usual editor) first. Each line is a command:
+pick, reword, edit, squash, fixup, drop, or exec with a shell command.
+--autosquash (or rebase.autoSquash) moves commits whose subject starts
+with "fixup! " or "squash! " next to the commit they fix.
+
+A commit that doesn't apply stops the rebase with conflicts. Resolve them,
+"orb add" the files and run "orb rebase --continue"; --skip drops the
+commit instead, and --abort goes back to where the rebase started.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			modes := 0
+			for _, set := range []bool{cont, skip, abort} {
+				if set {
+					modes++
+				}
+			}
+			if modes > 1 {
+				return fmt.Errorf("only one of --continue, --skip and --abort can be used")
+			}
+			if modes == 1 {
+				if len(args) > 0 {
+					return fmt.Errorf("--continue, --skip and --abort take no arguments")
+				}
+				state, err := loadRebaseState()
+				if err != nil {
+					return err
+				}
+				switch {
+				case cont:
+					return state.resume()
+				case skip:
+					return state.skip()
+				default:
+					return state.abort()
+				}
+			}
+
+			if _, err := os.Stat(rebaseDir); err == nil {
+				return fmt.Errorf("a rebase is already in progress\n(try \"orb rebase --continue\", \"--skip\" or \"--abort\")")
+			}
+			if sequencerInProgress() {
+				return fmt.Errorf("a cherry-pick or revert is in progress; finish or abort it first")
+			}
+
+			if !cmd.Flags().Changed("autosquash") {
+				if cfg, err := config.LoadConfig(); err == nil {
+					autosquash = cfg.GetBool("rebase.autoSquash", false)
+				}
+			}
+
+			if len(args) == 2 {
+				if err := switchToBranch(args[1], false); err != nil {
+					return err
+				}
+			}
+
+			upstream := ""
+			if len(args) > 0 {
+				upstream = args[0]
+			} else {
+				branch := refs.GetCurrentBranch()
+				cfg, err := config.LoadConfig()
+				if err != nil {
+					return err
+				}
+				name, _, ok := branchUpstream(cfg, branch)
+				if branch == "" || !ok {
+					return fmt.Errorf("there is no tracking information for the current branch; give the upstream to rebase against")
+				}
+				upstream = name
+			}
+			return startRebase(upstream, onto, interactive, autosquash)
+		},
+	}
+
+	cmd.Flags().StringVar(&onto, "onto", "", "Replay the commits on top of this commit instead of <upstream>")
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Edit the list of steps before starting")
+	cmd.Flags().BoolVar(&autosquash, "autosquash", false, "Move fixup!/squash! commits next to the commits they fix")
+	cmd.Flags().BoolVar(&cont, "continue", false, "Commit the resolved conflict and carry on")
+	cmd.Flags().BoolVar(&skip, "skip", false, "Drop the commit that stopped and carry on")
+	cmd.Flags().BoolVar(&abort, "abort", false, "Stop and go back to where the rebase started")
+
+	return cmd
+}
+
+// resolveCommitArg resolves a revision argument to a commit
+func resolveCommitArg(rev string) (string, error) {
+	hash, err := revision.Resolve(rev)
+	if err != nil {
+		return "", fmt.Errorf("bad revision '%s': %w", rev, err)
+	}
+	return revision.PeelToCommit(hash)
+}
+
+// startRebase works out the steps, lets the user edit them with -i, then
+// detaches HEAD at the new base and replays them
+func startRebase(upstreamSpec, ontoSpec string, interactive, autosquash bool) error {
+	head, err := refs.GetHead()
+	if err != nil || head == "" {
+		return fmt.Errorf("cannot rebase a branch without commits")
+	}
+	upstream, err := resolveCommitArg(upstreamSpec)
+	if err != nil {
+		return err
+	}
+	onto, ontoName := upstream, upstreamSpec
+	if ontoSpec != "" {
+		if onto, err = resolveCommitArg(ontoSpec); err != nil {
+			return err
+		}
+		ontoName = ontoSpec
+	}
+
+	if err := checkCleanForRebase(); err != nil {
+		return err
+	}
+
+	// the commits to replay: what HEAD has that upstream doesn't, oldest
+	// first and without merges
+	walker := revision.NewWalker()
+	if err := walker.Hide(upstream); err != nil {
+		return err
+	}
+	if err := walker.Push(head); err != nil {
+		return err
+	}
+	var newestFirst []*revision.WalkCommit
+	for {
+		commit, err := walker.Next()
+		if err != nil {
+			return err
+		}
+		if commit == nil {
+			break
+		}
+		if len(commit.Commit.Parents) <= 1 {
+			newestFirst = append(newestFirst, commit)
+		}
+	}
+	var steps []rebaseStep
+	for i := len(newestFirst) - 1; i >= 0; i-- {
+		steps = append(steps, rebaseStep{command: "pick", hash: newestFirst[i].Hash, arg: newestFirst[i].Subject()})
+	}
+
+	branch := refs.GetCurrentBranch()
+	if !interactive && onto == upstream {
+		if upToDate, err := revision.IsAncestor(upstream, head); err != nil {
+			return err
+		} else if upToDate {
+			fmt.Printf("Current branch %s is up to date.\n", describeHead())
+			return nil
+		}
+	}
+
+	if autosquash {
+		steps = autosquashSteps(steps)
+	}
+
+	state := &rebaseState{origHead: head, onto: onto, todo: steps}
+	if branch != "" {
+		state.headName = "refs/heads/" + branch
+	}
+	if err := state.save(); err != nil {
+		return err
+	}
+
+	if interactive {
+		header := fmt.Sprintf("\n# Rebase %s..%s onto %s (%d commands)\n", shortHash(upstream), shortHash(head), shortHash(onto), len(steps))
+		if err := os.WriteFile(todoFile, []byte(formatTodo(steps)+header+todoHelp), 0644); err != nil {
+			os.RemoveAll(rebaseDir)
+			return fmt.Errorf("writing todo list: %w", err)
+		}
+		if err := runEditor(sequenceEditor(), todoFile); err != nil {
+			os.RemoveAll(rebaseDir)
+			return err
+		}
+		content, err := os.ReadFile(todoFile)
+		if err != nil {
+			os.RemoveAll(rebaseDir)
+			return fmt.Errorf("reading todo list: %w", err)
+		}
+		if state.todo, err = parseTodo(string(content)); err != nil {
+			os.RemoveAll(rebaseDir)
+			return err
+		}
+	}
+
+	// only an emptied todo list is nothing to do; with no commits to
+	// replay the branch still moves to onto, like a fast-forward
+	if interactive && len(state.todo) == 0 {
+		os.RemoveAll(rebaseDir)
+		return fmt.Errorf("nothing to do")
+	}
+	for _, step := range state.todo {
+		if step.command == "squash" || step.command == "fixup" {
+			os.RemoveAll(rebaseDir)
+			return fmt.Errorf("cannot '%s' without a previous commit", step.command)
+		}
+		if step.command != "exec" && step.command != "drop" {
+			break
+		}
+	}
+	if err := state.save(); err != nil {
+		return err
+	}
+
+	if err := refs.WritePseudoRef("ORIG_HEAD", head); err != nil {
+		return err
+	}
+	if err := moveWorkTree(onto, false); err != nil {
+		os.RemoveAll(rebaseDir)
+		return err
+	}
+	if err := refs.UpdateHead(onto, "rebase (start): checkout "+ontoName); err != nil {
+		return fmt.Errorf("detaching HEAD: %w", err)
+	}
+	return state.run()
+}
+
+// checkCleanForRebase refuses to start with staged or unstaged changes,
+// which replaying commits would mix in or throw away
+func checkCleanForRebase() error {
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("loading index: %w", err)
+	}
+	if idx.HasConflicts() {
+		return fmt.Errorf("you need to resolve your current index first:\n\t%s", strings.Join(idx.ConflictPaths(), "\n\t"))
+	}
+	if clean, err := indexMatchesHead(idx); err != nil {
+		return err
+	} else if !clean {
+		return fmt.Errorf("cannot rebase: your index contains uncommitted changes; please commit or stash them")
+	}
+	for _, entry := range idx.GetEntries() {
+		working, err := worktree.HashFile(entry.Path)
+		if err != nil {
+			return err
+		}
+		if working != entry.ObjectHash {
+			return fmt.Errorf("cannot rebase: you have unstaged changes; please commit or stash them")
+		}
+	}
+	return nil
+}
+
+// sequenceEditor picks the editor for the todo list: ORB_SEQUENCE_EDITOR,
+// then sequence.editor, then the editor used for messages
+func sequenceEditor() string {
+	if editor := os.Getenv("ORB_SEQUENCE_EDITOR"); editor != "" {
+		return editor
+	}
+	if cfg, err := config.LoadConfig(); err == nil {
+		if editor := cfg.Get("sequence.editor"); editor != "" {
+			return editor
+		}
+	}
+	return editorCommand()
+}
+
+// autosquashSteps moves each "fixup! <subject>" and "squash! <subject>"
+// commit right after the commit it names, by subject or hash, and turns it
+// into a fixup or squash step. Commits naming nothing earlier stay put.
+func autosquashSteps(steps []rebaseStep) []rebaseStep {
+	type group struct {
+		step    rebaseStep
+		follows []rebaseStep
+	}
+	var groups []*group
+
+	for _, step := range steps {
+		command, target := "", step.arg
+		for {
+			if rest, ok := strings.CutPrefix(target, "fixup! "); ok {
+				target = rest
+				if command == "" {
+					command = "fixup"
+				}
+				continue
+			}
+			if rest, ok := strings.CutPrefix(target, "squash! "); ok {
+				target = rest
+				if command == "" {
+					command = "squash"
+				}
+				continue
+			}
+			break
+		}
+
+		var found *group
+		if command != "" {
+			for _, g := range groups {
+				if g.step.arg == target {
+					found = g
+					break
+				}
+			}
+			if found == nil {
+				for _, g := range groups {
+					if (len(target) >= 4 && strings.HasPrefix(g.step.hash, target)) || strings.HasPrefix(g.step.arg, target) {
+						found = g
+						break
+					}
+				}
+			}
+		}
+		if found == nil {
+			groups = append(groups, &group{step: step})
+			continue
+		}
+		step.command = command
+		found.follows = append(found.follows, step)
+	}
+
+	var sorted []rebaseStep
+	for _, g := range groups {
+		sorted = append(sorted, g.step)
+		sorted = append(sorted, g.follows...)
+	}
+	return sorted
+}
+
+// formatTodo writes steps as todo lines, e.g. "pick a1b2c3d subject"
+func formatTodo(steps []rebaseStep) string {
+	var b strings.Builder
+	for _, step := range steps {
+		if step.command == "exec" {
+			fmt.Fprintf(&b, "exec %s\n", step.arg)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", step.command, shortHash(step.hash), step.arg)
+	}
+	return b.String()
+}
+
+// parseTodo reads todo lines back, skipping comments and blank lines
+func parseTodo(content string) ([]rebaseStep, error) {
+	var steps []rebaseStep
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		word, rest, _ := strings.Cut(line, " ")
+		command, ok := rebaseCommands[word]
+		if !ok {
+			return nil, fmt.Errorf("invalid line in the todo list: %s", line)
+		}
+		rest = strings.TrimSpace(rest)
+		if command == "exec" {
+			if rest == "" {
+				return nil, fmt.Errorf("missing command after 'exec'")
+			}
+			steps = append(steps, rebaseStep{command: command, arg: rest})
+			continue
+		}
+
+		name, subject, _ := strings.Cut(rest, " ")
+		hash, err := resolveCommitArg(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line in the todo list: %s: %w", line, err)
+		}
+		steps = append(steps, rebaseStep{command: command, hash: hash, arg: strings.TrimSpace(subject)})
+	}
+	return steps, nil
+}
+
+// loadRebaseState reads the state of the rebase in progress
+func loadRebaseState() (*rebaseState, error) {
+	read := func(name string) string {
+		content, _ := os.ReadFile(filepath.Join(rebaseDir, name))
+		return strings.TrimRight(string(content), "\n")
+	}
+
+	if _, err := os.Stat(rebaseDir); err != nil {
+		return nil, fmt.Errorf("no rebase in progress")
+	}
+	state := &rebaseState{
+		headName: read("head-name"),
+		origHead: read("orig-head"),
+		onto:     read("onto"),
+	}
+	if state.headName == "detached HEAD" {
+		state.headName = ""
+	}
+
+	var err error
+	if state.todo, err = parseTodo(read("git-rebase-todo")); err != nil {
+		return nil, err
+	}
+	if state.done, err = parseTodo(read("done")); err != nil {
+		return nil, err
+	}
+	if stopped := read("stopped"); stopped != "" {
+		reason, line, _ := strings.Cut(stopped, " ")
+		steps, err := parseTodo(line)
+		if err != nil || len(steps) != 1 {
+			return nil, fmt.Errorf("corrupt rebase state: %s", stopped)
+		}
+		state.stopped, state.stoppedReason = steps[0], reason
+	}
+	return state, nil
+}
+
+// save writes the rebase state to disk
+func (r *rebaseState) save() error {
+	if err := os.MkdirAll(rebaseDir, 0755); err != nil {
+		return fmt.Errorf("creating rebase directory: %w", err)
+	}
+
+	headName := r.headName
+	if headName == "" {
+		headName = "detached HEAD"
+	}
+	stopped := ""
+	if r.stoppedReason != "" {
+		stopped = r.stoppedReason + " " + formatTodo([]rebaseStep{r.stopped})
+	}
+
+	files := map[string]string{
+		"head-name":       headName + "\n",
+		"orig-head":       r.origHead + "\n",
+		"onto":            r.onto + "\n",
+		"git-rebase-todo": formatTodo(r.todo),
+		"done":            formatTodo(r.done),
+		"stopped":         stopped,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(rebaseDir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing rebase state: %w", err)
+		}
+	}
+	return nil
+}
+
+// clearStop forgets why the rebase stopped
+func (r *rebaseState) clearStop() error {
+	r.stopped, r.stoppedReason = rebaseStep{}, ""
+	if err := refs.DeletePseudoRef("REBASE_HEAD"); err != nil {
+		return err
+	}
+	os.Remove(mergeMsgFile)
+	return r.save()
+}
+
+// run carries out the steps left in the todo list, stopping at conflicts,
+// edit steps and failed exec steps
+func (r *rebaseState) run() error {
+	for len(r.todo) > 0 {
+		step := r.todo[0]
+		r.todo = r.todo[1:]
+		r.done = append(r.done, step)
+		if err := r.save(); err != nil {
+			return err
+		}
+
+		switch step.command {
+		case "drop":
+			continue
+		case "exec":
+			fmt.Printf("Executing: %s\n", step.arg)
+			command := exec.Command("sh", "-c", step.arg)
+			command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+			if err := command.Run(); err != nil {
+				r.stopped, r.stoppedReason = step, "exec"
+				if err := r.save(); err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "warning: execution failed: %s\n", step.arg)
+				fmt.Fprintln(os.Stderr, "You can fix the problem, and then run")
+				fmt.Fprintln(os.Stderr, "\n  orb rebase --continue")
+				os.Exit(1)
+			}
+			continue
+		}
+
+		stopped, err := r.pick(step)
+		if err != nil || stopped {
+			return err
+		}
+	}
+	return r.finish()
+}
+
+// pick applies one commit step on top of HEAD. It reports whether the
+// rebase stopped for an edit.
+func (r *rebaseState) pick(step rebaseStep) (bool, error) {
+	commit, err := objects.ReadCommit(step.hash)
+	if err != nil {
+		return false, err
+	}
+	head, err := refs.GetHead()
+	if err != nil {
+		return false, err
+	}
+
+	// a commit already sitting on HEAD is reused as it is
+	if (step.command == "pick" || step.command == "edit") && len(commit.Parents) == 1 && commit.Parents[0] == head {
+		if err := moveWorkTree(step.hash, false); err != nil {
+			return false, err
+		}
+		if err := refs.UpdateHead(step.hash, "rebase (pick): "+commit.Subject()); err != nil {
+			return false, fmt.Errorf("updating HEAD: %w", err)
+		}
+		if step.command == "edit" {
+			return true, r.stopForEdit(step)
+		}
+		return false, nil
+	}
+
+	replayed, err := replayCommit(actionPick, step.hash, 0, false)
+	if err != nil {
+		return false, err
+	}
+	if !replayed.result.Clean() {
+		if err := r.stopForConflict(step, replayed.message); err != nil {
+			return true, err
+		}
+		os.Exit(1)
+	}
+	if replayed.empty && step.command != "squash" {
+		if step.command != "fixup" {
+			fmt.Printf("dropping %s %s -- patch contents already upstream\n", shortHash(step.hash), commit.Subject())
+		}
+		return false, nil
+	}
+	return r.commitStep(step, replayed.message, replayed.author)
+}
+
+// commitStep records the change a step brought into the index: as a new
+// commit, or folded into HEAD for squash and fixup. It reports whether
+// the rebase stopped for an edit.
+func (r *rebaseState) commitStep(step rebaseStep, message string, author *objects.Signature) (bool, error) {
+	switch step.command {
+	case "squash", "fixup":
+		return false, amendRebaseHead(step.command, message)
+	case "reword":
+		edited, err := editMessage(commitEditMsgFile, message+"\n\n"+
+			"# Please enter the commit message for your changes. Lines starting\n"+
+			"# with '#' will be ignored, and an empty message aborts the commit.\n")
+		if err != nil {
+			return false, err
+		}
+		if message = cleanupMessage(edited, true); message == "" {
+			return false, fmt.Errorf("aborting commit due to empty commit message")
+		}
+	}
+
+	if _, err := commitReplayed(message, author, "rebase ("+step.command+"): "+firstLine(message)); err != nil {
+		return false, err
+	}
+	if step.command == "edit" {
+		return true, r.stopForEdit(step)
+	}
+	return false, nil
+}
+
+// amendRebaseHead folds the index into HEAD's commit. A fixup keeps
+// HEAD's message; a squash adds this commit's message and lets the user
+// edit the result.
+func amendRebaseHead(command, message string) error {
+	head, err := refs.GetHead()
+	if err != nil {
+		return err
+	}
+	headCommit, err := objects.ReadCommit(head)
+	if err != nil {
+		return err
+	}
+
+	combined := strings.TrimRight(headCommit.Message, "\n")
+	if command == "squash" {
+		if added := squashMessage(message); added != "" {
+			combined += "\n\n" + added
+		}
+		edited, err := editMessage(commitEditMsgFile, "# This is a combination of commits.\n"+combined+"\n\n"+
+			"# Please enter the commit message for your changes. Lines starting\n"+
+			"# with '#' will be ignored, and an empty message aborts the commit.\n")
+		if err != nil {
+			return err
+		}
+		if combined = cleanupMessage(edited, true); combined == "" {
+			return fmt.Errorf("aborting commit due to empty commit message")
+		}
+	}
+
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("loading index: %w", err)
+	}
+	tree, err := objects.WriteObject(objects.TreeType, buildTreeContent(idx))
+	if err != nil {
+		return fmt.Errorf("writing tree object: %w", err)
+	}
+	committer, err := ident.Committer()
+	if err != nil {
+		return err
+	}
+
+	hash, err := objects.WriteCommit(&objects.Commit{
+		Tree:      tree,
+		Parents:   headCommit.Parents,
+		Author:    headCommit.Author,
+		Committer: committer,
+		Message:   combined + "\n",
+	})
+	if err != nil {
+		return fmt.Errorf("writing commit object: %w", err)
+	}
+
+	tx := refs.NewTransaction()
+	tx.Update("HEAD", hash, head, "rebase ("+command+"): "+firstLine(combined))
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("updating HEAD: %w", err)
+	}
+	fmt.Printf("[%s] %s\n", hash[:7], firstLine(combined))
+	return nil
+}
+
+// squashMessage is the part of a squashed commit's message worth keeping:
+// a "squash! <subject>" line only says where the commit belongs
+func squashMessage(message string) string {
+	message = strings.TrimSpace(message)
+	if strings.HasPrefix(message, "squash! ") || strings.HasPrefix(message, "fixup! ") {
+		_, body, _ := strings.Cut(message, "\n")
+		return strings.TrimSpace(body)
+	}
+	return message
+}
+
+// stopForConflict leaves a step that didn't apply cleanly to the user. The
+// caller exits once it's recorded
+func (r *rebaseState) stopForConflict(step rebaseStep, message string) error {
+	r.stopped, r.stoppedReason = step, "conflict"
+	if err := r.save(); err != nil {
+		return err
+	}
+	if err := refs.WritePseudoRef("REBASE_HEAD", step.hash); err != nil {
+		return err
+	}
+	if err := os.WriteFile(mergeMsgFile, []byte(message+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", mergeMsgFile, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "error: could not apply %s... %s\n", shortHash(step.hash), step.arg)
+	fmt.Fprintln(os.Stderr, "hint: Resolve all conflicts manually, mark them as resolved with")
+	fmt.Fprintln(os.Stderr, "hint: \"orb add/rm <conflicted_files>\", then run \"orb rebase --continue\".")
+	fmt.Fprintln(os.Stderr, "hint: You can instead skip this commit: run \"orb rebase --skip\".")
+	fmt.Fprintln(os.Stderr, "hint: To abort and get back to the state before \"orb rebase\", run \"orb rebase --abort\".")
+	return nil
+}
+
+// stopForEdit hands the freshly made commit of an edit step to the user
+func (r *rebaseState) stopForEdit(step rebaseStep) error {
+	r.stopped, r.stoppedReason = step, "edit"
+	if err := r.save(); err != nil {
+		return err
+	}
+	if err := refs.WritePseudoRef("REBASE_HEAD", step.hash); err != nil {
+		return err
+	}
+
+	fmt.Printf("Stopped at %s...  %s\n", shortHash(step.hash), step.arg)
+	fmt.Println("You can amend the commit now, with")
+	fmt.Println("\n  orb commit --amend")
+	fmt.Println("\nOnce you are satisfied with your changes, run")
+	fmt.Println("\n  orb rebase --continue")
+	return nil
+}
+
+// resume records whatever the user did where the rebase stopped and
+// carries on with the rest
+func (r *rebaseState) resume() error {
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("loading index: %w", err)
+	}
+	if idx.HasConflicts() {
+		return fmt.Errorf("you need to resolve your current index first:\n\t%s", strings.Join(idx.ConflictPaths(), "\n\t"))
+	}
+	unchanged, err := indexMatchesHead(idx)
+	if err != nil {
+		return err
+	}
+
+	step, reason := r.stopped, r.stoppedReason
+	message := step.arg
+	if content, err := os.ReadFile(mergeMsgFile); err == nil {
+		message = cleanupMessage(string(content), true)
+	}
+	if err := r.clearStop(); err != nil {
+		return err
+	}
+
+	switch reason {
+	case "conflict":
+		// resolving a conflict to nothing drops the commit, unless it was
+		// already committed by hand
+		if unchanged && step.command != "squash" {
+			break
+		}
+		var author *objects.Signature
+		if commit, err := objects.ReadCommit(step.hash); err == nil {
+			author = &commit.Author
+		}
+		if stopped, err := r.commitStep(step, message, author); err != nil || stopped {
+			return err
+		}
+	case "edit":
+		// staged changes go into the commit that was stopped at
+		if !unchanged {
+			if err := amendRebaseHead("fixup", ""); err != nil {
+				return err
+			}
+		}
+	}
+	return r.run()
+}
+
+// skip throws away the step the rebase stopped at and carries on
+func (r *rebaseState) skip() error {
+	head, err := refs.GetHead()
+	if err != nil {
+		return err
+	}
+	if err := moveWorkTree(head, true); err != nil {
+		return err
+	}
+	if err := r.clearStop(); err != nil {
+		return err
+	}
+	return r.run()
+}
+
+// abort goes back to the branch and commit the rebase started from
+func (r *rebaseState) abort() error {
+	if err := moveWorkTree(r.origHead, true); err != nil {
+		return err
+	}
+
+	target := r.origHead
+	if r.headName != "" {
+		target = r.headName
+	}
+	if err := refs.UpdateHead(target, "rebase (abort): returning to "+describeRebaseHead(r.headName)); err != nil {
+		return fmt.Errorf("updating HEAD: %w", err)
+	}
+	return r.remove()
+}
+
+// finish moves the branch to the rebased commits and checks it out again
+func (r *rebaseState) finish() error {
+	head, err := refs.GetHead()
+	if err != nil {
+		return err
+	}
+
+	if r.headName != "" {
+		tx := refs.NewTransaction()
+		tx.Update(r.headName, head, r.origHead, fmt.Sprintf("rebase (finish): %s onto %s", r.headName, r.onto))
+		tx.SetSymbolic("HEAD", r.headName, "rebase (finish): returning to "+r.headName)
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("updating %s: %w", r.headName, err)
+		}
+	}
+	if err := r.remove(); err != nil {
+		return err
+	}
+	fmt.Printf("Successfully rebased and updated %s.\n", describeRebaseHead(r.headName))
+	return nil
+}
+
+// remove deletes the rebase state
+func (r *rebaseState) remove() error {
+	if err := refs.DeletePseudoRef("REBASE_HEAD"); err != nil {
+		return err
+	}
+	os.Remove(mergeMsgFile)
+	if err := os.RemoveAll(rebaseDir); err != nil {
+		return fmt.Errorf("removing rebase state: %w", err)
+	}
+	return nil
+}
+
+// describeRebaseHead names what is being rebased in messages
+func describeRebaseHead(headName string) string {
+	if headName == "" {
+		return "detached HEAD"
+	}
+	return headName
+}
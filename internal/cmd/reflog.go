@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+func newReflogCommand() *cobra.Command {
+	var maxCount int
+
+	cmd := &cobra.Command{
+		Use:   "reflog [ref]",
+		Short: "Show where HEAD and branches have pointed to",
+		Long: `Show the reflog of a ref (HEAD by default), newest entry first.
+Entries can be used as revisions, e.g. "orb checkout HEAD@{2}" or "main@{yesterday}".`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			display := "HEAD"
+			if len(args) > 0 {
+				display = args[0]
+			}
+
+			// work out which log file the user means
+			name := "HEAD"
+			if display != "HEAD" && display != "@" {
+				full, err := refs.ExpandRef(display)
+				if err != nil {
+					return fmt.Errorf("unknown ref '%s'", display)
+				}
+				name = full
+			}
+
+			entries, err := refs.ReadReflog(name)
+			if err != nil {
+				return fmt.Errorf("reading reflog: %w", err)
+			}
+
+			// newest first, numbered the same way @{n} counts
+			shown := 0
+			for i := len(entries) - 1; i >= 0; i-- {
+				if maxCount > 0 && shown >= maxCount {
+					break
+				}
+				entry := entries[i]
+				fmt.Printf("%s %s@{%d}: %s\n", shortHash(entry.NewHash), display, len(entries)-1-i, entry.Message)
+				shown++
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&maxCount, "max-count", "n", 0, "Limit the number of entries shown")
+
+	return cmd
+}
+
+// shortHash abbreviates a hash for display
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
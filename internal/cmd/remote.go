@@ -43,17 +43,14 @@ func newRemoteAddCommand() *cobra.Command {
 			}
 
 			// Check if remote already exists
-			for _, remote := range cfg.Remotes {
+			for _, remote := range cfg.Remotes() {
 				if remote.Name == name {
 					return fmt.Errorf("remote %s already exists", name)
 				}
 			}
 
 			// Add new remote
-			cfg.Remotes = append(cfg.Remotes, transport.RemoteConfig{
-				Name: name,
-				URL:  url,
-			})
+			cfg.AddRemote(name, url)
 
 			if err := config.SaveConfig(cfg); err != nil {
 				return fmt.Errorf("failed to save config: %w", err)
@@ -79,23 +76,10 @@ func newRemoteRemoveCommand() *cobra.Command {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			found := false
-			newRemotes := []transport.RemoteConfig{}
-
-			for _, remote := range cfg.Remotes {
-				if remote.Name != name {
-					newRemotes = append(newRemotes, remote)
-				} else {
-					found = true
-				}
-			}
-
-			if !found {
+			if !cfg.RemoveRemote(name) {
 				return fmt.Errorf("remote '%s' not found", name)
 			}
 
-			cfg.Remotes = newRemotes
-
 			if err := config.SaveConfig(cfg); err != nil {
 				return fmt.Errorf("failed to save config: %w", err)
 			}
@@ -117,13 +101,14 @@ func newRemoteListCommand() *cobra.Command {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			if len(cfg.Remotes) == 0 {
+			remotes := cfg.Remotes()
+			if len(remotes) == 0 {
 				fmt.Println("No remotes configured")
 				return nil
 			}
 
 			fmt.Println("Configured remotes:")
-			for _, remote := range cfg.Remotes {
+			for _, remote := range remotes {
 				fmt.Printf("%s\t%s\n", remote.Name, remote.URL)
 			}
 			return nil
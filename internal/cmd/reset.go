@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/ayushsarode/orb/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+func newResetCommand() *cobra.Command {
+	var (
+		soft  bool
+		mixed bool
+		hard  bool
+		quiet bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reset [--soft | --mixed | --hard] [<commit>] | reset [<tree-ish>] [--] <path>...",
+		Short: "Reset the current branch or unstage files",
+		Long: `Move the current branch (or a detached HEAD) to another commit:
+
+  --soft   only moves the branch; the index and files stay as they are
+  --mixed  also resets the index to the commit, keeping the files (default)
+  --hard   also resets the index and tracked files, throwing changes away
+
+The old position is saved as ORIG_HEAD, so "orb reset ORIG_HEAD" undoes
+the move.
+
+With paths, the branch doesn't move: the index entries of those paths are
+reset to the commit (HEAD by default), which unstages them.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			modes := 0
+			for _, set := range []bool{soft, mixed, hard} {
+				if set {
+					modes++
+				}
+			}
+			if modes > 1 {
+				return fmt.Errorf("only one of --soft, --mixed and --hard can be used")
+			}
+
+			rev, paths, err := splitResetArgs(cmd, args)
+			if err != nil {
+				return err
+			}
+
+			if len(paths) > 0 {
+				if soft || hard {
+					return fmt.Errorf("cannot do a soft or hard reset with paths")
+				}
+				return resetPaths(rev, cleanPathspecs(paths), quiet)
+			}
+
+			mode := "mixed"
+			if soft {
+				mode = "soft"
+			} else if hard {
+				mode = "hard"
+			}
+			return resetHead(rev, mode, quiet)
+		},
+	}
+
+	cmd.Flags().BoolVar(&soft, "soft", false, "Only move the branch")
+	cmd.Flags().BoolVar(&mixed, "mixed", false, "Move the branch and reset the index (default)")
+	cmd.Flags().BoolVar(&hard, "hard", false, "Move the branch and reset the index and working tree")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only report errors")
+
+	return cmd
+}
+
+// splitResetArgs separates the revision from the paths. Without "--" the
+// first argument is a revision only if it resolves to one.
+func splitResetArgs(cmd *cobra.Command, args []string) (string, []string, error) {
+	rev, paths := "HEAD", args
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		if dash > 1 {
+			return "", nil, fmt.Errorf("only one revision can be given before '--'")
+		}
+		if dash == 1 {
+			rev = args[0]
+		}
+		return rev, args[dash:], nil
+	}
+
+	if len(args) > 0 {
+		if _, err := revision.Resolve(args[0]); err == nil {
+			rev, paths = args[0], args[1:]
+		}
+	}
+	return rev, paths, nil
+}
+
+// resetTarget resolves what reset moves to: a commit and its tree. HEAD on
+// a branch without commits yet stands for the empty tree.
+func resetTarget(rev string) (string, string, error) {
+	hash, err := revision.Resolve(rev)
+	if err != nil {
+		if rev == "HEAD" && headIsUnborn() {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("bad revision '%s': %w", rev, err)
+	}
+
+	commit, err := revision.PeelToCommit(hash)
+	if err != nil {
+		return "", "", err
+	}
+	tree, err := revision.PeelToTree(commit)
+	if err != nil {
+		return "", "", err
+	}
+	return commit, tree, nil
+}
+
+// headIsUnborn reports whether HEAD is on a branch without commits yet
+func headIsUnborn() bool {
+	_, err := refs.GetHead()
+	return err != nil
+}
+
+// resetPaths copies the index entries of some paths from a commit's tree,
+// dropping the ones the tree doesn't have
+func resetPaths(rev string, paths []string, quiet bool) error {
+	tree := ""
+	hash, err := revision.ResolveObject(rev)
+	switch {
+	case err == nil:
+		if tree, err = revision.PeelToTree(hash); err != nil {
+			return err
+		}
+	case rev == "HEAD" && headIsUnborn():
+		// nothing committed yet, so unstaging drops the entries
+	default:
+		return fmt.Errorf("bad revision '%s': %w", rev, err)
+	}
+
+	files, err := worktree.Files(tree)
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("loading index: %w", err)
+	}
+
+	for path := range idx.Entries {
+		if _, ok := files[path]; !ok && matchesPathspec(path, paths) {
+			idx.Remove(path)
+		}
+	}
+	for path, hash := range files {
+		if matchesPathspec(path, paths) {
+			idx.SetEntry(path, hash)
+		}
+	}
+
+	if err := idx.Write(); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+	if !quiet {
+		return printUnstagedChanges(idx)
+	}
+	return nil
+}
+
+// resetHead moves HEAD's branch to a commit, then brings the index and,
+// for a hard reset, the working tree along
+func resetHead(rev, mode string, quiet bool) error {
+	commit, tree, err := resetTarget(rev)
+	if err != nil {
+		return err
+	}
+
+	oldHead, _ := refs.GetHead()
+	oldTree := ""
+	if oldHead != "" {
+		if oldTree, err = revision.PeelToTree(oldHead); err != nil {
+			return err
+		}
+	}
+
+	if mode != "soft" {
+		idx, err := index.LoadIndex()
+		if err != nil {
+			return fmt.Errorf("loading index: %w", err)
+		}
+		files, err := worktree.Files(tree)
+		if err != nil {
+			return err
+		}
+
+		if mode == "hard" {
+			oldFiles, err := worktree.Files(oldTree)
+			if err != nil {
+				return err
+			}
+			if err := worktree.Checkout(idx, oldFiles, files, true); err != nil {
+				return err
+			}
+		} else {
+			worktree.ResetIndex(idx, files)
+		}
+
+		if err := idx.Write(); err != nil {
+			return fmt.Errorf("writing index: %w", err)
+		}
+	}
+
+	// resetting an unborn branch to itself leaves nothing to move
+	if commit != "" {
+		if oldHead != "" {
+			if err := refs.WritePseudoRef("ORIG_HEAD", oldHead); err != nil {
+				return err
+			}
+		}
+		branchRef, _, _ := refs.ResolveRef("HEAD")
+		tx := refs.NewTransaction()
+		tx.Update(branchRef, commit, "", "reset: moving to "+rev)
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("updating HEAD: %w", err)
+		}
+	}
+
+	if quiet {
+		return nil
+	}
+	switch mode {
+	case "hard":
+		if commit != "" {
+			c, err := objects.ReadCommit(commit)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("HEAD is now at %s %s\n", shortHash(commit), c.Subject())
+		}
+	case "mixed":
+		idx, err := index.LoadIndex()
+		if err != nil {
+			return fmt.Errorf("loading index: %w", err)
+		}
+		return printUnstagedChanges(idx)
+	}
+	return nil
+}
+
+// printUnstagedChanges lists the tracked files that differ from the index
+func printUnstagedChanges(idx *index.Index) error {
+	header := false
+	for _, entry := range idx.GetEntries() {
+		working, err := worktree.HashFile(entry.Path)
+		if err != nil {
+			return err
+		}
+		if working == entry.ObjectHash {
+			continue
+		}
+
+		if !header {
+			fmt.Println("Unstaged changes after reset:")
+			header = true
+		}
+		status := "M"
+		if working == "" {
+			status = "D"
+		}
+		fmt.Printf("%s\t%s\n", status, entry.Path)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/ayushsarode/orb/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+func newRestoreCommand() *cobra.Command {
+	var (
+		source  string
+		staged  bool
+		working bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restore [--source=<rev>] [--staged] [--worktree] <pathspec>...",
+		Short: "Restore working tree files or unstage them",
+		Long: `Put files back the way they are in the index or in a commit.
+
+By default the working tree is restored from the index, discarding
+unstaged changes. --staged restores the index from HEAD instead, which
+unstages changes; give both --staged and --worktree to do both. --source
+takes the files from any commit or tree. Tracked files the source doesn't
+have are removed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !staged && !working {
+				working = true
+			}
+			// the index is restored from HEAD unless told otherwise
+			if source == "" && staged {
+				source = "HEAD"
+			}
+			return restorePaths(source, cleanPathspecs(args), staged, working, false)
+		},
+	}
+
+	cmd.Flags().StringVarP(&source, "source", "s", "", "Restore from this commit or tree instead of the index")
+	cmd.Flags().BoolVarP(&staged, "staged", "S", false, "Restore the index")
+	cmd.Flags().BoolVarP(&working, "worktree", "W", false, "Restore the working tree (default)")
+
+	return cmd
+}
+
+// restorePaths copies the files matching paths from a source into the
+// index, the working tree or both. An empty source is the index itself.
+// Matching tracked files the source doesn't have are removed, unless
+// overlay is set, in which case they are left alone as checkout does.
+func restorePaths(source string, paths []string, staged, working, overlay bool) error {
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("loading index: %w", err)
+	}
+	tracked := worktree.IndexFiles(idx)
+
+	var files map[string]string
+	if source == "" {
+		files = tracked
+	} else {
+		tree := ""
+		hash, err := revision.ResolveObject(source)
+		switch {
+		case err == nil:
+			if tree, err = revision.PeelToTree(hash); err != nil {
+				return err
+			}
+		case source == "HEAD" && headIsUnborn():
+			// nothing committed yet: the source is empty
+		default:
+			return fmt.Errorf("invalid source '%s': %w", source, err)
+		}
+		if files, err = worktree.Files(tree); err != nil {
+			return err
+		}
+	}
+
+	// every pathspec has to name something, like git insists
+	for _, spec := range paths {
+		known := false
+		for _, candidates := range []map[string]string{files, tracked} {
+			for path := range candidates {
+				if matchesPathspec(path, []string{spec}) {
+					known = true
+					break
+				}
+			}
+		}
+		if !known {
+			return fmt.Errorf("pathspec '%s' did not match any file(s) known to orb", spec)
+		}
+	}
+
+	if working {
+		for path, hash := range files {
+			if !matchesPathspec(path, paths) {
+				continue
+			}
+			current, err := worktree.HashFile(path)
+			if err != nil {
+				return err
+			}
+			if current != hash {
+				if err := worktree.WriteFile(path, hash); err != nil {
+					return err
+				}
+			}
+		}
+		if !overlay {
+			for path := range tracked {
+				if _, ok := files[path]; !ok && matchesPathspec(path, paths) {
+					if err := worktree.RemoveFile(path); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	if staged {
+		for path := range tracked {
+			if _, ok := files[path]; !ok && !overlay && matchesPathspec(path, paths) {
+				idx.Remove(path)
+			}
+		}
+		for path, hash := range files {
+			if matchesPathspec(path, paths) {
+				idx.SetEntry(path, hash)
+			}
+		}
+		if err := idx.Write(); err != nil {
+			return fmt.Errorf("writing index: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newRevertCommand() *cobra.Command {
+	return newSequencerCommand(actionRevert,
+		"revert [-m <parent>] <commit>... | revert (--continue | --skip | --abort)",
+		"Undo the changes of existing commits",
+		`Record new commits that undo the change each given commit introduced.
+Reverting a merge needs -m to say which parent's side is kept.
+
+A revert that clashes with later changes stops with conflicts. Resolve
+them, "orb add" the files and run "orb revert --continue"; --skip drops the
+commit instead, and --abort goes back to where the revert started.`)
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+func newRmCommand() *cobra.Command {
+	var (
+		cached    bool
+		recursive bool
+		force     bool
+		quiet     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rm [--cached] [-r] [-f] <pathspec>...",
+		Short: "Remove files from the working tree and from the index",
+		Long: `Stop tracking files: remove them from the index and delete them from the
+working tree. With --cached the files stay on disk and only leave the
+index. A directory needs -r.
+
+Files whose content isn't safely recorded are refused: a file with
+changes that aren't staged, or with staged changes that aren't committed
+(unless --cached keeps the file). -f removes them anyway.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			idx, err := index.LoadIndex()
+			if err != nil {
+				return fmt.Errorf("loading index: %w", err)
+			}
+
+			paths, err := matchIndexPaths(idx, cleanPathspecs(args), recursive)
+			if err != nil {
+				return err
+			}
+
+			if !force {
+				head, err := headFiles()
+				if err != nil {
+					return err
+				}
+				if err := checkRemovable(idx, head, paths, cached); err != nil {
+					return err
+				}
+			}
+
+			for _, path := range paths {
+				if !quiet {
+					fmt.Printf("rm '%s'\n", path)
+				}
+				idx.Remove(path)
+				if !cached {
+					if err := worktree.RemoveFile(path); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := idx.Write(); err != nil {
+				return fmt.Errorf("writing index: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&cached, "cached", false, "Only remove from the index, keeping the files")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Allow removing directories")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Remove files even with uncommitted changes")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Don't list the removed files")
+
+	return cmd
+}
+
+// matchIndexPaths returns the tracked files each pathspec names. Every
+// pathspec must name something, and a directory only counts with recursive.
+func matchIndexPaths(idx *index.Index, specs []string, recursive bool) ([]string, error) {
+	matched := make(map[string]bool)
+	for _, spec := range specs {
+		found := false
+		for path := range idx.Entries {
+			if !matchesPathspec(path, []string{spec}) {
+				continue
+			}
+			if path != spec && !recursive {
+				return nil, fmt.Errorf("not removing '%s' recursively without -r", spec)
+			}
+			matched[path] = true
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("pathspec '%s' did not match any files", spec)
+		}
+	}
+
+	paths := make([]string, 0, len(matched))
+	for path := range matched {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// checkRemovable refuses paths whose content would be lost: the working
+// file differs from the index, or the index differs from HEAD. With
+// cached the file stays, so only a file that matches neither is refused.
+func checkRemovable(idx *index.Index, head map[string]string, paths []string, cached bool) error {
+	var both, staged, local []string
+	for _, path := range paths {
+		stagedHash := idx.Entries[path].ObjectHash
+		working, err := worktree.HashFile(path)
+		if err != nil {
+			return err
+		}
+
+		// a file already deleted from disk has nothing left to lose
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			working = stagedHash
+		}
+
+		differsFromHead := head[path] != stagedHash
+		differsFromFile := working != stagedHash
+		switch {
+		case differsFromHead && differsFromFile:
+			both = append(both, path)
+		case cached:
+		case differsFromHead:
+			staged = append(staged, path)
+		case differsFromFile:
+			local = append(local, path)
+		}
+	}
+
+	report := func(files []string, problem, hint string) error {
+		subject := "file has"
+		if len(files) > 1 {
+			subject = "files have"
+		}
+		msg := fmt.Sprintf("the following %s %s:", subject, problem)
+		for _, file := range files {
+			msg += "\n    " + file
+		}
+		return fmt.Errorf("%s\n(%s)", msg, hint)
+	}
+	switch {
+	case len(both) > 0:
+		return report(both, "staged content different from both the file and the HEAD", "use -f to force removal")
+	case len(staged) > 0:
+		return report(staged, "changes staged in the index", "use --cached to keep the file, or -f to force removal")
+	case len(local) > 0:
+		return report(local, "local modifications", "use --cached to keep the file, or -f to force removal")
+	}
+	return nil
+}
@@ -15,9 +15,31 @@ func NewRootCommnad() *cobra.Command {
 	rootCmd.AddCommand(newCommitCommand())
 	rootCmd.AddCommand(newLogCommand())
 	rootCmd.AddCommand(newStatusCommand())
+	rootCmd.AddCommand(newDiffCommand())
 	rootCmd.AddCommand(newConfigCommand())
 	rootCmd.AddCommand(newBranchCommand())
 	rootCmd.AddCommand(newCheckoutCommand())
+	rootCmd.AddCommand(newReflogCommand())
+	rootCmd.AddCommand(newCheckRefFormatCommand())
+	rootCmd.AddCommand(newSymbolicRefCommand())
+	rootCmd.AddCommand(newShowCommand())
+	rootCmd.AddCommand(newCatFileCommand())
+	rootCmd.AddCommand(newLsTreeCommand())
+	rootCmd.AddCommand(newHashObjectCommand())
+	rootCmd.AddCommand(newWriteTreeCommand())
+	rootCmd.AddCommand(newCommitTreeCommand())
+	rootCmd.AddCommand(newUpdateRefCommand())
+	rootCmd.AddCommand(newResetCommand())
+	rootCmd.AddCommand(newRestoreCommand())
+	rootCmd.AddCommand(newSwitchCommand())
+	rootCmd.AddCommand(newRmCommand())
+	rootCmd.AddCommand(newMvCommand())
+	rootCmd.AddCommand(newStashCommand())
+	rootCmd.AddCommand(newCherryPickCommand())
+	rootCmd.AddCommand(newRevertCommand())
+	rootCmd.AddCommand(newRebaseCommand())
+	rootCmd.AddCommand(newBlameCommand())
+	rootCmd.AddCommand(newBisectCommand())
 
 	// Add remote repository commands
 	rootCmd.AddCommand(newRemoteCommand())
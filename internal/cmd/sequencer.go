@@ -0,0 +1,561 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/ident"
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/merge"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/ayushsarode/orb/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+// sequencerDir holds the state of a cherry-pick or revert of several
+// commits, so that it can carry on after stopping at a conflict
+const sequencerDir = ".orb/sequencer"
+
+// mergeMsgFile holds the message of a commit that stopped at a conflict
+const mergeMsgFile = ".orb/MERGE_MSG"
+
+// actions the sequencer knows
+const (
+	actionPick   = "cherry-pick"
+	actionRevert = "revert"
+)
+
+// sequencer is a cherry-pick or revert in progress
+type sequencer struct {
+	action       string
+	head         string   // HEAD when it started, where --abort goes back to
+	todo         []string // commits not applied yet, in order
+	recordOrigin bool     // -x
+	mainline     int      // -m; which parent of a merge the change is against
+}
+
+// pickHead is the pseudo-ref naming the commit that stopped at a conflict
+func (s *sequencer) pickHead() string {
+	if s.action == actionRevert {
+		return "REVERT_HEAD"
+	}
+	return "CHERRY_PICK_HEAD"
+}
+
+func newSequencerCommand(action, use, short, long string) *cobra.Command {
+	var (
+		recordOrigin bool
+		mainline     int
+		cont         bool
+		skip         bool
+		abort        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  long,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			modes := 0
+			for _, set := range []bool{cont, skip, abort} {
+				if set {
+					modes++
+				}
+			}
+			if modes > 1 {
+				return fmt.Errorf("only one of --continue, --skip and --abort can be used")
+			}
+			if modes == 1 {
+				if len(args) > 0 {
+					return fmt.Errorf("--continue, --skip and --abort take no commits")
+				}
+				seq, err := loadSequencer()
+				if err != nil {
+					return err
+				}
+				switch {
+				case cont:
+					return seq.resume()
+				case skip:
+					return seq.skip()
+				default:
+					return seq.abort()
+				}
+			}
+
+			if len(args) == 0 {
+				return fmt.Errorf("no commits given")
+			}
+			if sequencerInProgress() {
+				return fmt.Errorf("a cherry-pick or revert is already in progress\n(try \"orb %s --continue\", \"--skip\" or \"--abort\")", action)
+			}
+
+			todo, err := sequencerCommits(args)
+			if err != nil {
+				return err
+			}
+			head, err := refs.GetHead()
+			if err != nil || head == "" {
+				return fmt.Errorf("cannot %s onto a branch without commits", action)
+			}
+
+			seq := &sequencer{action: action, head: head, todo: todo, recordOrigin: recordOrigin, mainline: mainline}
+			if err := seq.save(); err != nil {
+				return err
+			}
+			return seq.run(true)
+		},
+	}
+
+	if action == actionPick {
+		cmd.Flags().BoolVarP(&recordOrigin, "record-origin", "x", false, "Add \"(cherry picked from commit ...)\" to the message")
+	}
+	cmd.Flags().IntVarP(&mainline, "mainline", "m", 0, "For merges, the parent (from 1) whose side the change is taken against")
+	cmd.Flags().BoolVar(&cont, "continue", false, "Commit the resolved conflict and carry on")
+	cmd.Flags().BoolVar(&skip, "skip", false, "Drop the commit that stopped and carry on")
+	cmd.Flags().BoolVar(&abort, "abort", false, "Stop and go back to where it started")
+
+	return cmd
+}
+
+// sequencerCommits resolves the commit arguments in the order they are
+// applied; a range "A..B" expands to its commits oldest first
+func sequencerCommits(args []string) ([]string, error) {
+	var commits []string
+	for _, arg := range args {
+		if !strings.Contains(arg, "..") {
+			hash, err := revision.Resolve(arg)
+			if err != nil {
+				return nil, fmt.Errorf("bad revision '%s': %w", arg, err)
+			}
+			commit, err := revision.PeelToCommit(hash)
+			if err != nil {
+				return nil, err
+			}
+			commits = append(commits, commit)
+			continue
+		}
+
+		walker := revision.NewWalker()
+		if err := walker.AddRevision(arg); err != nil {
+			return nil, fmt.Errorf("bad revision '%s': %w", arg, err)
+		}
+		var newestFirst []string
+		for {
+			commit, err := walker.Next()
+			if err != nil {
+				return nil, err
+			}
+			if commit == nil {
+				break
+			}
+			newestFirst = append(newestFirst, commit.Hash)
+		}
+		for i := len(newestFirst) - 1; i >= 0; i-- {
+			commits = append(commits, newestFirst[i])
+		}
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("empty commit set passed")
+	}
+	return commits, nil
+}
+
+// sequencerInProgress reports whether a cherry-pick or revert has stopped
+func sequencerInProgress() bool {
+	_, err := os.Stat(sequencerDir)
+	return err == nil
+}
+
+// loadSequencer reads the state of the cherry-pick or revert in progress
+func loadSequencer() (*sequencer, error) {
+	head, err := os.ReadFile(filepath.Join(sequencerDir, "head"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no cherry-pick or revert in progress")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading sequencer state: %w", err)
+	}
+	seq := &sequencer{head: strings.TrimSpace(string(head)), action: actionPick}
+
+	opts, err := os.ReadFile(filepath.Join(sequencerDir, "opts"))
+	if err != nil {
+		return nil, fmt.Errorf("reading sequencer state: %w", err)
+	}
+	for _, line := range strings.Split(string(opts), "\n") {
+		key, value, _ := strings.Cut(line, " = ")
+		switch key {
+		case "action":
+			seq.action = value
+		case "record-origin":
+			seq.recordOrigin = value == "true"
+		case "mainline":
+			seq.mainline, _ = strconv.Atoi(value)
+		}
+	}
+
+	todo, err := os.ReadFile(filepath.Join(sequencerDir, "todo"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading sequencer state: %w", err)
+	}
+	for _, line := range strings.Split(string(todo), "\n") {
+		// "pick <hash> <subject>" or "revert <hash> <subject>"
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			seq.todo = append(seq.todo, fields[1])
+		}
+	}
+	return seq, nil
+}
+
+// save writes the sequencer state, the todo list readable as "pick <hash>
+// <subject>" lines like git's
+func (s *sequencer) save() error {
+	if err := os.MkdirAll(sequencerDir, 0755); err != nil {
+		return fmt.Errorf("creating sequencer directory: %w", err)
+	}
+
+	command := "pick"
+	if s.action == actionRevert {
+		command = "revert"
+	}
+	var todo strings.Builder
+	for _, hash := range s.todo {
+		subject := ""
+		if commit, err := objects.ReadCommit(hash); err == nil {
+			subject = commit.Subject()
+		}
+		fmt.Fprintf(&todo, "%s %s %s\n", command, hash, subject)
+	}
+
+	opts := fmt.Sprintf("action = %s\nrecord-origin = %t\nmainline = %d\n", s.action, s.recordOrigin, s.mainline)
+
+	files := map[string]string{
+		"head": s.head + "\n",
+		"opts": opts,
+		"todo": todo.String(),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(sequencerDir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing sequencer state: %w", err)
+		}
+	}
+	return nil
+}
+
+// finish removes every trace of the operation
+func (s *sequencer) finish() error {
+	if err := refs.DeletePseudoRef(s.pickHead()); err != nil {
+		return err
+	}
+	if err := os.Remove(mergeMsgFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", mergeMsgFile, err)
+	}
+	if err := os.RemoveAll(sequencerDir); err != nil {
+		return fmt.Errorf("removing sequencer state: %w", err)
+	}
+	return nil
+}
+
+// run applies the commits left in the todo list one after another,
+// stopping at the first that doesn't go in cleanly. On the very first
+// commit, a refusal (e.g. because of local changes) leaves no state behind.
+func (s *sequencer) run(starting bool) error {
+	for len(s.todo) > 0 {
+		hash := s.todo[0]
+
+		replayed, err := replayCommit(s.action, hash, s.mainline, s.recordOrigin)
+		if err != nil {
+			if starting {
+				s.finish()
+			}
+			return err
+		}
+		starting = false
+
+		s.todo = s.todo[1:]
+		if err := s.save(); err != nil {
+			return err
+		}
+
+		if !replayed.result.Clean() || replayed.empty {
+			return s.stop(hash, replayed)
+		}
+		if _, err := commitReplayed(replayed.message, replayed.author, s.action+": "+firstLine(replayed.message)); err != nil {
+			return err
+		}
+	}
+	return s.finish()
+}
+
+// stop records the commit that needs help and tells the user what to do
+func (s *sequencer) stop(hash string, replayed *replayedCommit) error {
+	if err := refs.WritePseudoRef(s.pickHead(), hash); err != nil {
+		return err
+	}
+	if err := os.WriteFile(mergeMsgFile, []byte(replayed.message+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", mergeMsgFile, err)
+	}
+
+	if replayed.empty {
+		fmt.Fprintf(os.Stderr, "The previous %s is now empty, possibly due to conflict resolution.\n", s.action)
+		fmt.Fprintln(os.Stderr, "If you wish to commit it anyway, use \"orb commit --allow-empty\";")
+		fmt.Fprintf(os.Stderr, "otherwise, please use \"orb %s --skip\".\n", s.action)
+		os.Exit(1)
+	}
+
+	verb := "apply"
+	if s.action == actionRevert {
+		verb = "revert"
+	}
+	fmt.Fprintf(os.Stderr, "error: could not %s %s... %s\n", verb, shortHash(hash), replayed.subject)
+	fmt.Fprintln(os.Stderr, "hint: after resolving the conflicts, mark the corrected paths")
+	fmt.Fprintln(os.Stderr, "hint: with 'orb add <paths>' or 'orb rm <paths>'")
+	fmt.Fprintf(os.Stderr, "hint: then run \"orb %s --continue\".\n", s.action)
+	fmt.Fprintf(os.Stderr, "hint: You can instead skip this commit with \"orb %s --skip\".\n", s.action)
+	fmt.Fprintf(os.Stderr, "hint: To abort and get back to the state before \"orb %s\",\n", s.action)
+	fmt.Fprintf(os.Stderr, "hint: run \"orb %s --abort\".\n", s.action)
+	os.Exit(1)
+	return nil
+}
+
+// resume commits the resolved conflict, if it hasn't been committed
+// already, and carries on with the rest
+func (s *sequencer) resume() error {
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("loading index: %w", err)
+	}
+	if idx.HasConflicts() {
+		return fmt.Errorf("you need to resolve your current index first:\n\t%s", strings.Join(idx.ConflictPaths(), "\n\t"))
+	}
+
+	if picked, err := refs.ReadPseudoRef(s.pickHead()); err == nil {
+		content, err := os.ReadFile(mergeMsgFile)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", mergeMsgFile, err)
+		}
+		message := cleanupMessage(string(content), true)
+
+		var author *objects.Signature
+		if s.action == actionPick {
+			if commit, err := objects.ReadCommit(picked); err == nil {
+				author = &commit.Author
+			}
+		}
+
+		empty, err := indexMatchesHead(idx)
+		if err != nil {
+			return err
+		}
+		if empty {
+			return fmt.Errorf("the previous %s is now empty; use \"orb commit --allow-empty\" to keep it or \"orb %s --skip\"", s.action, s.action)
+		}
+		if _, err := commitReplayed(message, author, s.action+": "+firstLine(message)); err != nil {
+			return err
+		}
+		if err := refs.DeletePseudoRef(s.pickHead()); err != nil {
+			return err
+		}
+		os.Remove(mergeMsgFile)
+	}
+	return s.run(false)
+}
+
+// skip throws away the commit that stopped and carries on with the rest
+func (s *sequencer) skip() error {
+	if _, err := refs.ReadPseudoRef(s.pickHead()); err == nil {
+		head, _ := refs.GetHead()
+		if err := moveWorkTree(head, true); err != nil {
+			return err
+		}
+		if err := refs.DeletePseudoRef(s.pickHead()); err != nil {
+			return err
+		}
+		os.Remove(mergeMsgFile)
+	} else if len(s.todo) > 0 {
+		// the last run refused the next commit before touching anything
+		s.todo = s.todo[1:]
+	}
+	return s.run(false)
+}
+
+// abort puts HEAD, the index and the files back to where they were
+// before the operation started
+func (s *sequencer) abort() error {
+	if err := moveWorkTree(s.head, true); err != nil {
+		return err
+	}
+	branchRef, _, _ := refs.ResolveRef("HEAD")
+	if head, _ := refs.GetHead(); head != s.head {
+		tx := refs.NewTransaction()
+		tx.Update(branchRef, s.head, "", s.action+": abort")
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("updating HEAD: %w", err)
+		}
+	}
+	return s.finish()
+}
+
+// replayedCommit is a commit whose change was merged into the working tree
+type replayedCommit struct {
+	result  *merge.Result
+	subject string // of the original commit
+	message string
+	author  *objects.Signature // nil means the current user
+	empty   bool               // it went in cleanly but changed nothing
+}
+
+// replayCommit merges the change a commit made, or with revert its
+// inverse, into the index and working tree on top of HEAD. The index must
+// match HEAD so that nothing unrelated ends up in the new commit.
+func replayCommit(action, hash string, mainline int, recordOrigin bool) (*replayedCommit, error) {
+	commit, err := objects.ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	parentTree := ""
+	switch {
+	case len(commit.Parents) > 1 && mainline == 0:
+		return nil, fmt.Errorf("commit %s is a merge but no -m option was given", shortHash(hash))
+	case len(commit.Parents) > 1 && mainline > len(commit.Parents):
+		return nil, fmt.Errorf("commit %s does not have parent %d", shortHash(hash), mainline)
+	case len(commit.Parents) <= 1 && mainline > 0:
+		return nil, fmt.Errorf("mainline was specified but commit %s is not a merge", shortHash(hash))
+	}
+	parent := ""
+	if len(commit.Parents) > 0 {
+		parent = commit.Parents[0]
+		if mainline > 0 {
+			parent = commit.Parents[mainline-1]
+		}
+		if parentTree, err = revision.PeelToTree(parent); err != nil {
+			return nil, err
+		}
+	}
+
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return nil, fmt.Errorf("loading index: %w", err)
+	}
+	if idx.HasConflicts() {
+		return nil, fmt.Errorf("you need to resolve your current index first:\n\t%s", strings.Join(idx.ConflictPaths(), "\n\t"))
+	}
+	if clean, err := indexMatchesHead(idx); err != nil {
+		return nil, err
+	} else if !clean {
+		return nil, fmt.Errorf("your local changes would be overwritten by %s\nhint: commit your changes or stash them to proceed", action)
+	}
+	ours := worktree.IndexFiles(idx)
+
+	subject := commit.Subject()
+	replayed := &replayedCommit{subject: subject}
+	base, theirs := parentTree, commit.Tree
+	labels := merge.Labels{Ours: "HEAD", Theirs: fmt.Sprintf("%s (%s)", shortHash(hash), subject)}
+
+	if action == actionRevert {
+		base, theirs = theirs, base
+		labels.Theirs = "parent of " + labels.Theirs
+		replayed.message = fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s", subject, hash)
+		if mainline > 0 {
+			replayed.message += fmt.Sprintf(", reversing\nchanges made to %s", parent)
+		}
+		replayed.message += "."
+	} else {
+		replayed.message = strings.TrimRight(commit.Message, "\n")
+		if recordOrigin {
+			replayed.message += fmt.Sprintf("\n\n(cherry picked from commit %s)", hash)
+		}
+		replayed.author = &commit.Author
+	}
+
+	baseFiles, err := worktree.Files(base)
+	if err != nil {
+		return nil, err
+	}
+	theirFiles, err := worktree.Files(theirs)
+	if err != nil {
+		return nil, err
+	}
+	result, err := merge.Files(baseFiles, ours, theirFiles, labels, mergeRenames())
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Apply(idx, ours); err != nil {
+		return nil, err
+	}
+	if err := idx.Write(); err != nil {
+		return nil, fmt.Errorf("writing index: %w", err)
+	}
+	for _, message := range result.Messages {
+		fmt.Println(message)
+	}
+
+	replayed.result = result
+	replayed.empty = result.Clean() && sameFiles(result.Files, ours)
+	return replayed, nil
+}
+
+// indexMatchesHead reports whether nothing is staged on top of HEAD
+func indexMatchesHead(idx *index.Index) (bool, error) {
+	head, err := headFiles()
+	if err != nil {
+		return false, err
+	}
+	return sameFiles(worktree.IndexFiles(idx), head), nil
+}
+
+// commitReplayed commits the index on top of HEAD, keeping the original
+// author when there is one, and prints the new commit like orb commit does
+func commitReplayed(message string, author *objects.Signature, reason string) (string, error) {
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return "", fmt.Errorf("loading index: %w", err)
+	}
+	tree, err := objects.WriteObject(objects.TreeType, buildTreeContent(idx))
+	if err != nil {
+		return "", fmt.Errorf("writing tree object: %w", err)
+	}
+
+	committer, err := ident.Committer()
+	if err != nil {
+		return "", err
+	}
+	if author == nil {
+		sig, err := ident.Author()
+		if err != nil {
+			return "", err
+		}
+		author = &sig
+	}
+
+	head, err := refs.GetHead()
+	if err != nil {
+		return "", fmt.Errorf("reading HEAD: %w", err)
+	}
+	hash, err := objects.WriteCommit(&objects.Commit{
+		Tree:      tree,
+		Parents:   []string{head},
+		Author:    *author,
+		Committer: committer,
+		Message:   message + "\n",
+	})
+	if err != nil {
+		return "", fmt.Errorf("writing commit object: %w", err)
+	}
+
+	branchRef, _, _ := refs.ResolveRef("HEAD")
+	tx := refs.NewTransaction()
+	tx.Update(branchRef, hash, head, reason)
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("updating HEAD: %w", err)
+	}
+
+	fmt.Printf("[%s] %s\n", hash[:7], firstLine(message))
+	return hash, nil
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/config"
+	"github.com/ayushsarode/orb/internal/diff"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+// showOptions are the flags of orb show that shape a commit
+type showOptions struct {
+	format      commitFormat
+	decorate    string
+	decorations *refs.Decorations
+	paths       []string
+	diff        diffOptions
+}
+
+func newShowCommand() *cobra.Command {
+	var (
+		oneline  bool
+		format   string
+		output   outputFlags
+		patch    bool
+		noPatch  bool
+		decorate string
+		lines    diffFlags
+		renames  renameFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "show [<object>...] [-- <path>...]",
+		Short: "Show commits, tags, trees and blobs",
+		Long: `Show one or more objects. Commits are shown with their log message and
+the patch they introduce; merges get a combined diff. Tags show their
+message and the object they point at, trees list their entries and blobs
+print their content. "<rev>:<path>" names a file or directory in a commit.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			specs, paths := args, []string(nil)
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				specs, paths = args[:dash], args[dash:]
+			}
+			if len(specs) == 0 {
+				specs = []string{"HEAD"}
+			}
+
+			opts := showOptions{paths: cleanPathspecs(paths), diff: output.options()}
+			opts.diff.patch = patch || !noPatch && !opts.diff.summarized()
+
+			var err error
+			if opts.diff.lines, err = lines.options(cmd); err != nil {
+				return err
+			}
+			if opts.diff.renames, err = renames.options(cmd, "diff"); err != nil {
+				return err
+			}
+			if opts.format, err = parseCommitFormat(format); err != nil {
+				return err
+			}
+			if oneline {
+				opts.format = commitFormat{name: "oneline", abbrev: true}
+			}
+			opts.format.color = useColor()
+
+			if !cmd.Flags().Changed("decorate") {
+				decorate = decorateShort
+				if cfg, err := config.LoadConfig(); err == nil {
+					decorate = cfg.GetString("log.decorate", decorateShort)
+				}
+			}
+			if opts.decorate, err = parseDecorateStyle(decorate); err != nil {
+				return err
+			}
+			if opts.decorations, err = refs.LoadDecorations(); err != nil {
+				return err
+			}
+
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+
+			checkFailed := false
+			for i, spec := range specs {
+				hash, err := revision.ResolveObject(spec)
+				if err != nil {
+					return fmt.Errorf("bad revision '%s': %w", spec, err)
+				}
+				if i > 0 && opts.format.multiLine() {
+					fmt.Fprintln(out)
+				}
+				err = showObject(out, spec, hash, opts)
+				if errors.Is(err, errCheckFailed) {
+					checkFailed = true
+				} else if err != nil {
+					return err
+				}
+			}
+			exitIfCheckFailed(out, checkFailed)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&oneline, "oneline", false, "Show commits on one line")
+	cmd.Flags().StringVar(&format, "format", "", "Pretty-print commits: oneline, short, medium, full, fuller or format:<string>")
+	cmd.Flags().StringVar(&format, "pretty", "", "Same as --format")
+	addOutputFlags(cmd, &output)
+	cmd.Flags().BoolVarP(&patch, "patch", "p", false, "Show the patch, also with --stat and the like")
+	cmd.Flags().BoolVarP(&noPatch, "no-patch", "s", false, "Don't show the patch")
+	cmd.Flags().StringVar(&decorate, "decorate", "", "Show ref names next to commits: short, full or no")
+	cmd.Flags().Lookup("decorate").NoOptDefVal = decorateShort
+	addDiffFlags(cmd, &lines)
+	addRenameFlags(cmd, &renames)
+	parseSimilarities(cmd)
+
+	return cmd
+}
+
+// showObject prints one object the way its type is shown
+func showObject(out *bufio.Writer, spec, hash string, opts showOptions) error {
+	objType, content, err := objects.ReadObject(hash)
+	if err != nil {
+		return err
+	}
+
+	switch objType {
+	case objects.CommitType:
+		commit, err := objects.ParseCommit(content)
+		if err != nil {
+			return fmt.Errorf("parsing commit %s: %w", hash, err)
+		}
+		return showCommit(out, hash, commit, opts)
+
+	case objects.TagType:
+		tag, err := objects.ParseTag(content)
+		if err != nil {
+			return fmt.Errorf("parsing tag %s: %w", hash, err)
+		}
+		fmt.Fprintln(out, colorize(opts.format.color, colorYellow, "tag "+tag.Name))
+		if tag.Tagger.Name != "" || tag.Tagger.Email != "" {
+			fmt.Fprintf(out, "Tagger: %s <%s>\n", tag.Tagger.Name, tag.Tagger.Email)
+			fmt.Fprintf(out, "Date:   %s\n", tag.Tagger.When.Format(gitDateLayout))
+		}
+		fmt.Fprintln(out)
+		if tag.Message != "" {
+			fmt.Fprint(out, tag.Message)
+			if !strings.HasSuffix(tag.Message, "\n") {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintln(out)
+		}
+		// then whatever the tag points at
+		return showObject(out, tag.Object, tag.Object, opts)
+
+	case objects.TreeType:
+		entries, err := objects.ParseTree(content)
+		if err != nil {
+			return fmt.Errorf("parsing tree %s: %w", hash, err)
+		}
+		fmt.Fprintf(out, "%s\n\n", colorize(opts.format.color, colorYellow, "tree "+spec))
+		for _, entry := range entries {
+			if entry.IsTree() {
+				fmt.Fprintln(out, entry.Name+"/")
+			} else {
+				fmt.Fprintln(out, entry.Name)
+			}
+		}
+		return nil
+	}
+
+	out.Write(content)
+	return nil
+}
+
+// showCommit prints a commit's header and message, then what it changed
+func showCommit(out *bufio.Writer, hash string, commit *objects.Commit, opts showOptions) error {
+	names := decorationNames(opts.decorations.For(hash), opts.decorate, opts.format.color)
+	lines := formatCommit(hash, commit, opts.format, names)
+
+	var diffLines []string
+	var err error
+	if len(commit.Parents) > 1 {
+		if opts.diff.patch {
+			diffLines, err = combinedDiffLines(commit, opts.paths)
+		}
+	} else if opts.diff.patch || opts.diff.summarized() {
+		diffLines, err = commitDiffLines(commit, opts.paths, opts.diff)
+	}
+	if err != nil && !errors.Is(err, errCheckFailed) {
+		return err
+	}
+
+	if len(diffLines) > 0 && opts.format.multiLine() {
+		lines = append(lines, "")
+	}
+	lines = append(lines, diffLines...)
+
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+	return err
+}
+
+// combinedDiffLines renders the combined diff of a merge against all of
+// its parents; files that match one of the parents are left out
+func combinedDiffLines(commit *objects.Commit, paths []string) ([]string, error) {
+	var parentTrees []string
+	for _, parentHash := range commit.Parents {
+		parent, err := objects.ReadCommit(parentHash)
+		if err != nil {
+			// without every parent there is nothing to combine
+			return nil, nil
+		}
+		parentTrees = append(parentTrees, parent.Tree)
+	}
+
+	changes, err := diff.CombinedChanges(parentTrees, commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, change := range changes {
+		if !matchesPathspec(change.Path, paths) {
+			continue
+		}
+		if err := diff.WriteCombinedPatch(&buf, change); err != nil {
+			return nil, err
+		}
+	}
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), nil
+}
@@ -0,0 +1,608 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/diff"
+	"github.com/ayushsarode/orb/internal/ident"
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/merge"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/ayushsarode/orb/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+// stashRef holds the newest stash; older ones live in its reflog
+const stashRef = "refs/stash"
+
+func newStashCommand() *cobra.Command {
+	var (
+		message   string
+		untracked bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stash [push [-m <message>] [-u] [-- <path>...]]",
+		Short: "Put local changes aside and get them back later",
+		Long: `Save the staged and unstaged changes as commits under refs/stash and
+reset the files to HEAD. Stashes form a stack: stash@{0} is the newest,
+and "orb stash pop" applies it and takes it off the stack.
+
+Each stash is a commit of the working tree whose parents are HEAD at the
+time and a commit of the index. With -u a third parent holds the
+untracked files.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && cmd.ArgsLenAtDash() != 0 {
+				return fmt.Errorf("unknown stash command '%s'", args[0])
+			}
+			return stashPush(message, untracked, cleanPathspecs(args))
+		},
+	}
+
+	cmd.Flags().StringVarP(&message, "message", "m", "", "Describe the stash")
+	cmd.Flags().BoolVarP(&untracked, "include-untracked", "u", false, "Stash untracked files too")
+
+	cmd.AddCommand(newStashPushCommand())
+	cmd.AddCommand(newStashApplyCommand(false))
+	cmd.AddCommand(newStashApplyCommand(true))
+	cmd.AddCommand(newStashListCommand())
+	cmd.AddCommand(newStashShowCommand())
+	cmd.AddCommand(newStashDropCommand())
+	cmd.AddCommand(newStashClearCommand())
+
+	return cmd
+}
+
+func newStashPushCommand() *cobra.Command {
+	var (
+		message   string
+		untracked bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "push [-m <message>] [-u] [--] [<path>...]",
+		Short: "Save local changes as a new stash",
+		Long: `Save local changes as a new stash and reset them to HEAD. With paths,
+only changes to those paths are stashed and reset.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return stashPush(message, untracked, cleanPathspecs(args))
+		},
+	}
+
+	cmd.Flags().StringVarP(&message, "message", "m", "", "Describe the stash")
+	cmd.Flags().BoolVarP(&untracked, "include-untracked", "u", false, "Stash untracked files too")
+
+	return cmd
+}
+
+func newStashApplyCommand(pop bool) *cobra.Command {
+	var restoreIndex bool
+
+	use, short := "apply [--index] [<stash>]", "Apply a stash on top of the current files"
+	if pop {
+		use, short = "pop [--index] [<stash>]", "Apply a stash and drop it from the stack"
+	}
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long: `Merge the changes of a stash (stash@{0} by default) into the working
+tree, the same way a merge would: changes that clash with what is there
+now are left as conflicts. Changes that were staged come back unstaged
+unless --index is given. pop drops the stash afterwards, unless applying
+it ran into conflicts.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, hash, err := stashEntry(args)
+			if err != nil {
+				return err
+			}
+
+			clean, err := stashApply(hash, restoreIndex)
+			if err != nil {
+				return err
+			}
+			if !clean {
+				if pop {
+					fmt.Println("The stash entry is kept in case you need it again.")
+				}
+				os.Exit(1)
+			}
+
+			if pop {
+				return stashDrop(n, hash)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&restoreIndex, "index", false, "Restore the staged changes to the index too")
+
+	return cmd
+}
+
+func newStashListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the stashes, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := refs.ReadReflog(stashRef)
+			if err != nil {
+				return fmt.Errorf("reading stashes: %w", err)
+			}
+			for i := len(entries) - 1; i >= 0; i-- {
+				fmt.Printf("stash@{%d}: %s\n", len(entries)-1-i, entries[i].Message)
+			}
+			return nil
+		},
+	}
+}
+
+func newStashShowCommand() *cobra.Command {
+	var (
+		stat  bool
+		patch bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "show [-p] [<stash>]",
+		Short: "Show the changes a stash holds",
+		Long: `Show what a stash changed against the commit it was made on: a diffstat
+by default, or the patch with -p.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, hash, err := stashEntry(args)
+			if err != nil {
+				return err
+			}
+			stash, err := readStash(hash)
+			if err != nil {
+				return err
+			}
+			base, err := objects.ReadCommit(stash.Parents[0])
+			if err != nil {
+				return err
+			}
+
+			changes, err := diff.Trees(base.Tree, stash.Tree)
+			if err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				return nil
+			}
+
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+
+			opts := diffOptions{stat: stat || !patch, patch: patch, lines: configDiffOptions(), renames: configRenames("diff")}
+			return writeChanges(out, changes, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&stat, "stat", false, "Show a diffstat (the default without -p)")
+	cmd.Flags().BoolVarP(&patch, "patch", "p", false, "Show the patch")
+
+	return cmd
+}
+
+func newStashDropCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "drop [<stash>]",
+		Short: "Remove a stash from the stack",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, hash, err := stashEntry(args)
+			if err != nil {
+				return err
+			}
+			return stashDrop(n, hash)
+		},
+	}
+}
+
+func newStashClearCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Remove all stashes",
+		Long: `Remove every stash. The stashed commits stay in the object store but
+nothing refers to them any more.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := refs.GetRef(stashRef); err != nil {
+				return nil
+			}
+			tx := refs.NewTransaction()
+			tx.Delete(stashRef, "", "stash: clear")
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("clearing stashes: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// stashEntry resolves a stash argument, "stash@{2}" or just "2", to its
+// place on the stack and its commit; no argument means the newest
+func stashEntry(args []string) (int, string, error) {
+	n := 0
+	if len(args) > 0 {
+		selector := strings.TrimSuffix(strings.TrimPrefix(args[0], "stash@{"), "}")
+		var err error
+		if n, err = strconv.Atoi(selector); err != nil || n < 0 {
+			return 0, "", fmt.Errorf("'%s' is not a stash reference", args[0])
+		}
+	}
+
+	entries, err := refs.ReadReflog(stashRef)
+	if err != nil {
+		return 0, "", fmt.Errorf("reading stashes: %w", err)
+	}
+	if len(entries) == 0 {
+		return 0, "", fmt.Errorf("no stash entries found")
+	}
+	if n >= len(entries) {
+		return 0, "", fmt.Errorf("stash@{%d} does not exist: there are only %d stash entries", n, len(entries))
+	}
+	return n, entries[len(entries)-1-n].NewHash, nil
+}
+
+// readStash reads a stash commit, checking it has the shape push gives it
+func readStash(hash string) (*objects.Commit, error) {
+	stash, err := objects.ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(stash.Parents) < 2 {
+		return nil, fmt.Errorf("%s is not a stash-like commit", shortHash(hash))
+	}
+	return stash, nil
+}
+
+// stashPush records the index and the working tree (and with untracked,
+// the untracked files) as a stash, then resets the stashed paths to HEAD
+func stashPush(message string, untracked bool, paths []string) error {
+	head, err := refs.GetHead()
+	if err != nil || head == "" {
+		return fmt.Errorf("you do not have the initial commit yet")
+	}
+	headCommit, err := objects.ReadCommit(head)
+	if err != nil {
+		return err
+	}
+	headTree, err := worktree.Files(headCommit.Tree)
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("loading index: %w", err)
+	}
+	if idx.HasConflicts() {
+		return fmt.Errorf("cannot save the current index state: you have unmerged files:\n\t%s", strings.Join(idx.ConflictPaths(), "\n\t"))
+	}
+	staged := worktree.IndexFiles(idx)
+
+	// the index state: HEAD's files with the staged version of every
+	// stashed path
+	indexFiles := make(map[string]string)
+	for path, hash := range headTree {
+		if !matchesPathspec(path, paths) {
+			indexFiles[path] = hash
+		}
+	}
+	for path, hash := range staged {
+		if matchesPathspec(path, paths) {
+			indexFiles[path] = hash
+		}
+	}
+
+	// the working tree state: the index state with the tracked files as
+	// they are on disk
+	workFiles := make(map[string]string)
+	for path, hash := range indexFiles {
+		workFiles[path] = hash
+	}
+	for path := range staged {
+		if !matchesPathspec(path, paths) {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(workFiles, path)
+			continue
+		}
+		hash, err := objects.WriteBlob(path)
+		if err != nil {
+			return fmt.Errorf("storing %s: %w", path, err)
+		}
+		workFiles[path] = hash
+	}
+
+	untrackedFiles := make(map[string]string)
+	if untracked {
+		files, err := listWorkingDirFiles()
+		if err != nil {
+			return fmt.Errorf("listing untracked files: %w", err)
+		}
+		for _, path := range files {
+			path = cleanPathspecs([]string{path})[0]
+			if _, tracked := idx.Entries[path]; tracked || !matchesPathspec(path, paths) {
+				continue
+			}
+			hash, err := objects.WriteBlob(path)
+			if err != nil {
+				return fmt.Errorf("storing %s: %w", path, err)
+			}
+			untrackedFiles[path] = hash
+		}
+	}
+
+	if sameFiles(indexFiles, headTree) && sameFiles(workFiles, indexFiles) && len(untrackedFiles) == 0 {
+		fmt.Println("No local changes to save")
+		return nil
+	}
+
+	branch := refs.GetCurrentBranch()
+	if branch == "" {
+		branch = "(no branch)"
+	}
+	onHead := fmt.Sprintf("%s: %s %s", branch, shortHash(head), headCommit.Subject())
+
+	indexCommit, err := writeStashCommit(indexFiles, []string{head}, "index on "+onHead)
+	if err != nil {
+		return err
+	}
+	parents := []string{head, indexCommit}
+	if len(untrackedFiles) > 0 {
+		untrackedCommit, err := writeStashCommit(untrackedFiles, nil, "untracked files on "+onHead)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, untrackedCommit)
+	}
+
+	description := "WIP on " + onHead
+	if message != "" {
+		description = "On " + branch + ": " + firstLine(message)
+	}
+	stash, err := writeStashCommit(workFiles, parents, description)
+	if err != nil {
+		return err
+	}
+
+	tx := refs.NewTransaction()
+	tx.Update(stashRef, stash, "", description)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("updating %s: %w", stashRef, err)
+	}
+
+	// put the stashed paths back the way HEAD has them
+	var reset []string
+	for _, files := range []map[string]string{staged, headTree} {
+		for path := range files {
+			if matchesPathspec(path, paths) {
+				reset = append(reset, path)
+			}
+		}
+	}
+	sort.Strings(reset)
+	for _, path := range reset {
+		hash, ok := headTree[path]
+		if !ok {
+			if err := worktree.RemoveFile(path); err != nil {
+				return err
+			}
+			idx.Remove(path)
+			continue
+		}
+		if working, err := worktree.HashFile(path); err != nil {
+			return err
+		} else if working != hash {
+			if err := worktree.WriteFile(path, hash); err != nil {
+				return err
+			}
+		}
+		idx.SetEntry(path, hash)
+	}
+	for path := range untrackedFiles {
+		if err := worktree.RemoveFile(path); err != nil {
+			return err
+		}
+	}
+	if err := idx.Write(); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+
+	fmt.Printf("Saved working directory and index state %s\n", description)
+	return nil
+}
+
+// writeStashCommit stores files as a tree and commits it
+func writeStashCommit(files map[string]string, parents []string, message string) (string, error) {
+	entries := make([]index.Entry, 0, len(files))
+	for path, hash := range files {
+		entries = append(entries, index.Entry{Path: path, ObjectHash: hash})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	tree, err := objects.WriteObject(objects.TreeType, buildDirectoryTree(entries, ""))
+	if err != nil {
+		return "", fmt.Errorf("writing tree object: %w", err)
+	}
+
+	author, err := ident.Author()
+	if err != nil {
+		return "", err
+	}
+	committer, err := ident.Committer()
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := objects.WriteCommit(&objects.Commit{
+		Tree:      tree,
+		Parents:   parents,
+		Author:    author,
+		Committer: committer,
+		Message:   message + "\n",
+	})
+	if err != nil {
+		return "", fmt.Errorf("writing commit object: %w", err)
+	}
+	return hash, nil
+}
+
+// sameFiles reports whether two sets of files are identical
+func sameFiles(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, hash := range a {
+		if b[path] != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// stashApply merges a stash into the index and working tree. The result
+// is false when the merge left conflicts behind.
+func stashApply(hash string, restoreIndex bool) (bool, error) {
+	stash, err := readStash(hash)
+	if err != nil {
+		return false, err
+	}
+	baseCommit, err := objects.ReadCommit(stash.Parents[0])
+	if err != nil {
+		return false, err
+	}
+	base, err := worktree.Files(baseCommit.Tree)
+	if err != nil {
+		return false, err
+	}
+	stashed, err := worktree.Files(stash.Tree)
+	if err != nil {
+		return false, err
+	}
+
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return false, fmt.Errorf("loading index: %w", err)
+	}
+	ours := worktree.IndexFiles(idx)
+
+	labels := merge.Labels{Base: "Stash base", Ours: "Updated upstream", Theirs: "Stashed changes"}
+
+	// with --index, the staged changes are merged into the index on their own
+	var staged *merge.Result
+	if restoreIndex {
+		indexTree, err := revision.PeelToTree(stash.Parents[1])
+		if err != nil {
+			return false, err
+		}
+		indexFiles, err := worktree.Files(indexTree)
+		if err != nil {
+			return false, err
+		}
+		if !sameFiles(indexFiles, base) {
+			if staged, err = merge.Files(base, ours, indexFiles, labels, mergeRenames()); err != nil {
+				return false, err
+			}
+			if !staged.Clean() {
+				return false, fmt.Errorf("conflicts in index; try without --index")
+			}
+		}
+	}
+
+	// untracked files come back as they were, and must not overwrite anything
+	untracked := make(map[string]string)
+	if len(stash.Parents) > 2 {
+		untrackedCommit, err := objects.ReadCommit(stash.Parents[2])
+		if err != nil {
+			return false, err
+		}
+		if untracked, err = worktree.Files(untrackedCommit.Tree); err != nil {
+			return false, err
+		}
+		var existing []string
+		for path := range untracked {
+			if _, err := os.Stat(path); err == nil {
+				existing = append(existing, path)
+			}
+		}
+		if len(existing) > 0 {
+			sort.Strings(existing)
+			return false, fmt.Errorf("could not restore untracked files from stash; these already exist:\n\t%s", strings.Join(existing, "\n\t"))
+		}
+	}
+
+	result, err := merge.Files(base, ours, stashed, labels, mergeRenames())
+	if err != nil {
+		return false, err
+	}
+	if err := result.Apply(idx, ours); err != nil {
+		return false, err
+	}
+	for _, message := range result.Messages {
+		fmt.Println(message)
+	}
+
+	// Apply stages what it merged; put the index back the way it was,
+	// apart from new files, or take the stashed index instead
+	for path, hash := range result.Files {
+		if _, conflicted := result.Conflicts[path]; conflicted || hash == ours[path] {
+			continue
+		}
+		if staged == nil && ours[path] != "" {
+			idx.SetEntry(path, ours[path])
+		}
+	}
+	for path, hash := range ours {
+		if _, merged := result.Files[path]; !merged && staged == nil {
+			idx.SetEntry(path, hash)
+		}
+	}
+	if staged != nil {
+		for path := range idx.Entries {
+			if _, ok := staged.Files[path]; !ok {
+				if _, conflicted := result.Conflicts[path]; !conflicted {
+					idx.Remove(path)
+				}
+			}
+		}
+		for path, hash := range staged.Files {
+			if _, conflicted := result.Conflicts[path]; !conflicted {
+				idx.SetEntry(path, hash)
+			}
+		}
+	}
+
+	for path, hash := range untracked {
+		if err := worktree.WriteFile(path, hash); err != nil {
+			return false, err
+		}
+	}
+
+	if err := idx.Write(); err != nil {
+		return false, fmt.Errorf("writing index: %w", err)
+	}
+	return result.Clean(), nil
+}
+
+// stashDrop takes entry n off the stash stack
+func stashDrop(n int, hash string) error {
+	if err := refs.DropReflogEntry(stashRef, n, true); err != nil {
+		return fmt.Errorf("dropping stash@{%d}: %w", n, err)
+	}
+	fmt.Printf("Dropped stash@{%d} (%s)\n", n, hash)
+	return nil
+}
@@ -7,11 +7,25 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/ayushsarode/orb/internal/diff"
 	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/worktree"
 	"github.com/spf13/cobra"
 )
 
+// stagedLabels are how status names each kind of staged change
+var stagedLabels = map[byte]string{
+	diff.Added:    "new file:",
+	diff.Deleted:  "deleted:",
+	diff.Modified: "modified:",
+	diff.Renamed:  "renamed:",
+	diff.Copied:   "copied:",
+}
+
 func newStatusCommand() *cobra.Command {
+	var renames renameFlags
+
 	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show the working tree status",
@@ -61,42 +75,45 @@ func newStatusCommand() *cobra.Command {
 				}
 			}
 
-			// Get current 1 name
-			headContent, err := os.ReadFile(".orb/HEAD")
+			// what the index holds compared to HEAD is what gets committed
+			head, err := headFiles()
+			if err != nil {
+				return err
+			}
+			renameOpts, err := renames.options(cmd, "status")
+			if err != nil {
+				return err
+			}
+			stagedChanges, err := findRenames(diff.Files(head, worktree.IndexFiles(idx)), renameOpts)
+			if err != nil {
+				return err
+			}
+
+			// Get current branch name
 			branchName := "detached HEAD"
 
-			if err == nil {
-				head := strings.TrimSpace(string(headContent))
-				if strings.HasPrefix(head, "ref: refs/heads/") {
-					// Use the refs package to get the branch name
-					refPath := strings.TrimPrefix(head, "ref: ")
-					if strings.HasPrefix(refPath, "refs/heads/") {
-						branchName = strings.TrimPrefix(refPath, "refs/heads/")
-					}
-				} else {
-					// We're in detached HEAD state with a commit hash
-					shortHash := head
-					if len(head) > 7 {
-						shortHash = head[:7]
-					}
-					branchName = fmt.Sprintf("detached HEAD at %s", shortHash)
-				}
+			if target, err := refs.ReadSymbolicRef("HEAD"); err == nil {
+				// HEAD points to a branch
+				branchName = refs.ShortenRef(target)
+			} else if head, err := refs.GetHead(); err == nil {
+				// We're in detached HEAD state with a commit hash
+				branchName = fmt.Sprintf("detached HEAD at %s", shortHash(head))
 			}
 
 			// Print status with correct branch name
 			fmt.Printf("On branch %s\n", branchName)
 
 			// Print staged files
-			if len(stagedFiles) > 0 {
+			if len(stagedChanges) > 0 {
 				fmt.Println("\nChanges to be committed:")
 				fmt.Println("  (use \"orb reset HEAD <file>...\" to unstage)")
 
-				for path := range stagedFiles {
-					if _, err := os.Stat(path); os.IsNotExist(err) {
-						fmt.Printf("\tdeleted:    %s\n", path)
-					} else {
-						fmt.Printf("\tnew file:   %s\n", path)
+				for _, change := range stagedChanges {
+					path := change.Path
+					if change.OldPath != "" {
+						path = change.OldPath + " -> " + change.Path
 					}
+					fmt.Printf("\t%-11s %s\n", stagedLabels[change.Status], path)
 				}
 			}
 
@@ -130,8 +147,10 @@ func newStatusCommand() *cobra.Command {
 					fmt.Printf("\t%s\n", path)
 				}
 
-				fmt.Println("\nnothing added to commit but untracked files present (use \"orb add\" to track)")
-			} else if len(modifiedFiles) == 0 && len(stagedFiles) == 0 {
+				if len(stagedChanges) == 0 {
+					fmt.Println("\nnothing added to commit but untracked files present (use \"orb add\" to track)")
+				}
+			} else if len(modifiedFiles) == 0 && len(stagedChanges) == 0 {
 				fmt.Println("nothing to commit, working tree clean")
 			}
 
@@ -139,6 +158,9 @@ func newStatusCommand() *cobra.Command {
 		},
 	}
 
+	addRenameFlags(cmd, &renames)
+	parseSimilarities(cmd)
+
 	return cmd
 }
 
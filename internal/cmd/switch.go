@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/ayushsarode/orb/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+func newSwitchCommand() *cobra.Command {
+	var (
+		create  bool
+		detach  bool
+		orphan  bool
+		discard bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "switch [-c | --orphan] <branch> [<start-point>] | switch --detach [<commit>]",
+		Short: "Switch branches",
+		Long: `Switch to a branch, updating the index and working tree to its commit.
+Local changes to files that differ between the two commits stop the
+switch, unless --discard-changes throws them away; other changes are
+carried over.
+
+  -c <new> [<start>]  create the branch at <start> (HEAD by default) first
+  --detach [<commit>] detach HEAD at a commit instead of a branch
+  --orphan <new>      start a branch with no history and an empty tree`,
+		Args: cobra.RangeArgs(0, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options := 0
+			for _, set := range []bool{create, detach, orphan} {
+				if set {
+					options++
+				}
+			}
+			if options > 1 {
+				return fmt.Errorf("only one of -c, --detach and --orphan can be used")
+			}
+
+			switch {
+			case detach:
+				rev := "HEAD"
+				if len(args) > 0 {
+					rev = args[0]
+				}
+				if len(args) > 1 {
+					return fmt.Errorf("--detach takes a single commit")
+				}
+				return detachHead(rev, discard)
+
+			case orphan:
+				if len(args) != 1 {
+					return fmt.Errorf("--orphan takes a single branch name")
+				}
+				return switchToOrphan(args[0], discard)
+
+			case len(args) == 0:
+				return fmt.Errorf("missing branch name; try -c to create one or --detach to leave branches")
+			}
+
+			branch := args[0]
+			if create {
+				start := "HEAD"
+				if len(args) > 1 {
+					start = args[1]
+				}
+				return switchToNewBranch(branch, start, discard)
+			}
+			if len(args) > 1 {
+				return fmt.Errorf("a start point can only be given with -c")
+			}
+
+			if _, err := refs.ReadRef("refs/heads/" + branch); err != nil {
+				// don't silently detach when a commit was meant to be a branch
+				if _, revErr := revision.Resolve(branch); revErr == nil {
+					return fmt.Errorf("a branch is expected, got '%s'; use --detach to switch to a commit", branch)
+				}
+				return fmt.Errorf("invalid reference: %s", branch)
+			}
+			return switchToBranch(branch, discard)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&create, "create", "c", false, "Create a new branch and switch to it")
+	cmd.Flags().BoolVarP(&detach, "detach", "d", false, "Detach HEAD at a commit")
+	cmd.Flags().BoolVar(&orphan, "orphan", false, "Switch to a new branch with no history")
+	cmd.Flags().BoolVarP(&discard, "discard-changes", "f", false, "Throw away local changes that are in the way")
+
+	return cmd
+}
+
+// newBranchStart checks that a branch can be created at a start point and
+// returns the start point's commit, refusing existing names
+func newBranchStart(branch, start string) (string, error) {
+	if err := refs.CheckBranchName(branch); err != nil {
+		return "", err
+	}
+	if _, err := refs.ReadRef("refs/heads/" + branch); err == nil {
+		return "", fmt.Errorf("branch '%s' already exists", branch)
+	}
+
+	hash, err := revision.Resolve(start)
+	if err != nil {
+		return "", fmt.Errorf("not a valid start point '%s': %w", start, err)
+	}
+	return revision.PeelToCommit(hash)
+}
+
+// switchToNewBranch creates a branch at a start point and switches to it.
+// The work tree moves first, so a switch refused over local changes
+// leaves no branch behind; then the branch and HEAD change together.
+func switchToNewBranch(branch, start string, force bool) error {
+	commit, err := newBranchStart(branch, start)
+	if err != nil {
+		return err
+	}
+
+	reason := fmt.Sprintf("checkout: moving from %s to %s", describeHead(), branch)
+	if err := moveWorkTree(commit, force); err != nil {
+		return err
+	}
+
+	tx := refs.NewTransaction()
+	tx.Create("refs/heads/"+branch, commit, "branch: Created from "+start)
+	tx.SetSymbolic("HEAD", "refs/heads/"+branch, reason)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("creating branch: %w", err)
+	}
+	fmt.Printf("Switched to a new branch '%s'\n", branch)
+	return nil
+}
+
+// switchToBranch checks out a branch's commit and points HEAD at it
+func switchToBranch(branch string, force bool) error {
+	if refs.GetCurrentBranch() == branch {
+		fmt.Printf("Already on '%s'\n", branch)
+		return nil
+	}
+
+	reason := fmt.Sprintf("checkout: moving from %s to %s", describeHead(), branch)
+
+	target, err := refs.ReadRef("refs/heads/" + branch)
+	if err != nil {
+		return fmt.Errorf("reading branch %s: %w", branch, err)
+	}
+	if err := moveWorkTree(target, force); err != nil {
+		return err
+	}
+
+	if err := refs.UpdateHead(branch, reason); err != nil {
+		return fmt.Errorf("switching to branch: %w", err)
+	}
+	fmt.Printf("Switched to branch '%s'\n", branch)
+	return nil
+}
+
+// detachHead checks out a commit and points HEAD straight at it
+func detachHead(rev string, force bool) error {
+	hash, err := revision.Resolve(rev)
+	if err != nil {
+		return fmt.Errorf("'%s' is not a commit: %w", rev, err)
+	}
+	commit, err := revision.PeelToCommit(hash)
+	if err != nil {
+		return err
+	}
+
+	reason := fmt.Sprintf("checkout: moving from %s to %s", describeHead(), rev)
+	if err := moveWorkTree(commit, force); err != nil {
+		return err
+	}
+	if err := refs.UpdateHead(commit, reason); err != nil {
+		return fmt.Errorf("checking out commit: %w", err)
+	}
+
+	c, err := objects.ReadCommit(commit)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("HEAD is now at %s %s\n", shortHash(commit), c.Subject())
+	return nil
+}
+
+// switchToOrphan points HEAD at a branch that doesn't exist yet and empties
+// the index and tracked files, so the next commit starts a new history
+func switchToOrphan(branch string, force bool) error {
+	if err := refs.CheckBranchName(branch); err != nil {
+		return err
+	}
+	if _, err := refs.ReadRef("refs/heads/" + branch); err == nil {
+		return fmt.Errorf("branch '%s' already exists", branch)
+	}
+
+	reason := fmt.Sprintf("checkout: moving from %s to %s", describeHead(), branch)
+	if err := moveWorkTree("", force); err != nil {
+		return err
+	}
+
+	// the branch only comes to exist with its first commit
+	if err := refs.WriteSymbolicRef("HEAD", "refs/heads/"+branch, reason); err != nil {
+		return err
+	}
+	fmt.Printf("Switched to a new branch '%s'\n", branch)
+	return nil
+}
+
+// moveWorkTree brings the index and working tree from HEAD's commit to
+// another one; "" is the empty tree of a branch without commits
+func moveWorkTree(commit string, force bool) error {
+	from, err := headFiles()
+	if err != nil {
+		return err
+	}
+
+	toTree := ""
+	if commit != "" {
+		if toTree, err = revision.PeelToTree(commit); err != nil {
+			return err
+		}
+	}
+	to, err := worktree.Files(toTree)
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.LoadIndex()
+	if err != nil {
+		return fmt.Errorf("loading index: %w", err)
+	}
+	if err := worktree.Checkout(idx, from, to, force); err != nil {
+		return err
+	}
+	if err := idx.Write(); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+	return nil
+}
+
+// headFiles returns the files of HEAD's commit; a branch without commits
+// has none
+func headFiles() (map[string]string, error) {
+	tree := ""
+	if head, err := refs.GetHead(); err == nil && head != "" {
+		if tree, err = revision.PeelToTree(head); err != nil {
+			return nil, err
+		}
+	}
+	return worktree.Files(tree)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+func newSymbolicRefCommand() *cobra.Command {
+	var (
+		quiet   bool
+		short   bool
+		remove  bool
+		message string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "symbolic-ref <name> [<ref>]",
+		Short: "Read, modify and delete symbolic refs",
+		Long: `With one argument, print the ref a symbolic ref (such as HEAD) points to.
+With two arguments, point the symbolic ref at the given ref.
+With -d, delete the symbolic ref.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			if remove {
+				return refs.DeleteSymbolicRef(name)
+			}
+
+			// Point the symbolic ref somewhere new
+			if len(args) == 2 {
+				target := args[1]
+				if !strings.HasPrefix(target, "refs/") {
+					return fmt.Errorf("refusing to point %s outside of refs/", name)
+				}
+
+				reason := message
+				if reason == "" {
+					reason = fmt.Sprintf("symbolic-ref: moving to %s", target)
+				}
+				return refs.WriteSymbolicRef(name, target, reason)
+			}
+
+			// Read it
+			target, err := refs.ReadSymbolicRef(name)
+			if err != nil {
+				if quiet {
+					// like git, -q only reports through the exit status
+					os.Exit(1)
+				}
+				return err
+			}
+
+			if short {
+				target = refs.ShortenRef(target)
+			}
+			fmt.Println(target)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Don't report an error if the ref is not symbolic")
+	cmd.Flags().BoolVar(&short, "short", false, "Shorten the printed ref name, e.g. refs/heads/main to main")
+	cmd.Flags().BoolVarP(&remove, "delete", "d", false, "Delete the symbolic ref")
+	cmd.Flags().StringVarP(&message, "message", "m", "", "Reason to record in the reflog")
+
+	return cmd
+}
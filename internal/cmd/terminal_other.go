@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package cmd
+
+// terminalColumns can't ask the terminal here, so leaves the width to
+// COLUMNS or the default
+func terminalColumns() int {
+	return 0
+}
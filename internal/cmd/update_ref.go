@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/refs"
+	"github.com/ayushsarode/orb/internal/revision"
+	"github.com/spf13/cobra"
+)
+
+func newUpdateRefCommand() *cobra.Command {
+	var (
+		message string
+		remove  bool
+		noDeref bool
+		stdin   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "update-ref [-m <reason>] [--no-deref] (-d <ref> [<old>] | <ref> <new> [<old>] | --stdin)",
+		Short: "Safely update the object a ref points at",
+		Long: `Point a ref at a new object, or delete it with -d. When <old> is given
+the ref is only changed if it currently points there; an empty <old> or
+forty zeros means the ref must not exist yet. Symbolic refs such as HEAD
+are followed unless --no-deref is given.
+
+With --stdin every line is one instruction and they all happen in one
+transaction, so either every ref changes or none does:
+
+  update <ref> <new> [<old>]
+  create <ref> <new>
+  delete <ref> [<old>]
+  verify <ref> [<old>]`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tx := refs.NewTransaction()
+
+			switch {
+			case stdin:
+				if len(args) > 0 {
+					return fmt.Errorf("--stdin takes no arguments")
+				}
+				if err := readRefInstructions(tx, message, noDeref); err != nil {
+					return err
+				}
+
+			case remove:
+				if len(args) < 1 || len(args) > 2 {
+					return fmt.Errorf("usage: orb update-ref -d <ref> [<old>]")
+				}
+				oldHash, err := refOldValue(args[1:])
+				if err != nil {
+					return err
+				}
+				tx.Delete(updateRefName(args[0], noDeref), oldHash, message)
+
+			default:
+				if len(args) < 2 || len(args) > 3 {
+					return fmt.Errorf("usage: orb update-ref <ref> <new> [<old>]")
+				}
+				newHash, err := revision.ResolveObject(args[1])
+				if err != nil {
+					return fmt.Errorf("%s: not a valid object name", args[1])
+				}
+				oldHash, err := refOldValue(args[2:])
+				if err != nil {
+					return err
+				}
+				tx.Update(updateRefName(args[0], noDeref), newHash, oldHash, message)
+			}
+
+			return tx.Commit()
+		},
+	}
+
+	cmd.Flags().StringVarP(&message, "message", "m", "", "Reason to record in the reflog")
+	cmd.Flags().BoolVarP(&remove, "delete", "d", false, "Delete the ref")
+	cmd.Flags().BoolVar(&noDeref, "no-deref", false, "Change a symbolic ref itself instead of the ref it points at")
+	cmd.Flags().BoolVar(&stdin, "stdin", false, "Read instructions from standard input and apply them all or none")
+
+	return cmd
+}
+
+// updateRefName returns the ref that actually changes: the end of a chain
+// of symbolic refs, or the ref itself with noDeref
+func updateRefName(ref string, noDeref bool) string {
+	if noDeref {
+		return ref
+	}
+	// a chain ending in a ref that doesn't exist yet still names it
+	name, _, _ := refs.ResolveRef(ref)
+	return name
+}
+
+// refOldValue reads an optional <old> argument. None means no check; an
+// empty value or the zero hash means the ref must not exist.
+func refOldValue(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if args[0] == "" || args[0] == refs.ZeroHash {
+		return refs.ZeroHash, nil
+	}
+	hash, err := revision.ResolveObject(args[0])
+	if err != nil {
+		return "", fmt.Errorf("%s: not a valid old object name", args[0])
+	}
+	return hash, nil
+}
+
+// readRefInstructions queues one transaction step per line of stdin
+func readRefInstructions(tx *refs.Transaction, reason string, noDeref bool) error {
+	scanner := bufio.NewScanner(os.Stdin)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: %s: missing <ref>", line, fields[0])
+		}
+		op, ref, rest := fields[0], updateRefName(fields[1], noDeref), fields[2:]
+
+		switch op {
+		case "update", "create":
+			if len(rest) < 1 || len(rest) > 2 || (op == "create" && len(rest) != 1) {
+				return fmt.Errorf("line %d: %s %s: wrong number of values", line, op, fields[1])
+			}
+			newHash, err := revision.ResolveObject(rest[0])
+			if err != nil {
+				return fmt.Errorf("line %d: %s: not a valid object name", line, rest[0])
+			}
+			if op == "create" {
+				tx.Create(ref, newHash, reason)
+				continue
+			}
+			oldHash, err := refOldValue(rest[1:])
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			tx.Update(ref, newHash, oldHash, reason)
+
+		case "delete", "verify":
+			if len(rest) > 1 {
+				return fmt.Errorf("line %d: %s %s: too many values", line, op, fields[1])
+			}
+			oldHash, err := refOldValue(rest)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if op == "delete" {
+				tx.Delete(ref, oldHash, reason)
+				continue
+			}
+			// verify without a value checks the ref doesn't exist
+			if oldHash == "" {
+				oldHash = refs.ZeroHash
+			}
+			tx.Verify(ref, oldHash)
+
+		default:
+			return fmt.Errorf("line %d: unknown command: %s", line, op)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/spf13/cobra"
+)
+
+func newWriteTreeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "write-tree",
+		Short: "Create a tree object from the index",
+		Long: `Write the staged files as tree objects, one per directory, and print
+the hash of the top-level tree. This is the tree "orb commit" would record.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			idx, err := index.LoadIndex()
+			if err != nil {
+				return fmt.Errorf("loading index: %w", err)
+			}
+
+			treeHash, err := objects.WriteObject(objects.TreeType, buildTreeContent(idx))
+			if err != nil {
+				return fmt.Errorf("writing tree object: %w", err)
+			}
+			fmt.Println(treeHash)
+			return nil
+		},
+	}
+
+	return cmd
+}
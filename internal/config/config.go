@@ -1,9 +1,11 @@
 package config
 
 import (
-	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/ayushsarode/orb/internal/transport"
@@ -11,173 +13,376 @@ import (
 
 const ConfigFile = ".orb/config"
 
-// Config represents the repository configuration
+// Scopes a configuration value can come from, lowest precedence first
+const (
+	ScopeSystem = "system"
+	ScopeGlobal = "global"
+	ScopeLocal  = "local"
+)
+
+// Entry is one value visible in the configuration and the file it came from
+type Entry struct {
+	Key     string // canonical key, e.g. "remote.origin.url"
+	Value   string
+	NoValue bool // the key was given without "=", which counts as true
+	Origin  string
+	Scope   string
+}
+
+// layer is one config file together with the scope it belongs to
+type layer struct {
+	scope string
+	file  *File
+}
+
+// Config represents the configuration. Values are read from the system,
+// global and repository config files (and everything they include), with
+// later files overriding earlier ones. Changes are written to a single
+// file, the repository config unless a scope was chosen, leaving
+// untouched lines exactly as they were.
 type Config struct {
-	Values  map[string]string
-	Remotes []transport.RemoteConfig
+	layers []layer
+	file   *File // where changes are written
+}
+
+// NewConfig returns an empty configuration backed by the repository config file
+func NewConfig() *Config {
+	file := &File{Path: ConfigFile}
+	return &Config{layers: []layer{{scope: ScopeLocal, file: file}}, file: file}
 }
 
-// LoadConfig loads the configuration from the config file
+// LoadConfig loads the system, global and repository configuration;
+// changes are written to the repository config file
 func LoadConfig() (*Config, error) {
-	cfg := &Config{
-		Values:  make(map[string]string),
-		Remotes: []transport.RemoteConfig{},
-	}
+	cfg := &Config{}
 
-	file, err := os.Open(ConfigFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
+	for _, scope := range []string{ScopeSystem, ScopeGlobal, ScopeLocal} {
+		for _, path := range readPaths(scope) {
+			file, err := loadWithIncludes(path, 0)
+			if err != nil {
+				return nil, fmt.Errorf("loading %s config: %w", scope, err)
+			}
+			cfg.layers = append(cfg.layers, layer{scope: scope, file: file})
 		}
-		return nil, fmt.Errorf("opening config file: %w", err)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	var section string
-	var inRemoteSection bool
-	var currentRemote transport.RemoteConfig
+	local := cfg.layers[len(cfg.layers)-1]
+	cfg.file = local.file
+	return cfg, nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+// LoadScope loads only the config file of one scope; reads and changes
+// are limited to that file
+func LoadScope(scope string) (*Config, error) {
+	path, err := WritePath(scope)
+	if err != nil {
+		return nil, err
+	}
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	file, err := loadWithIncludes(path, 0)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s config: %w", scope, err)
+	}
+	return &Config{layers: []layer{{scope: scope, file: file}}, file: file}, nil
+}
+
+// readPaths lists the files read for a scope, lowest precedence first
+func readPaths(scope string) []string {
+	switch scope {
+	case ScopeSystem:
+		if os.Getenv("ORB_CONFIG_NOSYSTEM") != "" {
+			return nil
+		}
+		return []string{systemConfigPath()}
+	case ScopeGlobal:
+		if path := os.Getenv("ORB_CONFIG_GLOBAL"); path != "" {
+			return []string{path}
 		}
+		var paths []string
+		if xdg := xdgConfigPath(); xdg != "" {
+			paths = append(paths, xdg)
+		}
+		if home := homeConfigPath(); home != "" {
+			paths = append(paths, home)
+		}
+		return paths
+	default:
+		return []string{ConfigFile}
+	}
+}
 
-		// Parse section headers: [section]
-		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-			// If we were in a remote section, add the remote to the list
-			if inRemoteSection && currentRemote.Name != "" {
-				cfg.Remotes = append(cfg.Remotes, currentRemote)
-				currentRemote = transport.RemoteConfig{}
+// WritePath returns the file that changes in a scope are written to
+func WritePath(scope string) (string, error) {
+	switch scope {
+	case ScopeSystem:
+		return systemConfigPath(), nil
+	case ScopeGlobal:
+		if path := os.Getenv("ORB_CONFIG_GLOBAL"); path != "" {
+			return path, nil
+		}
+		home := homeConfigPath()
+		// like git, prefer ~/.orbconfig unless only the XDG file exists
+		if xdg := xdgConfigPath(); xdg != "" {
+			if _, err := os.Stat(home); os.IsNotExist(err) {
+				if _, err := os.Stat(xdg); err == nil {
+					return xdg, nil
+				}
 			}
+		}
+		if home == "" {
+			return "", fmt.Errorf("cannot determine the home directory for global config")
+		}
+		return home, nil
+	case ScopeLocal:
+		return ConfigFile, nil
+	}
+	return "", fmt.Errorf("unknown config scope '%s'", scope)
+}
 
-			section = line[1 : len(line)-1]
+// systemConfigPath is /etc/orbconfig unless ORB_CONFIG_SYSTEM says otherwise
+func systemConfigPath() string {
+	if path := os.Getenv("ORB_CONFIG_SYSTEM"); path != "" {
+		return path
+	}
+	return "/etc/orbconfig"
+}
 
-			// Check if this is a remote section
-			if strings.HasPrefix(section, "remote ") {
-				inRemoteSection = true
-				currentRemote.Name = strings.TrimPrefix(section, "remote ")
-			} else {
-				inRemoteSection = false
-			}
-			continue
+// homeConfigPath is ~/.orbconfig
+func homeConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".orbconfig")
+}
+
+// xdgConfigPath is $XDG_CONFIG_HOME/orb/config, defaulting to ~/.config/orb/config
+func xdgConfigPath() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "orb", "config")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "orb", "config")
+}
+
+// Entries returns every value in the order git would see them; later
+// entries override earlier ones for single-valued keys
+func (cfg *Config) Entries() []Entry {
+	var entries []Entry
+	for _, layer := range cfg.layers {
+		collectEntries(layer.file, layer.scope, &entries)
+	}
+	return entries
+}
+
+// TargetEntries returns only the values in the file changes are written to
+func (cfg *Config) TargetEntries() []Entry {
+	var entries []Entry
+	for _, layer := range cfg.layers {
+		if layer.file == cfg.file {
+			collectEntries(layer.file, layer.scope, &entries)
 		}
+	}
+	return entries
+}
 
-		// Parse key-value pairs
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
+// collectEntries appends a file's values, splicing included files in
+// right where they are included
+func collectEntries(file *File, scope string, entries *[]Entry) {
+	for _, l := range file.lines {
+		if l.kind != lineVariable {
 			continue
 		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		// Handle remote section values
-		if inRemoteSection {
-			if key == "url" {
-				currentRemote.URL = value
-			}
-			// Store as remote.<name>.<key> = value for backward compatibility
-			cfg.Values[fmt.Sprintf("remote.%s.%s", currentRemote.Name, key)] = value
-		} else {
-			// Store as section.key = value for regular sections
-			if section != "" {
-				key = section + "." + key
-			}
-			cfg.Values[key] = value
+		*entries = append(*entries, Entry{Key: l.key(), Value: l.value, NoValue: l.noValue, Origin: file.Path, Scope: scope})
+		if l.include != nil {
+			collectEntries(l.include, scope, entries)
 		}
 	}
+}
 
-	// Add the last remote if we were in a remote section
-	if inRemoteSection && currentRemote.Name != "" {
-		cfg.Remotes = append(cfg.Remotes, currentRemote)
+// GetAll returns every value of a multi-valued key, such as remote.origin.fetch
+func (cfg *Config) GetAll(key string) []string {
+	key = CanonicalKey(key)
+	var values []string
+	for _, entry := range cfg.Entries() {
+		if entry.Key == key {
+			values = append(values, entry.Value)
+		}
 	}
+	return values
+}
 
-	return cfg, nil
+// lookup returns the last entry for key
+func (cfg *Config) lookup(key string) (Entry, bool) {
+	key = CanonicalKey(key)
+	entries := cfg.Entries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Key == key {
+			return entries[i], true
+		}
+	}
+	return Entry{}, false
 }
 
 // Get returns a configuration value
 func (cfg *Config) Get(key string) string {
-	return cfg.Values[key]
+	entry, _ := cfg.lookup(key)
+	return entry.Value
 }
 
 // GetString returns a configuration value or a default if not found
 func (cfg *Config) GetString(key, defaultValue string) string {
-	if value, ok := cfg.Values[key]; ok {
-		return value
+	if entry, ok := cfg.lookup(key); ok {
+		return entry.Value
 	}
 	return defaultValue
 }
 
-// Set sets a configuration value
-func (cfg *Config) Set(key, value string) {
-	cfg.Values[key] = value
+// GetBool returns a boolean configuration value, or the default if it is
+// unset or not a valid boolean
+func (cfg *Config) GetBool(key string, defaultValue bool) bool {
+	entry, ok := cfg.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	if entry.NoValue {
+		return true
+	}
+	value, err := ParseBool(entry.Value)
+	if err != nil {
+		return defaultValue
+	}
+	return value
 }
 
-// Save writes the configuration to the config file
-// SaveConfig writes a configuration to the config file
-func SaveConfig(cfg *Config) error {
-	// Open or create config file
-	file, err := os.OpenFile(ConfigFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+// GetInt returns an integer configuration value, understanding k/m/g
+// suffixes, or the default if it is unset or invalid
+func (cfg *Config) GetInt(key string, defaultValue int64) int64 {
+	entry, ok := cfg.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	value, err := ParseInt(entry.Value)
 	if err != nil {
-		return fmt.Errorf("creating config file: %w", err)
+		return defaultValue
 	}
-	defer file.Close()
+	return value
+}
 
-	// Group configuration by section
-	sections := make(map[string]map[string]string)
+// Set sets a configuration value, replacing any existing values of the key
+func (cfg *Config) Set(key, value string) {
+	cfg.file.set(key, value)
+}
 
-	for fullKey, value := range cfg.Values {
-		// Skip remote configurations as we'll handle them separately
-		if strings.HasPrefix(fullKey, "remote.") && strings.Count(fullKey, ".") >= 2 {
-			continue
-		}
+// Add adds another value to a (multi-valued) key
+func (cfg *Config) Add(key, value string) {
+	cfg.file.add(key, value)
+}
 
-		parts := strings.SplitN(fullKey, ".", 2)
+// Unset removes every value of a key and reports how many were removed
+func (cfg *Config) Unset(key string) int {
+	return cfg.file.unsetMatching(key, func(int, *line) bool { return true })
+}
 
-		if len(parts) != 2 {
-			// Keys without a section go into the empty section
-			if sections[""] == nil {
-				sections[""] = make(map[string]string)
-			}
-			sections[""][fullKey] = value
+// UnsetMatching removes the values of a key that match the pattern
+func (cfg *Config) UnsetMatching(key string, pattern *regexp.Regexp) int {
+	return cfg.file.unsetMatching(key, func(_ int, l *line) bool { return pattern.MatchString(l.value) })
+}
+
+// RemoveSection removes every value of a section, e.g. "branch.main"
+func (cfg *Config) RemoveSection(section string) bool {
+	return cfg.file.removeSection(canonicalSection(section))
+}
+
+// RenameSection moves every value of a section to a new section name
+func (cfg *Config) RenameSection(oldSection, newSection string) bool {
+	return cfg.file.renameSection(canonicalSection(oldSection), canonicalSection(newSection))
+}
+
+// Remotes returns the configured remotes in the order they appear
+func (cfg *Config) Remotes() []transport.RemoteConfig {
+	var remotes []transport.RemoteConfig
+	index := make(map[string]int)
+
+	for _, entry := range cfg.Entries() {
+		section, name := splitKey(entry.Key)
+		if !strings.HasPrefix(section, "remote.") || name != "url" {
 			continue
 		}
-
-		section, key := parts[0], parts[1]
-		if sections[section] == nil {
-			sections[section] = make(map[string]string)
+		remoteName := strings.TrimPrefix(section, "remote.")
+		if i, ok := index[remoteName]; ok {
+			remotes[i].URL = entry.Value
+			continue
 		}
-		sections[section][key] = value
+		index[remoteName] = len(remotes)
+		remotes = append(remotes, transport.RemoteConfig{Name: remoteName, URL: entry.Value})
 	}
+	return remotes
+}
 
-	// Write each regular section
-	for section, values := range sections {
-		if section != "" {
-			fmt.Fprintf(file, "[%s]\n", section)
-		}
+// AddRemote records a new remote with the default fetch refspec
+func (cfg *Config) AddRemote(name, url string) {
+	cfg.Set("remote."+name+".url", url)
+	cfg.Add("remote."+name+".fetch", fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", name))
+}
 
-		for key, value := range values {
-			fmt.Fprintf(file, "\t%s = %s\n", key, value)
-		}
+// RemoveRemote forgets a remote, reporting whether it existed
+func (cfg *Config) RemoveRemote(name string) bool {
+	return cfg.RemoveSection("remote." + name)
+}
+
+// SaveConfig writes a configuration to the config file
+func SaveConfig(cfg *Config) error {
+	return cfg.file.Save()
+}
+
+// Save saves the configuration to the config file
+func (cfg *Config) Save() error {
+	return SaveConfig(cfg)
+}
 
-		fmt.Fprintln(file)
+// ParseBool parses a git-style boolean: true/yes/on/1 or false/no/off/0/""
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
 	}
+	return false, fmt.Errorf("bad boolean config value '%s'", value)
+}
 
-	// Write remote sections
-	for _, remote := range cfg.Remotes {
-		fmt.Fprintf(file, "[remote %s]\n", remote.Name)
-		fmt.Fprintf(file, "\turl = %s\n", remote.URL)
-		fmt.Fprintln(file)
+// ParseInt parses an integer with an optional k, m or g suffix (powers of 1024)
+func ParseInt(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("bad numeric config value ''")
 	}
 
-	return nil
+	multiplier := int64(1)
+	switch strings.ToLower(value[len(value)-1:]) {
+	case "k":
+		multiplier = 1024
+	case "m":
+		multiplier = 1024 * 1024
+	case "g":
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad numeric config value '%s'", value)
+	}
+	return n * multiplier, nil
 }
 
-// Save saves the configuration to the config file
-func (cfg *Config) Save() error {
-	return SaveConfig(cfg)
+// ExpandPath expands a leading "~/" in a path value to the home directory
+func ExpandPath(value string) string {
+	return expandHome(value)
 }
@@ -0,0 +1,541 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// kinds of line in a config file
+const (
+	lineOther    = iota // blank lines, comments and anything we don't understand
+	lineSection         // [section] or [section "subsection"]
+	lineVariable        // name = value
+)
+
+// line is one logical line of a config file. raw keeps the exact original
+// text (including continuation lines and the newline) so that a file we
+// didn't change is written back byte for byte.
+type line struct {
+	raw     string
+	kind    int
+	section string // canonical section the line belongs to, e.g. "remote.origin"
+	name    string // lowercased variable name
+	value   string // parsed value
+	noValue bool   // "name" without "=", which means true
+
+	include *File // the file pulled in by an include.path line
+}
+
+// File is a single config file, kept line by line
+type File struct {
+	Path  string
+	lines []*line
+}
+
+// LoadFile reads and parses a config file; a missing file is treated as empty
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &File{Path: path}, nil
+		}
+		return nil, fmt.Errorf("reading config file %s: %w", path, err)
+	}
+
+	file, err := ParseFile(path, string(data))
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// ParseFile parses the text of a config file
+func ParseFile(path, text string) (*File, error) {
+	file := &File{Path: path}
+	section := ""
+
+	for lineNo := 1; text != ""; {
+		raw, rest := nextLogicalLine(text)
+		text = rest
+
+		l, err := parseLine(raw, section)
+		if err != nil {
+			return nil, fmt.Errorf("bad config line %d in %s: %w", lineNo, path, err)
+		}
+		if l.kind == lineSection {
+			section = l.section
+		}
+
+		file.lines = append(file.lines, l)
+		lineNo += strings.Count(raw, "\n")
+	}
+
+	return file, nil
+}
+
+// nextLogicalLine splits off one line, joining lines that end in a
+// backslash continuation (outside of comments)
+func nextLogicalLine(text string) (string, string) {
+	end := 0
+	for {
+		i := strings.IndexByte(text[end:], '\n')
+		if i < 0 {
+			return text, ""
+		}
+		end += i + 1
+
+		physical := strings.TrimRight(text[:end], "\r\n")
+		if !endsWithContinuation(physical) {
+			return text[:end], text[end:]
+		}
+	}
+}
+
+// endsWithContinuation reports whether a line ends in an unescaped,
+// uncommented backslash
+func endsWithContinuation(s string) bool {
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuote = !inQuote
+		case '#', ';':
+			if !inQuote {
+				return false
+			}
+		case '\\':
+			if i == len(s)-1 {
+				return true
+			}
+			i++
+		}
+	}
+	return false
+}
+
+// parseLine parses one logical line; section is the section we are in
+func parseLine(raw, section string) (*line, error) {
+	l := &line{raw: raw, kind: lineOther, section: section}
+	text := strings.TrimSpace(raw)
+
+	if text == "" || text[0] == '#' || text[0] == ';' {
+		return l, nil
+	}
+
+	if text[0] == '[' {
+		name, err := parseSectionHeader(text)
+		if err != nil {
+			return nil, err
+		}
+		l.kind = lineSection
+		l.section = name
+		return l, nil
+	}
+
+	if section == "" {
+		return nil, fmt.Errorf("variable outside of a section")
+	}
+
+	name, rest, hasValue := strings.Cut(text, "=")
+	if !hasValue {
+		// a bare "name" may still carry a comment
+		if i := strings.IndexAny(name, "#;"); i >= 0 {
+			name = name[:i]
+		}
+	}
+	name = strings.TrimSpace(name)
+	if !validVariableName(name) {
+		return nil, fmt.Errorf("invalid variable name '%s'", name)
+	}
+
+	l.kind = lineVariable
+	l.name = strings.ToLower(name)
+	if !hasValue {
+		l.noValue = true
+		return l, nil
+	}
+
+	value, err := parseValue(rest)
+	if err != nil {
+		return nil, err
+	}
+	l.value = value
+	return l, nil
+}
+
+// parseSectionHeader understands [section], [section "subsection"],
+// the old [section.subsection] form and the [remote origin] form older
+// versions of orb wrote
+func parseSectionHeader(text string) (string, error) {
+	end := strings.LastIndex(text, "]")
+	if end < 0 {
+		return "", fmt.Errorf("unterminated section header")
+	}
+
+	// anything after "]" must be a comment
+	if trailing := strings.TrimSpace(text[end+1:]); trailing != "" && trailing[0] != '#' && trailing[0] != ';' {
+		return "", fmt.Errorf("unexpected text after section header")
+	}
+
+	inner := text[1:end]
+	name, sub, hasSub := strings.Cut(inner, " ")
+	name = strings.TrimSpace(name)
+
+	if !hasSub {
+		// [section] or the deprecated [section.subsection]
+		if section, subsection, ok := strings.Cut(name, "."); ok {
+			return strings.ToLower(section) + "." + strings.ToLower(subsection), nil
+		}
+		return strings.ToLower(name), nil
+	}
+
+	sub = strings.TrimSpace(sub)
+	if !strings.HasPrefix(sub, "\"") {
+		// [remote origin] as written by older orb versions
+		return strings.ToLower(name) + "." + sub, nil
+	}
+
+	if len(sub) < 2 || !strings.HasSuffix(sub, "\"") {
+		return "", fmt.Errorf("unterminated subsection name")
+	}
+
+	var b strings.Builder
+	quoted := sub[1 : len(sub)-1]
+	for i := 0; i < len(quoted); i++ {
+		if quoted[i] == '\\' && i+1 < len(quoted) {
+			i++
+		}
+		b.WriteByte(quoted[i])
+	}
+	return strings.ToLower(name) + "." + b.String(), nil
+}
+
+// parseValue decodes the text after "=": quotes, escapes, comments and
+// surrounding whitespace
+func parseValue(text string) (string, error) {
+	var b strings.Builder
+	inQuote := false
+	pendingSpace := ""
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		if !inQuote && (c == ' ' || c == '\t') {
+			// only keep whitespace that has something after it
+			if b.Len() > 0 {
+				pendingSpace += string(c)
+			}
+			continue
+		}
+		if !inQuote && (c == '#' || c == ';') {
+			break
+		}
+		if c == '\r' || c == '\n' {
+			continue
+		}
+
+		b.WriteString(pendingSpace)
+		pendingSpace = ""
+
+		switch c {
+		case '"':
+			inQuote = !inQuote
+		case '\\':
+			if i+1 >= len(text) {
+				return "", fmt.Errorf("bad escape at end of value")
+			}
+			i++
+			switch text[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				// backspace removes the previous character
+				s := b.String()
+				b.Reset()
+				if len(s) > 0 {
+					b.WriteString(s[:len(s)-1])
+				}
+			case '\\', '"':
+				b.WriteByte(text[i])
+			case '\n':
+				// line continuation
+			case '\r':
+				if i+1 < len(text) && text[i+1] == '\n' {
+					i++
+				}
+			default:
+				return "", fmt.Errorf("invalid escape '\\%c'", text[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	if inQuote {
+		return "", fmt.Errorf("unterminated quote in value")
+	}
+	return b.String(), nil
+}
+
+// validVariableName checks a variable name; dots are tolerated because
+// older orb versions wrote keys like "main.remote" under [branch]
+func validVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if i == 0 && !isLetter {
+			return false
+		}
+		if !isLetter && !(c >= '0' && c <= '9') && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatValue quotes and escapes a value so parseValue reads it back unchanged
+func formatValue(value string) string {
+	var b strings.Builder
+	needQuotes := value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;")
+
+	for _, c := range value {
+		switch c {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(c)
+		}
+	}
+
+	if needQuotes {
+		return `"` + b.String() + `"`
+	}
+	return b.String()
+}
+
+// formatSectionHeader writes the header for a canonical section name
+func formatSectionHeader(section string) string {
+	name, sub, ok := strings.Cut(section, ".")
+	if !ok {
+		return "[" + name + "]\n"
+	}
+	sub = strings.ReplaceAll(sub, `\`, `\\`)
+	sub = strings.ReplaceAll(sub, `"`, `\"`)
+	return fmt.Sprintf("[%s \"%s\"]\n", name, sub)
+}
+
+// key returns the canonical "section.subsection.name" key of a variable line
+func (l *line) key() string {
+	return l.section + "." + l.name
+}
+
+// String renders the file back to text
+func (f *File) String() string {
+	var b strings.Builder
+	for _, l := range f.lines {
+		b.WriteString(l.raw)
+	}
+	return b.String()
+}
+
+// Save writes the file through a lock file so readers never see half a config
+func (f *File) Save() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+
+	lockPath := f.Path + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("locking config file: %w", err)
+	}
+
+	if _, err := lock.WriteString(f.String()); err != nil {
+		lock.Close()
+		os.Remove(lockPath)
+		return fmt.Errorf("writing config file: %w", err)
+	}
+	if err := lock.Close(); err != nil {
+		os.Remove(lockPath)
+		return fmt.Errorf("writing config file: %w", err)
+	}
+
+	if err := os.Rename(lockPath, f.Path); err != nil {
+		os.Remove(lockPath)
+		return fmt.Errorf("replacing config file: %w", err)
+	}
+	return nil
+}
+
+// set replaces every value of key with a single value, or adds it
+func (f *File) set(key, value string) {
+	section, name := splitKey(key)
+	key = section + "." + name
+
+	last := -1
+	for i, l := range f.lines {
+		if l.kind == lineVariable && l.key() == key {
+			last = i
+		}
+	}
+	if last < 0 {
+		f.add(key, value)
+		return
+	}
+
+	// keep the last occurrence in place and drop the others
+	f.lines[last] = newVariableLine(section, name, value)
+	f.unsetMatching(key, func(i int, l *line) bool { return i != last })
+}
+
+// add appends a value for key, after the other variables of its section
+func (f *File) add(key, value string) {
+	section, name := splitKey(key)
+	newLine := newVariableLine(section, name, value)
+
+	insertAt := -1
+	for i, l := range f.lines {
+		if l.section == section && (l.kind == lineSection || l.kind == lineVariable) {
+			insertAt = i + 1
+		}
+	}
+
+	if insertAt < 0 {
+		// no such section yet: start one at the end of the file
+		if n := len(f.lines); n > 0 && !strings.HasSuffix(f.lines[n-1].raw, "\n") {
+			f.lines[n-1].raw += "\n"
+		}
+		f.lines = append(f.lines, &line{raw: formatSectionHeader(section), kind: lineSection, section: section}, newLine)
+		return
+	}
+
+	f.lines = append(f.lines[:insertAt], append([]*line{newLine}, f.lines[insertAt:]...)...)
+}
+
+// unsetMatching removes the variable lines of key for which remove returns true
+// and reports how many were removed
+func (f *File) unsetMatching(key string, remove func(i int, l *line) bool) int {
+	key = CanonicalKey(key)
+
+	kept := f.lines[:0]
+	removed := 0
+	for i, l := range f.lines {
+		if l.kind == lineVariable && l.key() == key && remove(i, l) {
+			removed++
+			continue
+		}
+		kept = append(kept, l)
+	}
+	f.lines = kept
+	return removed
+}
+
+// removeSection drops a section's header and everything in it
+func (f *File) removeSection(section string) bool {
+	kept := f.lines[:0]
+	found := false
+	for _, l := range f.lines {
+		if l.section == section || l.legacyIn(section) {
+			found = found || l.kind != lineOther
+			continue
+		}
+		kept = append(kept, l)
+	}
+	f.lines = kept
+	return found
+}
+
+// legacyIn reports whether l is a legacy key of section, like "main.remote"
+// under a bare [branch] for section "branch.main". It has to sit in the
+// parent section itself and name a single variable, so the keys of
+// [branch "main.x"] aren't taken for those of branch.main.
+func (l *line) legacyIn(section string) bool {
+	parent, _, ok := strings.Cut(section, ".")
+	if !ok || l.kind != lineVariable || l.section != parent {
+		return false
+	}
+	name, ok := strings.CutPrefix(l.key(), section+".")
+	return ok && !strings.Contains(name, ".")
+}
+
+// renameSection moves a section and its variables to a new name
+func (f *File) renameSection(oldSection, newSection string) bool {
+	found := false
+
+	// legacy keys can't move with a header, so re-add them under the new section
+	var legacy []*line
+	kept := f.lines[:0]
+	for _, l := range f.lines {
+		if l.legacyIn(oldSection) {
+			legacy = append(legacy, l)
+			continue
+		}
+		kept = append(kept, l)
+	}
+	f.lines = kept
+
+	for _, l := range f.lines {
+		if l.section != oldSection {
+			continue
+		}
+		found = true
+		l.section = newSection
+		if l.kind == lineSection {
+			l.raw = formatSectionHeader(newSection)
+		}
+	}
+
+	for _, l := range legacy {
+		found = true
+		f.add(newSection+"."+strings.TrimPrefix(l.key(), oldSection+"."), l.value)
+	}
+	return found
+}
+
+// newVariableLine builds a freshly formatted "\tname = value" line
+func newVariableLine(section, name, value string) *line {
+	return &line{
+		raw:     fmt.Sprintf("\t%s = %s\n", name, formatValue(value)),
+		kind:    lineVariable,
+		section: section,
+		name:    name,
+		value:   value,
+	}
+}
+
+// splitKey splits "section.subsection.name" into its canonical section
+// ("section.subsection") and lowercased name
+func splitKey(key string) (string, string) {
+	dot := strings.LastIndex(key, ".")
+	if dot < 0 {
+		return strings.ToLower(key), ""
+	}
+	return canonicalSection(key[:dot]), strings.ToLower(key[dot+1:])
+}
+
+// canonicalSection lowercases the section part but keeps the subsection's case
+func canonicalSection(section string) string {
+	name, sub, ok := strings.Cut(section, ".")
+	if !ok {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name) + "." + sub
+}
+
+// CanonicalKey normalizes a key's case the way git compares keys
+func CanonicalKey(key string) string {
+	section, name := splitKey(key)
+	return section + "." + name
+}
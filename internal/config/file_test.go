@@ -0,0 +1,174 @@
+package config
+
+import "testing"
+
+func TestParseFileRoundTrip(t *testing.T) {
+	text := "# top comment\n" +
+		"[core]\n" +
+		"\tbare = false ; inline\n" +
+		"[remote \"origin\"]\n" +
+		"\turl = https://example.com/repo \\\n" +
+		"continued\n" +
+		"\n" +
+		"[branch]\n" +
+		"\tmain.remote = origin\n"
+
+	file, err := ParseFile("config", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := file.String(); got != text {
+		t.Errorf("String() =\n%q\nwant\n%q", got, text)
+	}
+}
+
+func TestSetAndUnset(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		change func(f *File)
+		want   string
+	}{
+		{
+			name:   "set replaces in place",
+			text:   "[core]\n\t# keep me\n\tbare = true\n",
+			change: func(f *File) { f.set("core.bare", "false") },
+			want:   "[core]\n\t# keep me\n\tbare = false\n",
+		},
+		{
+			name:   "set adds to the existing section",
+			text:   "[user]\n\tname = A\n[core]\n\tbare = true\n",
+			change: func(f *File) { f.set("user.email", "a@example.com") },
+			want:   "[user]\n\tname = A\n\temail = a@example.com\n[core]\n\tbare = true\n",
+		},
+		{
+			name:   "set adds a new section",
+			text:   "[core]\n\tbare = true\n",
+			change: func(f *File) { f.set("branch.main.remote", "origin") },
+			want:   "[core]\n\tbare = true\n[branch \"main\"]\n\tremote = origin\n",
+		},
+		{
+			name:   "unset keeps the header",
+			text:   "[core]\n\tbare = true\n\tfilemode = false\n",
+			change: func(f *File) { f.unsetMatching("core.bare", func(int, *line) bool { return true }) },
+			want:   "[core]\n\tfilemode = false\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ParseFile("config", tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(file)
+			if got := file.String(); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveSection(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		section string
+		want    string
+		found   bool
+	}{
+		{
+			name:    "subsection",
+			text:    "[core]\n\tbare = true\n[branch \"foo\"]\n\tremote = origin\n\tmerge = refs/heads/foo\n",
+			section: "branch.foo",
+			want:    "[core]\n\tbare = true\n",
+			found:   true,
+		},
+		{
+			name:    "legacy keys under the bare section",
+			text:    "[branch]\n\tfoo.remote = origin\n\tbar.remote = origin\n",
+			section: "branch.foo",
+			want:    "[branch]\n\tbar.remote = origin\n",
+			found:   true,
+		},
+		{
+			name:    "a subsection whose name starts the same is left alone",
+			text:    "[branch \"foo\"]\n\tremote = origin\n[branch \"foo.bar\"]\n\tremote = upstream\n",
+			section: "branch.foo",
+			want:    "[branch \"foo.bar\"]\n\tremote = upstream\n",
+			found:   true,
+		},
+		{
+			name:    "dotted legacy keys belong to a deeper subsection",
+			text:    "[branch]\n\tfoo.bar.remote = upstream\n",
+			section: "branch.foo",
+			want:    "[branch]\n\tfoo.bar.remote = upstream\n",
+			found:   false,
+		},
+		{
+			name:    "missing",
+			text:    "[core]\n\tbare = true\n",
+			section: "branch.foo",
+			want:    "[core]\n\tbare = true\n",
+			found:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ParseFile("config", tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := file.removeSection(tt.section); found != tt.found {
+				t.Errorf("removeSection() = %v, want %v", found, tt.found)
+			}
+			if got := file.String(); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameSection(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		from, to string
+		want     string
+	}{
+		{
+			name: "subsection",
+			text: "[branch \"foo\"]\n\tremote = origin\n",
+			from: "branch.foo", to: "branch.baz",
+			want: "[branch \"baz\"]\n\tremote = origin\n",
+		},
+		{
+			name: "a subsection whose name starts the same stays",
+			text: "[branch \"foo\"]\n\tremote = origin\n[branch \"foo.bar\"]\n\tremote = upstream\n",
+			from: "branch.foo", to: "branch.baz",
+			want: "[branch \"baz\"]\n\tremote = origin\n[branch \"foo.bar\"]\n\tremote = upstream\n",
+		},
+		{
+			name: "legacy keys move to the new subsection",
+			text: "[branch]\n\tfoo.remote = origin\n\tfoo.bar.remote = upstream\n",
+			from: "branch.foo", to: "branch.baz",
+			want: "[branch]\n\tfoo.bar.remote = upstream\n[branch \"baz\"]\n\tremote = origin\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ParseFile("config", tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !file.renameSection(tt.from, tt.to) {
+				t.Error("renameSection() found nothing")
+			}
+			if got := file.String(); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxIncludeDepth stops include cycles, the same limit git uses
+const maxIncludeDepth = 10
+
+// loadWithIncludes loads a config file and, recursively, the files its
+// [include] and [includeIf "..."] sections pull in
+func loadWithIncludes(path string, depth int) (*File, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("exceeded maximum include depth (%d) while including %s", maxIncludeDepth, path)
+	}
+
+	file, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range file.lines {
+		if l.kind != lineVariable || l.name != "path" || l.noValue {
+			continue
+		}
+
+		switch {
+		case l.section == "include":
+		case strings.HasPrefix(l.section, "includeif."):
+			if !includeConditionMatches(strings.TrimPrefix(l.section, "includeif."), path) {
+				continue
+			}
+		default:
+			continue
+		}
+
+		included, err := loadWithIncludes(resolveIncludePath(l.value, path), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		l.include = included
+	}
+
+	return file, nil
+}
+
+// resolveIncludePath expands "~/" and makes relative paths relative to
+// the directory of the file doing the including
+func resolveIncludePath(includePath, from string) string {
+	includePath = expandHome(includePath)
+	if filepath.IsAbs(includePath) {
+		return includePath
+	}
+	return filepath.Join(filepath.Dir(from), includePath)
+}
+
+// expandHome replaces a leading "~/" with the user's home directory
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+// includeConditionMatches evaluates the condition of an includeIf section.
+// Only "gitdir:" and "gitdir/i:" are understood; anything else is false.
+func includeConditionMatches(condition, from string) bool {
+	var pattern string
+	caseInsensitive := false
+
+	switch {
+	case strings.HasPrefix(condition, "gitdir:"):
+		pattern = strings.TrimPrefix(condition, "gitdir:")
+	case strings.HasPrefix(condition, "gitdir/i:"):
+		pattern = strings.TrimPrefix(condition, "gitdir/i:")
+		caseInsensitive = true
+	default:
+		return false
+	}
+
+	orbDir, err := filepath.Abs(".orb")
+	if err != nil {
+		return false
+	}
+	orbDir = filepath.ToSlash(orbDir) + "/"
+
+	// relative patterns are relative to the including file, bare ones may
+	// match anywhere, and a trailing slash matches everything below
+	switch {
+	case strings.HasPrefix(pattern, "./"):
+		pattern = filepath.ToSlash(filepath.Dir(from)) + pattern[1:]
+	case strings.HasPrefix(pattern, "~/"):
+		pattern = filepath.ToSlash(expandHome(pattern))
+	case !strings.HasPrefix(pattern, "/"):
+		pattern = "**/" + pattern
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+
+	re, err := globToRegexp(pattern, caseInsensitive)
+	if err != nil {
+		return false
+	}
+	// "gitdir:~/work/project" should match the .orb directory inside it
+	return re.MatchString(orbDir) || re.MatchString(strings.TrimSuffix(orbDir, "/"))
+}
+
+// globToRegexp converts a wildmatch-style pattern, where "**" crosses
+// directories and "*" does not, into a regular expression
+func globToRegexp(pattern string, caseInsensitive bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	if caseInsensitive {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,154 @@
+package diff
+
+import "sort"
+
+// histogramMaxChain is how many times a line may occur in the old side and
+// still be tried as an anchor; past it histogram falls back to Myers
+const histogramMaxChain = 64
+
+// patience matches up the lines that occur exactly once on both sides, in
+// the longest run that keeps their order, then diffs between them the same
+// way. Where no line is unique on both sides, Myers takes over.
+func patience(a, b []string) []Edit {
+	if len(a) == 0 || len(b) == 0 {
+		return myers(a, b)
+	}
+
+	countA := make(map[string]int)
+	for _, line := range a {
+		countA[line]++
+	}
+	countB := make(map[string]int)
+	posB := make(map[string]int)
+	for j, line := range b {
+		countB[line]++
+		posB[line] = j
+	}
+
+	// the unique lines in the order of a, with where b has them
+	var pairs [][2]int
+	for i, line := range a {
+		if countA[line] == 1 && countB[line] == 1 {
+			pairs = append(pairs, [2]int{i, posB[line]})
+		}
+	}
+	anchors := longestIncreasing(pairs)
+	if len(anchors) == 0 {
+		return myers(a, b)
+	}
+
+	var edits []Edit
+	prevA, prevB := 0, 0
+	for _, anchor := range anchors {
+		i, j := anchor[0], anchor[1]
+		edits = append(edits, withCommonEnds(a[prevA:i], b[prevB:j], patience)...)
+		edits = append(edits, Edit{Op: Equal, Text: a[i]})
+		prevA, prevB = i+1, j+1
+	}
+	return append(edits, withCommonEnds(a[prevA:], b[prevB:], patience)...)
+}
+
+// longestIncreasing picks the longest run of pairs, already in order of
+// their first index, whose second index goes up too. It is found by
+// patience sorting: each pair goes on the leftmost pile whose top is
+// larger, remembering the top of the pile before.
+func longestIncreasing(pairs [][2]int) [][2]int {
+	var tops []int // index in pairs of the top of each pile
+	prev := make([]int, len(pairs))
+	for k, pair := range pairs {
+		pile := sort.Search(len(tops), func(p int) bool { return pairs[tops[p]][1] > pair[1] })
+		prev[k] = -1
+		if pile > 0 {
+			prev[k] = tops[pile-1]
+		}
+		if pile == len(tops) {
+			tops = append(tops, k)
+		} else {
+			tops[pile] = k
+		}
+	}
+	if len(tops) == 0 {
+		return nil
+	}
+
+	run := make([][2]int, len(tops))
+	for k, n := tops[len(tops)-1], len(tops)-1; k >= 0; k, n = prev[k], n-1 {
+		run[n] = pairs[k]
+	}
+	return run
+}
+
+// histogram finds the longest stretch of matching lines around the lines
+// that occur least often in the old side, keeps it, and diffs the parts
+// before and after the same way. Lines too common to be good anchors leave
+// the work to Myers.
+func histogram(a, b []string) []Edit {
+	if len(a) == 0 || len(b) == 0 {
+		return myers(a, b)
+	}
+
+	occurrences := make(map[string][]int)
+	for i, line := range a {
+		occurrences[line] = append(occurrences[line], i)
+	}
+
+	// the best region so far: where it starts on both sides, how long it
+	// is and how rare its rarest line is
+	found, tooCommon := false, false
+	bestA, bestB, bestLen, bestCount := 0, 0, 0, histogramMaxChain+1
+
+	for j := 0; j < len(b); {
+		next := j + 1
+		positions := occurrences[b[j]]
+		if len(positions) > histogramMaxChain {
+			tooCommon = true
+			j = next
+			continue
+		}
+		for _, i := range positions {
+			// grow the match both ways
+			startA, startB := i, j
+			for startA > 0 && startB > 0 && a[startA-1] == b[startB-1] {
+				startA--
+				startB--
+			}
+			length := i - startA + 1
+			for startA+length < len(a) && startB+length < len(b) && a[startA+length] == b[startB+length] {
+				length++
+			}
+
+			count := histogramMaxChain + 1
+			for k := startA; k < startA+length; k++ {
+				count = min(count, len(occurrences[a[k]]))
+			}
+			if count < bestCount || (count == bestCount && length > bestLen) {
+				found = true
+				bestA, bestB, bestLen, bestCount = startA, startB, length, count
+			}
+			// lines inside this region won't make a better start
+			next = max(next, startB+length)
+		}
+		j = next
+	}
+
+	if !found {
+		if tooCommon {
+			return myers(a, b)
+		}
+		// nothing in common at all
+		var edits []Edit
+		for _, line := range a {
+			edits = append(edits, Edit{Op: Delete, Text: line})
+		}
+		for _, line := range b {
+			edits = append(edits, Edit{Op: Insert, Text: line})
+		}
+		return edits
+	}
+
+	edits := withCommonEnds(a[:bestA], b[:bestB], histogram)
+	for _, line := range a[bestA : bestA+bestLen] {
+		edits = append(edits, Edit{Op: Equal, Text: line})
+	}
+	return append(edits, withCommonEnds(a[bestA+bestLen:], b[bestB+bestLen:], histogram)...)
+}
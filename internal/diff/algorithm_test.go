@@ -0,0 +1,177 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+var algorithms = []Algorithm{Myers, Patience, Histogram}
+
+// sides rebuilds the old and new text an edit script was made from
+func sides(edits []Edit) (string, string) {
+	var a, b strings.Builder
+	for _, edit := range edits {
+		if edit.Op != Insert {
+			a.WriteString(edit.Text)
+		}
+		if edit.Op != Delete {
+			b.WriteString(edit.Text)
+		}
+	}
+	return a.String(), b.String()
+}
+
+// script writes an edit script one line per edit, as in a patch
+func script(edits []Edit) string {
+	var b strings.Builder
+	for _, edit := range edits {
+		switch edit.Op {
+		case Equal:
+			b.WriteByte(' ')
+		case Delete:
+			b.WriteByte('-')
+		case Insert:
+			b.WriteByte('+')
+		}
+		b.WriteString(edit.Text)
+	}
+	return b.String()
+}
+
+func TestCompareRebuildsBothSides(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"both empty", "", ""},
+		{"added from nothing", "", "a\nb\n"},
+		{"deleted to nothing", "a\nb\n", ""},
+		{"equal", "a\nb\nc\n", "a\nb\nc\n"},
+		{"one line changed", "a\nb\nc\n", "a\nX\nc\n"},
+		{"nothing in common", "a\nb\n", "c\nd\n"},
+		{"repeated lines", "x\nx\ny\nx\nx\n", "x\ny\nx\ny\nx\n"},
+		{"moved block", "1\n2\n3\n4\n5\n6\n", "4\n5\n6\n1\n2\n3\n"},
+		{"no final newline", "a\nb", "a\nb\n"},
+		{"only blank lines", "\n\n\n", "\n\n"},
+	}
+
+	for _, tt := range tests {
+		for _, algorithm := range algorithms {
+			t.Run(tt.name+"/"+string(algorithm), func(t *testing.T) {
+				edits := Compare(SplitLines(tt.a), SplitLines(tt.b), Options{Algorithm: algorithm})
+				a, b := sides(edits)
+				if a != tt.a || b != tt.b {
+					t.Errorf("script\n%s\nrebuilds %q -> %q, want %q -> %q", script(edits), a, b, tt.a, tt.b)
+				}
+			})
+		}
+	}
+}
+
+func TestMyersIsShortest(t *testing.T) {
+	a := SplitLines("a\nb\nc\na\nb\nb\na\n")
+	b := SplitLines("c\nb\na\nb\na\nc\n")
+
+	added, deleted := CountChanges(Compare(a, b, Options{Algorithm: Myers}))
+	if added+deleted != 5 {
+		t.Errorf("Myers made %d changes, want 5", added+deleted)
+	}
+}
+
+// Myers is as happy to keep a blank line as a line of code; patience and
+// histogram anchor on the lines that occur once on each side
+func TestUniqueLinesAnchor(t *testing.T) {
+	a := SplitLines("}\nfoo()\n\n\nbar()\n}\n")
+	b := SplitLines("\nfoo()\nbar()\n")
+
+	want := "-}\n+\n foo()\n-\n-\n bar()\n-}\n"
+	for _, algorithm := range []Algorithm{Patience, Histogram} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			if got := script(Compare(a, b, Options{Algorithm: algorithm})); got != want {
+				t.Errorf("script =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+	if got := script(Compare(a, b, Options{Algorithm: Myers})); got == want {
+		t.Errorf("Myers anchored on the unique lines too, so this doesn't tell the algorithms apart")
+	}
+}
+
+func TestWhitespaceOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		opts Options
+		want string
+	}{
+		{
+			name: "-w ignores all whitespace",
+			a:    "if (x) {\n", b: "if(x){\n",
+			opts: Options{IgnoreAllSpace: true},
+			want: " if (x) {\n",
+		},
+		{
+			name: "-b ignores the amount of whitespace",
+			a:    "a  b\nc\n", b: "a b \nc\n",
+			opts: Options{IgnoreSpaceChange: true},
+			want: " a  b\n c\n",
+		},
+		{
+			name: "-b still sees whitespace appear in a word",
+			a:    "ab\n", b: "a b\n",
+			opts: Options{IgnoreSpaceChange: true},
+			want: "-ab\n+a b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, algorithm := range algorithms {
+			t.Run(tt.name+"/"+string(algorithm), func(t *testing.T) {
+				tt.opts.Algorithm = algorithm
+				edits := Compare(SplitLines(tt.a), SplitLines(tt.b), tt.opts)
+				if got := script(edits); got != tt.want {
+					t.Errorf("script =\n%s\nwant\n%s", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestIgnoreBlankLines(t *testing.T) {
+	edits := Compare(SplitLines("a\nb\n"), SplitLines("a\n\n\nb\nc\n"), Options{IgnoreBlankLines: true})
+
+	var ignored, counted []string
+	for _, edit := range edits {
+		switch {
+		case edit.Op == Equal:
+		case edit.Ignored:
+			ignored = append(ignored, edit.Text)
+		default:
+			counted = append(counted, edit.Text)
+		}
+	}
+	if len(ignored) != 2 || len(counted) != 1 || counted[0] != "c\n" {
+		t.Errorf("ignored %q and counted %q, want the two blank lines ignored and c counted", ignored, counted)
+	}
+	if added, deleted := CountChanges(edits); added != 1 || deleted != 0 {
+		t.Errorf("CountChanges() = %d, %d, want 1, 0", added, deleted)
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	tests := map[string]Algorithm{
+		"":          Myers,
+		"default":   Myers,
+		"minimal":   Myers,
+		"Patience":  Patience,
+		"histogram": Histogram,
+	}
+	for name, want := range tests {
+		if got, err := ParseAlgorithm(name); err != nil || got != want {
+			t.Errorf("ParseAlgorithm(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseAlgorithm("fast"); err == nil {
+		t.Error("ParseAlgorithm(\"fast\") didn't fail")
+	}
+}
@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Problem is something --check finds wrong with a line a change adds
+type Problem struct {
+	Path    string
+	Line    int // in the new version of the file
+	Message string
+	Text    string
+}
+
+// conflictMarkers start the lines a merge leaves around a conflict
+var conflictMarkers = []string{"<<<<<<<", "|||||||", "=======", ">>>>>>>"}
+
+// Check looks through the lines a change adds for whitespace errors, the
+// kinds git reports by default, and for conflict markers left behind by a
+// merge. Binary files are never checked.
+func Check(change Change, opts Options) ([]Problem, error) {
+	edits, binary, err := FileEdits(change, opts)
+	if err != nil || binary {
+		return nil, err
+	}
+
+	var problems []Problem
+	line, blankRun := 0, 0
+	for _, edit := range edits {
+		if edit.Op == Delete {
+			continue
+		}
+		line++
+		text := strings.TrimSuffix(edit.Text, "\n")
+		if edit.Op == Equal || edit.Ignored {
+			blankRun = 0
+			continue
+		}
+
+		if strings.TrimSpace(text) == "" {
+			blankRun++
+		} else {
+			blankRun = 0
+		}
+
+		add := func(message string) {
+			problems = append(problems, Problem{Path: change.Path, Line: line, Message: message, Text: text})
+		}
+		if conflictMarker(text) {
+			add("leftover conflict marker")
+		}
+		if strings.TrimRight(text, " \t\r") != text {
+			add("trailing whitespace.")
+		}
+		indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+		if strings.Contains(indent, " \t") {
+			add("space before tab in indent.")
+		}
+	}
+
+	// blank lines added at the very end of the file
+	if blankRun > 0 {
+		start := line - blankRun + 1
+		problems = append(problems, Problem{Path: change.Path, Line: start, Message: "new blank line at EOF."})
+	}
+	return problems, nil
+}
+
+// conflictMarker reports whether a line is a conflict marker: the marker
+// alone or followed by a space, so a line of "=" longer than it isn't one
+func conflictMarker(text string) bool {
+	for _, marker := range conflictMarkers {
+		rest, ok := strings.CutPrefix(text, marker)
+		if ok && (rest == "" || rest[0] == ' ') {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteProblems writes what --check found, the way git does: where the
+// problem is and what it is, then the added line
+func WriteProblems(w io.Writer, problems []Problem) {
+	for _, problem := range problems {
+		fmt.Fprintf(w, "%s:%d: %s\n", problem.Path, problem.Line, problem.Message)
+		if problem.Text != "" {
+			fmt.Fprintf(w, "+%s\n", problem.Text)
+		}
+	}
+}
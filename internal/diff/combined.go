@@ -0,0 +1,318 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// CombinedChange is a file of a merge that differs from every parent, the
+// only files "diff --cc" shows. Hashes and modes are "" where a side has
+// no such file.
+type CombinedChange struct {
+	Path         string
+	ParentHashes []string
+	ParentModes  []string
+	NewHash      string
+	NewMode      string
+}
+
+// CombinedChanges lists the files of a merge's tree that differ from the
+// trees of all of its parents, sorted by path
+func CombinedChanges(parentTrees []string, tree string) ([]CombinedChange, error) {
+	byPath := make(map[string]*CombinedChange)
+	seen := make(map[string]int)
+
+	for i, parentTree := range parentTrees {
+		changes, err := Trees(parentTree, tree)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range changes {
+			combined, ok := byPath[change.Path]
+			if !ok {
+				combined = &CombinedChange{
+					Path:         change.Path,
+					ParentHashes: make([]string, len(parentTrees)),
+					ParentModes:  make([]string, len(parentTrees)),
+					NewHash:      change.NewHash,
+					NewMode:      change.NewMode,
+				}
+				byPath[change.Path] = combined
+			}
+			combined.ParentHashes[i] = change.OldHash
+			combined.ParentModes[i] = change.OldMode
+			seen[change.Path]++
+		}
+	}
+
+	var changes []CombinedChange
+	for path, combined := range byPath {
+		if seen[path] == len(parentTrees) {
+			changes = append(changes, *combined)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// combinedRow is one line of a combined diff: a line of the result, or a
+// line some parents had that the result lost. markers holds one '+', '-'
+// or ' ' per parent.
+type combinedRow struct {
+	text    string
+	lost    bool
+	markers []byte
+}
+
+// interesting reports whether the row differs from any parent
+func (r combinedRow) interesting() bool {
+	for _, m := range r.markers {
+		if m != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// lostLine is a line missing from the result, with the parents that had it
+type lostLine struct {
+	text    string
+	parents []bool
+}
+
+// combinedRows diffs the result against every parent and lines the
+// results up: each line of the result says which parents lack it, and the
+// lines lost from parents are placed before the result line they preceded
+func combinedRows(parents [][]string, result []string) []combinedRow {
+	added := make([][]bool, len(parents))
+	lost := make([][]lostLine, len(result)+1)
+
+	for p, parent := range parents {
+		added[p] = make([]bool, len(result))
+		j := 0
+		var deleted []string
+		flush := func() {
+			lost[j] = mergeLost(lost[j], deleted, p, len(parents))
+			deleted = nil
+		}
+		for _, edit := range Lines(parent, result) {
+			switch edit.Op {
+			case Delete:
+				deleted = append(deleted, edit.Text)
+			case Insert:
+				flush()
+				added[p][j] = true
+				j++
+			default:
+				flush()
+				j++
+			}
+		}
+		flush()
+	}
+
+	var rows []combinedRow
+	for j := 0; j <= len(result); j++ {
+		for _, line := range lost[j] {
+			markers := make([]byte, len(parents))
+			for p, had := range line.parents {
+				markers[p] = ' '
+				if had {
+					markers[p] = '-'
+				}
+			}
+			rows = append(rows, combinedRow{text: line.text, lost: true, markers: markers})
+		}
+		if j == len(result) {
+			break
+		}
+
+		markers := make([]byte, len(parents))
+		for p := range parents {
+			markers[p] = ' '
+			if added[p][j] {
+				markers[p] = '+'
+			}
+		}
+		rows = append(rows, combinedRow{text: result[j], markers: markers})
+	}
+	return rows
+}
+
+// mergeLost adds the lines parent p lost at one spot to the lines other
+// parents lost there, sharing the ones they have in common in order
+func mergeLost(existing []lostLine, deleted []string, p, parents int) []lostLine {
+	pos := 0
+	for _, text := range deleted {
+		found := -1
+		for k := pos; k < len(existing); k++ {
+			if existing[k].text == text {
+				found = k
+				break
+			}
+		}
+		if found < 0 {
+			line := lostLine{text: text, parents: make([]bool, parents)}
+			existing = append(existing[:pos], append([]lostLine{line}, existing[pos:]...)...)
+			found = pos
+		}
+		existing[found].parents[p] = true
+		pos = found + 1
+	}
+	return existing
+}
+
+// WriteCombinedPatch writes a merge's file in the dense combined format of
+// "diff --cc": one marker column per parent, and only hunks where the
+// result differs from every parent
+func WriteCombinedPatch(w io.Writer, change CombinedChange) error {
+	parents := make([][]string, len(change.ParentHashes))
+	binary := false
+	for i, hash := range change.ParentHashes {
+		content, err := ReadBlob(hash)
+		if err != nil {
+			return err
+		}
+		binary = binary || IsBinary(content)
+		parents[i] = SplitLines(string(content))
+	}
+	content, err := ReadBlob(change.NewHash)
+	if err != nil {
+		return err
+	}
+	binary = binary || IsBinary(content)
+
+	fmt.Fprintf(w, "diff --cc %s\n", change.Path)
+	var short []string
+	for _, hash := range change.ParentHashes {
+		if hash == "" {
+			short = append(short, "0000000")
+		} else {
+			short = append(short, abbrev(hash))
+		}
+	}
+	newShort := "0000000"
+	if change.NewHash != "" {
+		newShort = abbrev(change.NewHash)
+	}
+	if change.NewHash == "" {
+		fmt.Fprintf(w, "deleted file mode %s\n", strings.Join(change.ParentModes, ","))
+	}
+	fmt.Fprintf(w, "index %s..%s\n", strings.Join(short, ","), newShort)
+
+	if binary {
+		fmt.Fprintf(w, "Binary files differ\n")
+		return nil
+	}
+
+	rows := combinedRows(parents, SplitLines(string(content)))
+	hunks := combinedHunks(rows, len(parents), DefaultContext)
+	if len(hunks) == 0 {
+		return nil
+	}
+
+	newName := "b/" + change.Path
+	if change.NewHash == "" {
+		newName = "/dev/null"
+	}
+	fmt.Fprintf(w, "--- a/%s\n+++ %s\n", change.Path, newName)
+	for _, hunk := range hunks {
+		fmt.Fprintln(w, hunk.header)
+		for _, row := range hunk.rows {
+			fmt.Fprint(w, string(row.markers), row.text)
+			if !strings.HasSuffix(row.text, "\n") {
+				fmt.Fprint(w, "\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return nil
+}
+
+// combinedHunk is one "@@@ -a,b -c,d +e,f @@@" section
+type combinedHunk struct {
+	header string
+	rows   []combinedRow
+}
+
+// combinedHunks groups rows into hunks the way Hunks groups edits, then
+// drops the hunks that match one of the parents exactly
+func combinedHunks(rows []combinedRow, parents, context int) []combinedHunk {
+	// line numbers reached before each row, per parent and in the result
+	parentPos := make([][]int, parents)
+	for p := range parentPos {
+		parentPos[p] = make([]int, len(rows)+1)
+	}
+	resultPos := make([]int, len(rows)+1)
+	for i, row := range rows {
+		resultPos[i+1] = resultPos[i]
+		if !row.lost {
+			resultPos[i+1]++
+		}
+		for p := range parentPos {
+			parentPos[p][i+1] = parentPos[p][i]
+			if (row.lost && row.markers[p] == '-') || (!row.lost && row.markers[p] == ' ') {
+				parentPos[p][i+1]++
+			}
+		}
+	}
+
+	var hunks []combinedHunk
+	for i := 0; i < len(rows); {
+		if !rows[i].interesting() {
+			i++
+			continue
+		}
+
+		last := i
+		for j := i + 1; j < len(rows) && j-last-1 <= 2*context; j++ {
+			if rows[j].interesting() {
+				last = j
+			}
+		}
+		start := max(0, i-context)
+		stop := min(len(rows), last+context+1)
+		i = stop
+
+		// dense: a hunk that some parent already had adds nothing
+		if !differsFromAll(rows[start:stop], parents) {
+			continue
+		}
+
+		at := strings.Repeat("@", parents+1)
+		header := at
+		for p := range parentPos {
+			header += " -" + combinedRange(parentPos[p][start], parentPos[p][stop])
+		}
+		header += " +" + combinedRange(resultPos[start], resultPos[stop]) + " " + at
+		hunks = append(hunks, combinedHunk{header: header, rows: rows[start:stop]})
+	}
+	return hunks
+}
+
+// differsFromAll reports whether every parent column has a change
+func differsFromAll(rows []combinedRow, parents int) bool {
+	for p := 0; p < parents; p++ {
+		changed := false
+		for _, row := range rows {
+			if row.markers[p] != ' ' {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return false
+		}
+	}
+	return true
+}
+
+// combinedRange formats the lines from one position to another as
+// "start,count", numbering an empty range by the line before it
+func combinedRange(from, to int) string {
+	if to == from {
+		return hunkRange(from, 0)
+	}
+	return hunkRange(from+1, to-from)
+}
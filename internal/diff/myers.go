@@ -0,0 +1,162 @@
+// Package diff compares file contents and trees: line diffs, unified
+// patches and the list of paths that changed between two trees.
+package diff
+
+import "strings"
+
+// Op says what an edit does to a line
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is one line of an edit script turning the old lines into the new ones
+type Edit struct {
+	Op   Op
+	Text string // the line, including its newline if it had one
+
+	// Ignored marks a change that is left out of hunks and counts, like
+	// blank lines with --ignore-blank-lines
+	Ignored bool
+}
+
+// SplitLines splits content into lines that keep their "\n", so a last
+// line without a newline stays different from one with it
+func SplitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns the shortest edit script from a to b, found with Myers'
+// O(ND) algorithm. Lines shared at the start and end are matched up
+// front, which keeps the search small for typical edits.
+func Lines(a, b []string) []Edit {
+	return withCommonEnds(a, b, myers)
+}
+
+// withCommonEnds matches up the lines a and b share at the start and end
+// and leaves the middle to diff
+func withCommonEnds(a, b []string, diff func(a, b []string) []Edit) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Op: Equal, Text: line})
+	}
+	edits = append(edits, diff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Op: Equal, Text: line})
+	}
+	return edits
+}
+
+// myers finds the furthest reaching path for each number of edits d,
+// remembering the frontier of every step so the path can be walked back
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+
+		for k := -d; k <= d; k += 2 {
+			// step down (insert) or right (delete), whichever reaches further
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+
+			// follow the diagonal of matching lines
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the recorded frontiers from the end back to the start,
+// turning each step into an edit
+func backtrack(trace [][]int, a, b []string, offset int) []Edit {
+	var edits []Edit
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Op: Equal, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, Edit{Op: Insert, Text: b[y-1]})
+			} else {
+				edits = append(edits, Edit{Op: Delete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	// the edits were collected back to front
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// CountChanges returns how many lines an edit script adds and removes
+func CountChanges(edits []Edit) (added, deleted int) {
+	for _, edit := range edits {
+		if edit.Ignored {
+			continue
+		}
+		switch edit.Op {
+		case Insert:
+			added++
+		case Delete:
+			deleted++
+		}
+	}
+	return added, deleted
+}
@@ -0,0 +1,182 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Algorithm is how the lines of two versions are matched up
+type Algorithm string
+
+// The diff algorithms, by the names --diff-algorithm takes
+const (
+	// Myers finds the shortest edit script
+	Myers Algorithm = "myers"
+
+	// Patience matches up lines that occur once on each side first, which
+	// keeps moved blocks and refactored functions readable
+	Patience Algorithm = "patience"
+
+	// Histogram is patience extended to lines that aren't unique, trying
+	// the rarest lines first
+	Histogram Algorithm = "histogram"
+)
+
+// ParseAlgorithm reads an algorithm name; "default" and "minimal" are
+// Myers, which always finds the shortest script here
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch strings.ToLower(name) {
+	case "", "default", "myers", "minimal":
+		return Myers, nil
+	case "patience":
+		return Patience, nil
+	case "histogram":
+		return Histogram, nil
+	}
+	return "", fmt.Errorf("unknown diff algorithm '%s'", name)
+}
+
+// Word diff modes, the values --word-diff takes
+const (
+	WordDiffPlain     = "plain"
+	WordDiffColor     = "color"
+	WordDiffPorcelain = "porcelain"
+)
+
+// DefaultWordRegex is what a word is unless told otherwise: a run of
+// anything but whitespace
+const DefaultWordRegex = `[^[:space:]]+`
+
+// Options say how lines are compared and how a patch shows them. The
+// zero value is a plain Myers diff.
+type Options struct {
+	Algorithm Algorithm
+
+	// IgnoreAllSpace compares lines without any of their whitespace (-w),
+	// and IgnoreSpaceChange takes any run of whitespace as one space and
+	// ignores it at the end of lines (-b)
+	IgnoreAllSpace    bool
+	IgnoreSpaceChange bool
+
+	// IgnoreBlankLines leaves out changes that only add or remove blank
+	// lines
+	IgnoreBlankLines bool
+
+	// WordDiff shows changed words inside lines instead of whole lines,
+	// as one of the WordDiff modes; "" shows lines
+	WordDiff string
+
+	// WordRegex is what a word is for WordDiff; nil is DefaultWordRegex
+	WordRegex *regexp.Regexp
+}
+
+// ParseWordDiff checks a --word-diff mode; "none" turns it off
+func ParseWordDiff(mode string) (string, error) {
+	switch mode {
+	case WordDiffPlain, WordDiffColor, WordDiffPorcelain:
+		return mode, nil
+	case "none", "":
+		return "", nil
+	}
+	return "", fmt.Errorf("bad --word-diff argument: %s", mode)
+}
+
+// Compare returns the edit script from a to b the way opts say. Lines
+// that only differ in ignored whitespace count as equal and keep the old
+// side's text.
+func Compare(a, b []string, opts Options) []Edit {
+	keysA, keysB := a, b
+	if opts.IgnoreAllSpace || opts.IgnoreSpaceChange {
+		keysA, keysB = whitespaceKeys(a, opts), whitespaceKeys(b, opts)
+	}
+
+	var edits []Edit
+	switch opts.Algorithm {
+	case Patience:
+		edits = withCommonEnds(keysA, keysB, patience)
+	case Histogram:
+		edits = withCommonEnds(keysA, keysB, histogram)
+	default:
+		edits = Lines(keysA, keysB)
+	}
+
+	if opts.IgnoreAllSpace || opts.IgnoreSpaceChange {
+		restoreText(edits, a, b)
+	}
+	if opts.IgnoreBlankLines {
+		ignoreBlankChanges(edits)
+	}
+	return edits
+}
+
+// whitespaceKeys turns lines into what is compared when some whitespace
+// doesn't count
+func whitespaceKeys(lines []string, opts Options) []string {
+	keys := make([]string, len(lines))
+	for i, line := range lines {
+		if opts.IgnoreAllSpace {
+			keys[i] = strings.Join(strings.Fields(line), "")
+			continue
+		}
+
+		var key strings.Builder
+		inSpace := false
+		for _, r := range strings.TrimRightFunc(line, unicode.IsSpace) {
+			if unicode.IsSpace(r) {
+				inSpace = true
+				continue
+			}
+			if inSpace {
+				key.WriteByte(' ')
+				inSpace = false
+			}
+			key.WriteRune(r)
+		}
+		keys[i] = key.String()
+	}
+	return keys
+}
+
+// restoreText puts the real lines back into an edit script made from
+// their keys
+func restoreText(edits []Edit, a, b []string) {
+	i, j := 0, 0
+	for k := range edits {
+		switch edits[k].Op {
+		case Equal:
+			edits[k].Text = a[i]
+			i++
+			j++
+		case Delete:
+			edits[k].Text = a[i]
+			i++
+		case Insert:
+			edits[k].Text = b[j]
+			j++
+		}
+	}
+}
+
+// ignoreBlankChanges marks every run of changes made only of blank lines
+func ignoreBlankChanges(edits []Edit) {
+	for start := 0; start < len(edits); {
+		if edits[start].Op == Equal {
+			start++
+			continue
+		}
+		end, blank := start, true
+		for ; end < len(edits) && edits[end].Op != Equal; end++ {
+			if strings.TrimSpace(edits[end].Text) != "" {
+				blank = false
+			}
+		}
+		if blank {
+			for k := start; k < end; k++ {
+				edits[k].Ignored = true
+			}
+		}
+		start = end
+	}
+}
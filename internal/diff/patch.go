@@ -0,0 +1,136 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// binaryCheckLen is how much of a file is searched for a NUL byte, the same
+// heuristic git uses to decide a file is binary
+const binaryCheckLen = 8000
+
+// IsBinary reports whether content looks like a binary file
+func IsBinary(content []byte) bool {
+	if len(content) > binaryCheckLen {
+		content = content[:binaryCheckLen]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// ReadBlob returns a blob's content; "" is an empty file
+func ReadBlob(hash string) ([]byte, error) {
+	if hash == "" {
+		return nil, nil
+	}
+
+	objType, content, err := objects.ReadObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", hash, err)
+	}
+	if objType != objects.BlobType {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", hash, objType)
+	}
+	return content, nil
+}
+
+// FileEdits diffs the old and new contents of a change line by line, the
+// way opts say. The second result is true when either side is binary, in
+// which case there are no edits.
+func FileEdits(change Change, opts Options) ([]Edit, bool, error) {
+	oldContent, err := ReadBlob(change.OldHash)
+	if err != nil {
+		return nil, false, err
+	}
+	newContent, err := ReadBlob(change.NewHash)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if IsBinary(oldContent) || IsBinary(newContent) {
+		return nil, true, nil
+	}
+	return Compare(SplitLines(string(oldContent)), SplitLines(string(newContent)), opts), false, nil
+}
+
+// WritePatch writes a change as a git-style patch: the "diff --git" header,
+// the mode and index lines, then the hunks, line by line or word by word
+func WritePatch(w io.Writer, change Change, opts Options) error {
+	edits, binary, err := FileEdits(change, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "diff --git a/%s b/%s\n", change.SourcePath(), change.Path)
+	switch change.Status {
+	case Renamed, Copied:
+		verb := "rename"
+		if change.Status == Copied {
+			verb = "copy"
+		}
+		fmt.Fprintf(w, "similarity index %d%%\n", change.Score)
+		fmt.Fprintf(w, "%s from %s\n%s to %s\n", verb, change.OldPath, verb, change.Path)
+		switch {
+		case change.OldMode != change.NewMode:
+			fmt.Fprintf(w, "old mode %s\nnew mode %s\n", change.OldMode, change.NewMode)
+			if change.OldHash != change.NewHash {
+				fmt.Fprintf(w, "index %s..%s\n", abbrev(change.OldHash), abbrev(change.NewHash))
+			}
+		case change.OldHash != change.NewHash:
+			fmt.Fprintf(w, "index %s..%s %s\n", abbrev(change.OldHash), abbrev(change.NewHash), change.NewMode)
+		}
+	case Added:
+		fmt.Fprintf(w, "new file mode %s\n", change.NewMode)
+		fmt.Fprintf(w, "index 0000000..%s\n", abbrev(change.NewHash))
+	case Deleted:
+		fmt.Fprintf(w, "deleted file mode %s\n", change.OldMode)
+		fmt.Fprintf(w, "index %s..0000000\n", abbrev(change.OldHash))
+	default:
+		if change.OldMode != change.NewMode {
+			fmt.Fprintf(w, "old mode %s\nnew mode %s\n", change.OldMode, change.NewMode)
+			fmt.Fprintf(w, "index %s..%s\n", abbrev(change.OldHash), abbrev(change.NewHash))
+		} else {
+			fmt.Fprintf(w, "index %s..%s %s\n", abbrev(change.OldHash), abbrev(change.NewHash), change.NewMode)
+		}
+	}
+
+	oldName, newName := "a/"+change.SourcePath(), "b/"+change.Path
+	if change.Status == Added {
+		oldName = "/dev/null"
+	}
+	if change.Status == Deleted {
+		newName = "/dev/null"
+	}
+
+	if binary {
+		// a binary file renamed as it was has nothing more to show
+		if change.OldHash != change.NewHash {
+			fmt.Fprintf(w, "Binary files %s and %s differ\n", oldName, newName)
+		}
+		return nil
+	}
+
+	hunks := Hunks(edits, DefaultContext)
+	if len(hunks) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName)
+	if opts.WordDiff != "" {
+		for _, hunk := range hunks {
+			WriteWordDiff(w, hunk, opts)
+		}
+		return nil
+	}
+	WriteHunks(w, hunks)
+	return nil
+}
+
+// abbrev shortens a hash for the index line
+func abbrev(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
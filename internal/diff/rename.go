@@ -0,0 +1,307 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rename detection defaults, the same as git's
+const (
+	DefaultRenameThreshold = 50
+	DefaultRenameLimit     = 1000
+)
+
+// RenameOptions say how DetectRenames pairs up files
+type RenameOptions struct {
+	// Threshold is how similar, in percent, two files must be to pair up
+	// when their contents aren't the same
+	Threshold int
+
+	// Copies also pairs new files with the old side of modified files,
+	// and a deleted file with more than one new file
+	Copies bool
+
+	// Limit keeps large changes fast: similar files aren't looked for when
+	// there are more than Limit*Limit pairs to compare. 0 means no limit.
+	Limit int
+}
+
+// ParseSimilarity reads a threshold the way -M and -C take it: "90%" is
+// 90 percent, and bare digits are a fraction, so "9" and "90" are 90
+// percent and "05" is 5
+func ParseSimilarity(value string) (int, error) {
+	bad := fmt.Errorf("invalid similarity '%s'", value)
+
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		n, err := strconv.Atoi(percent)
+		if err != nil || n < 0 || n > 100 {
+			return 0, bad
+		}
+		return n, nil
+	}
+
+	if value == "" || strings.Trim(value, "0123456789") != "" {
+		return 0, bad
+	}
+	// only the first two digits after the point matter for a percentage
+	digits := (value + "00")[:2]
+	n, _ := strconv.Atoi(digits)
+	return n, nil
+}
+
+// similarityCandidate is a possible pairing of a source and a new file
+type similarityCandidate struct {
+	source, dest int
+	score        int
+}
+
+// fileLines is a blob cut into lines for scoring, with how many times
+// each line shows up
+type fileLines struct {
+	size  int
+	lines map[string]int
+}
+
+// DetectRenames pairs up deleted and added files that hold the same or
+// similar content, turning them into renames, and with opts.Copies pairs
+// added files with files that stayed too, turning them into copies.
+// Exact matches are found first; the rest are scored by how much content
+// they share. When there are too many files to look for similar ones,
+// only exact matches are found and the second result is the limit that
+// would have been enough; otherwise it is 0.
+func DetectRenames(changes []Change, opts RenameOptions) ([]Change, int, error) {
+	var sources, dests []int
+	for i, change := range changes {
+		switch {
+		case change.Status == Added:
+			dests = append(dests, i)
+		case change.Status == Deleted:
+			sources = append(sources, i)
+		case change.Status == Modified && opts.Copies:
+			sources = append(sources, i)
+		}
+	}
+	if len(dests) == 0 || len(sources) == 0 {
+		return changes, 0, nil
+	}
+
+	paired := make(map[int]int)   // new file to its source
+	renamed := make(map[int]bool) // deleted files that found a new name
+	scores := make(map[int]int)   // similarity of the inexact pairs
+
+	// a deleted file can only be renamed once, but copied any number of
+	// times; the old side of a modified file can only be copied
+	available := func(s int) bool {
+		return !renamed[s] || opts.Copies
+	}
+	pair := func(d, s, score int) {
+		paired[d] = s
+		scores[d] = score
+		if changes[s].Status == Deleted {
+			renamed[s] = true
+		}
+	}
+
+	// exact matches first, preferring a source with the same file name
+	byHash := make(map[string][]int)
+	for _, s := range sources {
+		if hash := changes[s].OldHash; hash != emptyBlob {
+			byHash[hash] = append(byHash[hash], s)
+		}
+	}
+	var left []int
+	for _, d := range dests {
+		if s, ok := exactSource(changes, byHash[changes[d].NewHash], d, available); ok {
+			pair(d, s, 100)
+			continue
+		}
+		left = append(left, d)
+	}
+
+	var unpaired []int
+	for _, s := range sources {
+		if available(s) {
+			unpaired = append(unpaired, s)
+		}
+	}
+
+	needed := 0
+	if len(left) > 0 && len(unpaired) > 0 {
+		if opts.Limit > 0 && len(left)*len(unpaired) > opts.Limit*opts.Limit {
+			needed = max(len(left), len(unpaired))
+		} else {
+			candidates, err := scoreCandidates(changes, unpaired, left, opts.Threshold)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, c := range candidates {
+				if _, done := paired[c.dest]; !done && available(c.source) {
+					pair(c.dest, c.source, c.score)
+				}
+			}
+		}
+	}
+
+	// a deleted file goes to its first new name, in path order, as a
+	// rename; any other new file made from it is a copy
+	usedAsRename := make(map[int]bool)
+	var result []Change
+	for i, change := range changes {
+		if change.Status == Deleted && renamed[i] {
+			continue
+		}
+		s, ok := paired[i]
+		if !ok {
+			result = append(result, change)
+			continue
+		}
+
+		source := changes[s]
+		status := byte(Copied)
+		if source.Status == Deleted && !usedAsRename[s] {
+			status = Renamed
+			usedAsRename[s] = true
+		}
+		result = append(result, Change{
+			Status:  status,
+			Path:    change.Path,
+			OldPath: source.Path,
+			OldHash: source.OldHash,
+			NewHash: change.NewHash,
+			OldMode: source.OldMode,
+			NewMode: change.NewMode,
+			Score:   scores[i],
+		})
+	}
+	return result, needed, nil
+}
+
+// emptyBlob is the hash of an empty file; empty files are never paired,
+// since any two of them would match
+const emptyBlob = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+
+// exactSource picks the source of an exact match among those still
+// available, preferring one with the same file name
+func exactSource(changes []Change, matches []int, dest int, available func(int) bool) (int, bool) {
+	best, found := 0, false
+	name := baseName(changes[dest].Path)
+	for _, s := range matches {
+		if !available(s) {
+			continue
+		}
+		if !found {
+			best, found = s, true
+		}
+		if baseName(changes[s].Path) == name {
+			return s, true
+		}
+	}
+	return best, found
+}
+
+// scoreCandidates scores every source against every new file and returns
+// the pairs at or above the threshold, best first
+func scoreCandidates(changes []Change, sources, dests []int, threshold int) ([]similarityCandidate, error) {
+	cache := make(map[string]*fileLines)
+	load := func(hash string) (*fileLines, error) {
+		if f, ok := cache[hash]; ok {
+			return f, nil
+		}
+		content, err := ReadBlob(hash)
+		if err != nil {
+			return nil, err
+		}
+		f := &fileLines{size: len(content), lines: make(map[string]int)}
+		for _, line := range SplitLines(string(content)) {
+			f.lines[line]++
+		}
+		cache[hash] = f
+		return f, nil
+	}
+
+	var candidates []similarityCandidate
+	for _, d := range dests {
+		dest, err := load(changes[d].NewHash)
+		if err != nil {
+			return nil, err
+		}
+		if dest.size == 0 {
+			continue
+		}
+		for _, s := range sources {
+			source, err := load(changes[s].OldHash)
+			if err != nil {
+				return nil, err
+			}
+			if source.size == 0 {
+				continue
+			}
+
+			// files too different in size can't reach the threshold
+			larger := max(source.size, dest.size)
+			if min(source.size, dest.size)*100 < threshold*larger {
+				continue
+			}
+
+			score := similarity(source, dest) * 100 / larger
+			if score >= threshold {
+				candidates = append(candidates, similarityCandidate{source: s, dest: d, score: score})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if changes[a.dest].Path != changes[b.dest].Path {
+			return changes[a.dest].Path < changes[b.dest].Path
+		}
+		return changes[a.source].Path < changes[b.source].Path
+	})
+	return candidates, nil
+}
+
+// similarity counts the bytes of the lines two files have in common
+func similarity(a, b *fileLines) int {
+	if len(b.lines) < len(a.lines) {
+		a, b = b, a
+	}
+	common := 0
+	for line, count := range a.lines {
+		common += min(count, b.lines[line]) * len(line)
+	}
+	return common
+}
+
+// baseName is the last part of a slash-separated path
+func baseName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// RenamePath shows a rename or copy the way --stat does, with the parts
+// both paths share outside the braces: "dir/{old.txt => new.txt}"
+func RenamePath(oldPath, newPath string) string {
+	// the shared prefix and suffix end and start at a slash
+	prefix := 0
+	for i := 0; i < len(oldPath) && i < len(newPath) && oldPath[i] == newPath[i]; i++ {
+		if oldPath[i] == '/' {
+			prefix = i + 1
+		}
+	}
+	suffix := 0
+	for i := 1; i <= len(oldPath)-prefix && i <= len(newPath)-prefix && oldPath[len(oldPath)-i] == newPath[len(newPath)-i]; i++ {
+		if oldPath[len(oldPath)-i] == '/' {
+			suffix = i
+		}
+	}
+
+	if prefix == 0 && suffix == 0 {
+		return oldPath + " => " + newPath
+	}
+	return fmt.Sprintf("%s{%s => %s}%s", oldPath[:prefix],
+		oldPath[prefix:len(oldPath)-suffix], newPath[prefix:len(newPath)-suffix], oldPath[len(oldPath)-suffix:])
+}
@@ -0,0 +1,119 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FileStat is how many lines a change adds and removes
+type FileStat struct {
+	Path    string
+	Added   int
+	Deleted int
+	Binary  bool
+}
+
+// Stats counts the added and removed lines of every change, comparing
+// lines the way opts say
+func Stats(changes []Change, opts Options) ([]FileStat, error) {
+	var stats []FileStat
+	for _, change := range changes {
+		edits, binary, err := FileEdits(change, opts)
+		if err != nil {
+			return nil, err
+		}
+		added, deleted := CountChanges(edits)
+		path := change.Path
+		if change.OldPath != "" {
+			path = RenamePath(change.OldPath, change.Path)
+		}
+		stats = append(stats, FileStat{Path: path, Added: added, Deleted: deleted, Binary: binary})
+	}
+	return stats, nil
+}
+
+// WriteStat writes the --stat view: a "path | 3 ++-" line per file, with
+// the bars scaled down to fit in width columns, then the summary line
+func WriteStat(w io.Writer, stats []FileStat, width int) {
+	if len(stats) == 0 {
+		return
+	}
+
+	nameWidth, maxChanges := 0, 0
+	for _, stat := range stats {
+		nameWidth = max(nameWidth, len(stat.Path))
+		maxChanges = max(maxChanges, stat.Added+stat.Deleted)
+	}
+	countWidth := len(fmt.Sprint(maxChanges))
+
+	// " name | count " comes before the bar
+	barWidth := width - nameWidth - countWidth - 5
+	if barWidth < 10 {
+		barWidth = 10
+	}
+
+	for _, stat := range stats {
+		if stat.Binary {
+			fmt.Fprintf(w, " %-*s | %*s\n", nameWidth, stat.Path, countWidth, "Bin")
+			continue
+		}
+
+		added, deleted := stat.Added, stat.Deleted
+		if maxChanges > barWidth {
+			added = scaleBar(added, maxChanges, barWidth)
+			deleted = scaleBar(deleted, maxChanges, barWidth)
+		}
+
+		line := fmt.Sprintf(" %-*s | %*d %s%s", nameWidth, stat.Path, countWidth, stat.Added+stat.Deleted,
+			strings.Repeat("+", added), strings.Repeat("-", deleted))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+	fmt.Fprintln(w, Summary(stats))
+}
+
+// WriteNumstat writes the --numstat view: added and removed lines and the
+// path, tab-separated, with "-" for the counts of binary files
+func WriteNumstat(w io.Writer, stats []FileStat) {
+	for _, stat := range stats {
+		if stat.Binary {
+			fmt.Fprintf(w, "-\t-\t%s\n", stat.Path)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\n", stat.Added, stat.Deleted, stat.Path)
+	}
+}
+
+// scaleBar scales a count down to the bar width, keeping any change visible
+func scaleBar(n, maxChanges, barWidth int) int {
+	if n == 0 {
+		return 0
+	}
+	return max(1, n*barWidth/maxChanges)
+}
+
+// Summary returns the " 2 files changed, 3 insertions(+), 1 deletion(-)" line
+func Summary(stats []FileStat) string {
+	added, deleted := 0, 0
+	for _, stat := range stats {
+		added += stat.Added
+		deleted += stat.Deleted
+	}
+
+	summary := fmt.Sprintf(" %d %s changed", len(stats), plural(len(stats), "file", "files"))
+	if added > 0 || deleted == 0 {
+		summary += fmt.Sprintf(", %d %s(+)", added, plural(added, "insertion", "insertions"))
+	}
+	if deleted > 0 || added == 0 {
+		summary += fmt.Sprintf(", %d %s(-)", deleted, plural(deleted, "deletion", "deletions"))
+	}
+	return summary
+}
+
+// plural picks the singular or plural word for n
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
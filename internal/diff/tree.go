@@ -0,0 +1,193 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// Change statuses, the letters git uses in --name-status
+const (
+	Added    = 'A'
+	Deleted  = 'D'
+	Modified = 'M'
+	Renamed  = 'R'
+	Copied   = 'C'
+)
+
+// Change is a file that differs between two trees. The old side is empty
+// for added files and the new side is empty for deleted ones. Renames and
+// copies come from DetectRenames and also carry the old path and how
+// similar the two sides are.
+type Change struct {
+	Status  byte
+	Path    string
+	OldHash string
+	NewHash string
+	OldMode string
+	NewMode string
+	OldPath string
+	Score   int
+}
+
+// SourcePath is the path of the old side of a change
+func (c Change) SourcePath() string {
+	if c.OldPath != "" {
+		return c.OldPath
+	}
+	return c.Path
+}
+
+// Trees lists the files that differ between two trees, sorted by path.
+// Either tree may be "" to stand for an empty tree.
+func Trees(oldTree, newTree string) ([]Change, error) {
+	var changes []Change
+	if err := diffTrees(oldTree, newTree, "", &changes); err != nil {
+		return nil, err
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// WriteNames writes the --name-only view, a path per line, or with status
+// the --name-status view: the status letter, then the path. Renames and
+// copies add their similarity to the letter and show both paths.
+func WriteNames(w io.Writer, changes []Change, status bool) {
+	for _, change := range changes {
+		switch {
+		case !status:
+			fmt.Fprintln(w, change.Path)
+		case change.OldPath != "":
+			fmt.Fprintf(w, "%c%03d\t%s\t%s\n", change.Status, change.Score, change.OldPath, change.Path)
+		default:
+			fmt.Fprintf(w, "%c\t%s\n", change.Status, change.Path)
+		}
+	}
+}
+
+// Files lists the files that differ between two sets of files, each a map
+// from path to blob hash, sorted by path. Such sets carry no modes, so
+// every file is taken to be a regular one.
+func Files(oldFiles, newFiles map[string]string) []Change {
+	var changes []Change
+	for path, oldHash := range oldFiles {
+		newHash, ok := newFiles[path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Status: Deleted, Path: path, OldHash: oldHash, OldMode: regularMode})
+		case newHash != oldHash:
+			changes = append(changes, Change{Status: Modified, Path: path, OldHash: oldHash, NewHash: newHash,
+				OldMode: regularMode, NewMode: regularMode})
+		}
+	}
+	for path, newHash := range newFiles {
+		if _, ok := oldFiles[path]; !ok {
+			changes = append(changes, Change{Status: Added, Path: path, NewHash: newHash, NewMode: regularMode})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// regularMode is the mode of a plain file
+const regularMode = "100644"
+
+// diffTrees compares two trees entry by entry, descending into
+// subdirectories that differ
+func diffTrees(oldTree, newTree, prefix string, changes *[]Change) error {
+	if oldTree == newTree {
+		return nil
+	}
+
+	oldEntries, err := readTreeMap(oldTree)
+	if err != nil {
+		return err
+	}
+	newEntries, err := readTreeMap(newTree)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for name := range oldEntries {
+		names[name] = true
+	}
+	for name := range newEntries {
+		names[name] = true
+	}
+
+	for name := range names {
+		oldEntry, inOld := oldEntries[name]
+		newEntry, inNew := newEntries[name]
+		path := prefix + name
+
+		if inOld && inNew && oldEntry.Hash == newEntry.Hash && oldEntry.Mode == newEntry.Mode {
+			continue
+		}
+
+		// a path that stays a directory is compared inside
+		if inOld && inNew && oldEntry.IsTree() && newEntry.IsTree() {
+			if err := diffTrees(oldEntry.Hash, newEntry.Hash, path+"/", changes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// a file that stays a file is a modification
+		if inOld && inNew && !oldEntry.IsTree() && !newEntry.IsTree() {
+			*changes = append(*changes, Change{Status: Modified, Path: path, OldHash: oldEntry.Hash, NewHash: newEntry.Hash,
+				OldMode: oldEntry.Mode, NewMode: newEntry.Mode})
+			continue
+		}
+
+		// anything else is the old side going away and the new side appearing
+		if inOld {
+			if err := addSide(oldEntry, path, Deleted, changes); err != nil {
+				return err
+			}
+		}
+		if inNew {
+			if err := addSide(newEntry, path, Added, changes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addSide records an entry that exists on only one side, expanding
+// directories into every file below them
+func addSide(entry objects.TreeEntry, path string, status byte, changes *[]Change) error {
+	if entry.IsTree() {
+		if status == Added {
+			return diffTrees("", entry.Hash, path+"/", changes)
+		}
+		return diffTrees(entry.Hash, "", path+"/", changes)
+	}
+
+	if status == Added {
+		*changes = append(*changes, Change{Status: Added, Path: path, NewHash: entry.Hash, NewMode: entry.Mode})
+	} else {
+		*changes = append(*changes, Change{Status: Deleted, Path: path, OldHash: entry.Hash, OldMode: entry.Mode})
+	}
+	return nil
+}
+
+// readTreeMap reads a tree into a map by name; "" is the empty tree
+func readTreeMap(hash string) (map[string]objects.TreeEntry, error) {
+	entries := make(map[string]objects.TreeEntry)
+	if hash == "" {
+		return entries, nil
+	}
+
+	list, err := objects.ReadTree(hash)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range list {
+		entries[entry.Name] = entry
+	}
+	return entries, nil
+}
@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around a change
+const DefaultContext = 3
+
+// Hunk is one "@@ -a,b +c,d @@" section of a unified diff
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Edits              []Edit
+}
+
+// Hunks groups an edit script into hunks with the given number of context
+// lines; changes closer together than twice the context share a hunk
+func Hunks(edits []Edit, context int) []Hunk {
+	// line numbers reached before each edit
+	oldPos := make([]int, len(edits)+1)
+	newPos := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if edit.Op != Insert {
+			oldPos[i+1]++
+		}
+		if edit.Op != Delete {
+			newPos[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(edits); {
+		if !edits[i].changes() {
+			i++
+			continue
+		}
+
+		// extend the hunk over every change that is close enough
+		last := i
+		for j := i + 1; j < len(edits) && j-last-1 <= 2*context; j++ {
+			if edits[j].changes() {
+				last = j
+			}
+		}
+
+		start := max(0, i-context)
+		stop := min(len(edits), last+context+1)
+
+		hunk := Hunk{
+			OldStart: oldPos[start] + 1,
+			OldLines: oldPos[stop] - oldPos[start],
+			NewStart: newPos[start] + 1,
+			NewLines: newPos[stop] - newPos[start],
+			Edits:    edits[start:stop],
+		}
+		// an empty side is numbered by the line before it, like git does
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		hunks = append(hunks, hunk)
+		i = stop
+	}
+	return hunks
+}
+
+// changes reports whether an edit is a change that belongs in a hunk
+func (e Edit) changes() bool {
+	return e.Op != Equal && !e.Ignored
+}
+
+// Header returns the "@@ -1,3 +1,4 @@" line of the hunk
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+// hunkRange leaves out the line count when it is one
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// WriteHunks writes hunks in unified format
+func WriteHunks(w io.Writer, hunks []Hunk) {
+	for _, hunk := range hunks {
+		fmt.Fprintln(w, hunk.Header())
+		for _, edit := range hunk.Edits {
+			WriteLine(w, edit)
+		}
+	}
+}
+
+// WriteLine writes one line of a hunk with its ' ', '+' or '-' marker,
+// noting a missing newline at the end of the file
+func WriteLine(w io.Writer, edit Edit) {
+	marker := " "
+	switch edit.Op {
+	case Insert:
+		marker = "+"
+	case Delete:
+		marker = "-"
+	}
+
+	fmt.Fprint(w, marker, edit.Text)
+	if !strings.HasSuffix(edit.Text, "\n") {
+		fmt.Fprint(w, "\n\\ No newline at end of file\n")
+	}
+}
@@ -0,0 +1,174 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ANSI escapes for --word-diff=color, the colors git uses
+const (
+	wordColorDeleted  = "\x1b[31m"
+	wordColorInserted = "\x1b[32m"
+	wordColorReset    = "\x1b[m"
+)
+
+var defaultWordRegex = regexp.MustCompile(DefaultWordRegex)
+
+// wordText is one side of a hunk cut into words, with the text between
+// them: gaps[k] comes before words[k], and the last gap ends the text
+type wordText struct {
+	words []string
+	gaps  []string
+}
+
+// splitWords cuts text into the words a regex matches and what lies
+// between them
+func splitWords(text string, re *regexp.Regexp) wordText {
+	var wt wordText
+	last := 0
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		wt.gaps = append(wt.gaps, text[last:loc[0]])
+		wt.words = append(wt.words, text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	wt.gaps = append(wt.gaps, text[last:])
+	return wt
+}
+
+// wordWriter writes the pieces of a word diff in one of the modes,
+// gathering pieces of the same kind so porcelain shows them on one line
+type wordWriter struct {
+	w       io.Writer
+	mode    string
+	op      byte
+	pending strings.Builder
+}
+
+// write adds text that is on both sides (' '), only the old side ('-')
+// or only the new side ('+')
+func (ww *wordWriter) write(op byte, text string) {
+	if text == "" {
+		return
+	}
+	if op != ww.op {
+		ww.flush()
+		ww.op = op
+	}
+	ww.pending.WriteString(text)
+}
+
+// flush writes out the pieces gathered so far
+func (ww *wordWriter) flush() {
+	text := ww.pending.String()
+	ww.pending.Reset()
+	if text == "" {
+		return
+	}
+
+	if ww.mode == WordDiffPorcelain {
+		// a line per piece, with "~" where the text has a newline
+		for k, part := range strings.Split(text, "\n") {
+			if k > 0 {
+				fmt.Fprintln(ww.w, "~")
+			}
+			if part != "" {
+				fmt.Fprintf(ww.w, "%c%s\n", ww.op, part)
+			}
+		}
+		return
+	}
+
+	switch {
+	case ww.op == ' ':
+		fmt.Fprint(ww.w, text)
+	case ww.mode == WordDiffColor && ww.op == '-':
+		fmt.Fprint(ww.w, wordColorDeleted+text+wordColorReset)
+	case ww.mode == WordDiffColor:
+		fmt.Fprint(ww.w, wordColorInserted+text+wordColorReset)
+	case ww.op == '-':
+		fmt.Fprint(ww.w, "[-"+text+"-]")
+	default:
+		fmt.Fprint(ww.w, "{+"+text+"+}")
+	}
+}
+
+// WriteWordDiff writes a hunk's changes word by word: the old and new
+// text of the hunk are cut into words, the words are diffed, and the
+// result is shown over the new text with the removed and added words
+// marked the way the mode says
+func WriteWordDiff(w io.Writer, hunk Hunk, opts Options) {
+	re := opts.WordRegex
+	if re == nil {
+		re = defaultWordRegex
+	}
+
+	var oldText, newText strings.Builder
+	for _, edit := range hunk.Edits {
+		if edit.Op != Insert {
+			oldText.WriteString(edit.Text)
+		}
+		if edit.Op != Delete {
+			newText.WriteString(edit.Text)
+		}
+	}
+	before, after := splitWords(oldText.String(), re), splitWords(newText.String(), re)
+
+	ww := &wordWriter{w: w, mode: opts.WordDiff}
+	fmt.Fprintln(w, hunk.Header())
+
+	// the text between words comes from the new side; gapDone is the gap
+	// already written ahead of a deletion
+	edits := Compare(before.words, after.words, Options{Algorithm: opts.Algorithm})
+	i, j, gapDone := 0, 0, -1
+	for k := 0; k < len(edits); {
+		if edits[k].Op == Equal {
+			if gapDone != j {
+				ww.write(' ', after.gaps[j])
+			}
+			ww.write(' ', after.words[j])
+			i++
+			j++
+			k++
+			continue
+		}
+
+		// a run of changes shows what went, then what came
+		var removed, added strings.Builder
+		firstI, firstJ := i, j
+		for ; k < len(edits) && edits[k].Op != Equal; k++ {
+			if edits[k].Op == Delete {
+				if i > firstI {
+					removed.WriteString(before.gaps[i])
+				}
+				removed.WriteString(before.words[i])
+				i++
+			} else {
+				if j > firstJ {
+					added.WriteString(after.gaps[j])
+				}
+				added.WriteString(after.words[j])
+				j++
+			}
+		}
+		if gapDone != firstJ {
+			ww.write(' ', after.gaps[firstJ])
+			gapDone = firstJ
+		}
+		ww.write('-', removed.String())
+		ww.write('+', added.String())
+	}
+	if gapDone != j {
+		ww.write(' ', after.gaps[j])
+	}
+	ww.flush()
+
+	// the text of a hunk ends with a newline unless the file doesn't
+	if ww.mode != WordDiffPorcelain && !strings.HasSuffix(newText.String(), "\n") {
+		fmt.Fprintln(w)
+	}
+}
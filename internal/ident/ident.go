@@ -0,0 +1,122 @@
+package ident
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayushsarode/orb/internal/config"
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// defaults used when neither the environment nor the config names anyone
+const (
+	defaultName  = "Unknown"
+	defaultEmail = "unknown@example.com"
+)
+
+// Author returns who wrote a change: ORB_AUTHOR_NAME, ORB_AUTHOR_EMAIL and
+// ORB_AUTHOR_DATE win over author.* and user.* config, and the date
+// defaults to now
+func Author() (objects.Signature, error) {
+	return resolve("AUTHOR", "author")
+}
+
+// Committer returns who is recording a change, from ORB_COMMITTER_*,
+// committer.* and user.* in that order
+func Committer() (objects.Signature, error) {
+	return resolve("COMMITTER", "committer")
+}
+
+// resolve builds a signature from the environment, then the config
+func resolve(envRole, configRole string) (objects.Signature, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		// Fallback to defaults if config can't be loaded
+		cfg = config.NewConfig()
+	}
+
+	sig := objects.Signature{
+		Name:  firstSet(os.Getenv("ORB_"+envRole+"_NAME"), cfg.Get(configRole+".name"), cfg.Get("user.name"), defaultName),
+		Email: firstSet(os.Getenv("ORB_"+envRole+"_EMAIL"), cfg.Get(configRole+".email"), cfg.Get("user.email"), defaultEmail),
+		When:  time.Now(),
+	}
+
+	if date := os.Getenv("ORB_" + envRole + "_DATE"); date != "" {
+		when, err := ParseDate(date)
+		if err != nil {
+			return sig, fmt.Errorf("invalid ORB_%s_DATE: %w", envRole, err)
+		}
+		sig.When = when
+	}
+
+	return sig, nil
+}
+
+// firstSet returns the first non-empty value
+func firstSet(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ParseIdent splits "Name <email>" into its parts
+func ParseIdent(value string) (string, string, error) {
+	open := strings.Index(value, "<")
+	close := strings.LastIndex(value, ">")
+	if open < 0 || close < open || strings.TrimSpace(value[close+1:]) != "" {
+		return "", "", fmt.Errorf("'%s' is not 'Name <email>'", value)
+	}
+
+	name := strings.TrimSpace(value[:open])
+	if name == "" {
+		return "", "", fmt.Errorf("'%s' has no name", value)
+	}
+	return name, strings.TrimSpace(value[open+1 : close]), nil
+}
+
+// date layouts accepted for commit dates, RFC 2822 and ISO 8601 variants
+var dateLayouts = []string{
+	time.RFC1123Z,                    // Mon, 02 Jan 2006 15:04:05 -0700
+	"Mon, 2 Jan 2006 15:04:05 -0700", // RFC 2822 with a single digit day
+	"2 Jan 2006 15:04:05 -0700",      // RFC 2822 without the weekday
+	"Mon Jan 2 15:04:05 2006 -0700",  // the format git prints
+	time.RFC3339,                     // 2006-01-02T15:04:05-07:00
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// ParseDate parses a commit date: "@<unix> <tz>" (or without the "@"),
+// RFC 2822 or ISO 8601. Dates without a zone are taken as local time.
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	// raw git format: "@1700000000 +0100" or "1700000000 +0100"
+	raw := strings.TrimPrefix(value, "@")
+	fields := strings.Fields(raw)
+	if len(fields) >= 1 && len(fields) <= 2 {
+		if unix, err := strconv.ParseInt(fields[0], 10, 64); err == nil && (len(fields) == 2 || strings.HasPrefix(value, "@")) {
+			zone := time.UTC
+			if len(fields) == 2 {
+				if zone, err = objects.ParseTimezone(fields[1]); err != nil {
+					return time.Time{}, err
+				}
+			}
+			return time.Unix(unix, 0).In(zone), nil
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date format: %s", value)
+}
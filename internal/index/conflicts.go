@@ -0,0 +1,99 @@
+package index
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// conflictsFile keeps the unmerged paths next to the index, one
+// "<base> <ours> <theirs>\t<path>" line each, with "-" for a missing side
+const conflictsFile = ".orb/index.unmerged"
+
+// AddConflict records that a merge left a path unmerged
+func (idx *Index) AddConflict(path string, conflict Conflict) {
+	idx.Conflicts[path] = conflict
+}
+
+// HasConflicts reports whether any path is still unmerged
+func (idx *Index) HasConflicts() bool {
+	return len(idx.Conflicts) > 0
+}
+
+// ConflictPaths returns the unmerged paths, sorted
+func (idx *Index) ConflictPaths() []string {
+	paths := make([]string, 0, len(idx.Conflicts))
+	for path := range idx.Conflicts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ClearConflicts forgets every unmerged path, e.g. when a merge is aborted
+func (idx *Index) ClearConflicts() {
+	idx.Conflicts = make(map[string]Conflict)
+}
+
+// loadConflicts reads the unmerged paths, if there are any
+func (idx *Index) loadConflicts() error {
+	file, err := os.Open(conflictsFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening unmerged paths: %w", err)
+	}
+	defer file.Close()
+
+	side := func(hash string) string {
+		if hash == "-" {
+			return ""
+		}
+		return hash
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		header, path, ok := strings.Cut(scanner.Text(), "\t")
+		fields := strings.Fields(header)
+		if !ok || len(fields) != 3 {
+			continue
+		}
+		idx.Conflicts[path] = Conflict{Base: side(fields[0]), Ours: side(fields[1]), Theirs: side(fields[2])}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading unmerged paths: %w", err)
+	}
+	return nil
+}
+
+// writeConflicts saves the unmerged paths, removing the file once there
+// are none left
+func (idx *Index) writeConflicts() error {
+	if len(idx.Conflicts) == 0 {
+		if err := os.Remove(conflictsFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing unmerged paths: %w", err)
+		}
+		return nil
+	}
+
+	side := func(hash string) string {
+		if hash == "" {
+			return "-"
+		}
+		return hash
+	}
+
+	var b strings.Builder
+	for _, path := range idx.ConflictPaths() {
+		c := idx.Conflicts[path]
+		fmt.Fprintf(&b, "%s %s %s\t%s\n", side(c.Base), side(c.Ours), side(c.Theirs), path)
+	}
+	if err := os.WriteFile(conflictsFile, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("writing unmerged paths: %w", err)
+	}
+	return nil
+}
@@ -26,12 +26,26 @@ type Entry struct {
 // Index is a collection of all staged files
 type Index struct {
 	Entries map[string]Entry
+
+	// Conflicts are the paths a merge left unmerged. Their entry in
+	// Entries holds our side until the conflict is resolved by adding or
+	// removing the path.
+	Conflicts map[string]Conflict
+}
+
+// Conflict is a path a merge couldn't resolve, with the blob each side
+// had; a side without the file is ""
+type Conflict struct {
+	Base   string
+	Ours   string
+	Theirs string
 }
 
 // NewIndex creates a new empty index
 func NewIndex() *Index {
 	return &Index{
-		Entries: make(map[string]Entry),
+		Entries:   make(map[string]Entry),
+		Conflicts: make(map[string]Conflict),
 	}
 }
 
@@ -87,6 +101,10 @@ func LoadIndex() (*Index, error) {
 	if err := scanner.Err(); err != nil {
 		return idx, fmt.Errorf("reading index file: %w", err)
 	}
+
+	if err := idx.loadConflicts(); err != nil {
+		return idx, err
+	}
 	
 	return idx, nil
 }
@@ -105,10 +123,36 @@ func (idx *Index) AddFile(path string, hash string) error {
 		Mode:       uint32(info.Mode()),
 		Size:       uint32(info.Size()),
 	}
+
+	// staging a file is how a conflict in it is marked resolved
+	delete(idx.Conflicts, path)
 	
 	return nil
 }
 
+// SetEntry stages a blob for a path without reading the file, e.g. when
+// the index is rebuilt from a tree; the file need not exist
+func (idx *Index) SetEntry(path string, hash string) {
+	entry := Entry{Path: path, ObjectHash: hash}
+	if info, err := os.Stat(path); err == nil {
+		entry.ModTime = info.ModTime()
+		entry.Mode = uint32(info.Mode())
+		entry.Size = uint32(info.Size())
+	}
+	idx.Entries[path] = entry
+	delete(idx.Conflicts, path)
+}
+
+// Remove unstages a path, reporting whether it was in the index
+func (idx *Index) Remove(path string) bool {
+	delete(idx.Conflicts, path)
+	if _, ok := idx.Entries[path]; !ok {
+		return false
+	}
+	delete(idx.Entries, path)
+	return true
+}
+
 // Write writes the index to disk, basically saves all staged changes
 func (idx *Index) Write() error {
 	// Create parent directories if needed
@@ -141,7 +185,7 @@ func (idx *Index) Write() error {
 		}
 	}
 	
-	return nil
+	return idx.writeConflicts()
 }
 
 // GetEntries returns all entries in the index, sorted by path
@@ -0,0 +1,120 @@
+// Package merge combines two lines of development that split from a
+// common base: diff3 on file contents and a three-way merge of trees.
+package merge
+
+import (
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/diff"
+)
+
+// Labels name the three sides in conflict markers
+type Labels struct {
+	Base   string
+	Ours   string
+	Theirs string
+}
+
+// Lines merges the changes ours and theirs made to base, diff3 style.
+// Regions only one side changed take that side; regions both changed the
+// same way are taken once; anything else becomes a conflict block
+// between <<<<<<< and >>>>>>> markers. The second result is the number
+// of conflicts.
+func Lines(base, ours, theirs []string, labels Labels) ([]string, int) {
+	oursAt := matches(base, ours)
+	theirsAt := matches(base, theirs)
+
+	var merged []string
+	conflicts := 0
+	b, o, t := 0, 0, 0
+
+	for b < len(base) || o < len(ours) || t < len(theirs) {
+		// lines all three still agree on go straight through
+		stable := 0
+		for b+stable < len(base) && oursAt[b+stable] == o+stable && theirsAt[b+stable] == t+stable {
+			stable++
+		}
+		if stable > 0 {
+			merged = append(merged, base[b:b+stable]...)
+			b, o, t = b+stable, o+stable, t+stable
+			continue
+		}
+
+		// the next base line both sides kept ends the unstable region
+		next := b
+		for next < len(base) && (oursAt[next] < 0 || theirsAt[next] < 0) {
+			next++
+		}
+		oEnd, tEnd := len(ours), len(theirs)
+		if next < len(base) {
+			oEnd, tEnd = oursAt[next], theirsAt[next]
+		}
+
+		baseChunk, oursChunk, theirsChunk := base[b:next], ours[o:oEnd], theirs[t:tEnd]
+		switch {
+		case equal(oursChunk, baseChunk):
+			merged = append(merged, theirsChunk...)
+		case equal(theirsChunk, baseChunk), equal(oursChunk, theirsChunk):
+			merged = append(merged, oursChunk...)
+		default:
+			merged = append(merged, conflictBlock(oursChunk, theirsChunk, labels)...)
+			conflicts++
+		}
+		b, o, t = next, oEnd, tEnd
+	}
+	return merged, conflicts
+}
+
+// matches maps every line of base to the line of other it was kept as, or
+// -1 when other dropped it
+func matches(base, other []string) []int {
+	at := make([]int, len(base))
+	i, j := 0, 0
+	for _, edit := range diff.Lines(base, other) {
+		switch edit.Op {
+		case diff.Equal:
+			at[i] = j
+			i++
+			j++
+		case diff.Delete:
+			at[i] = -1
+			i++
+		case diff.Insert:
+			j++
+		}
+	}
+	return at
+}
+
+// equal reports whether two runs of lines are the same
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// conflictBlock puts both sides of a conflict between markers. A side
+// ending without a newline gets one, so the markers stay on their own lines.
+func conflictBlock(ours, theirs []string, labels Labels) []string {
+	block := []string{"<<<<<<< " + labels.Ours + "\n"}
+	block = append(block, terminated(ours)...)
+	block = append(block, "=======\n")
+	block = append(block, terminated(theirs)...)
+	return append(block, ">>>>>>> "+labels.Theirs+"\n")
+}
+
+// terminated returns lines with a newline after the last one
+func terminated(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	out := append([]string(nil), lines...)
+	out[len(out)-1] += "\n"
+	return out
+}
@@ -0,0 +1,104 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ayushsarode/orb/internal/diff"
+)
+
+var testLabels = Labels{Base: "base", Ours: "ours", Theirs: "theirs"}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		conflicts          int
+	}{
+		{
+			name: "nobody changed anything",
+			base: "a\nb\nc\n", ours: "a\nb\nc\n", theirs: "a\nb\nc\n",
+			want: "a\nb\nc\n",
+		},
+		{
+			name: "only ours changed",
+			base: "a\nb\nc\n", ours: "a\nB\nc\n", theirs: "a\nb\nc\n",
+			want: "a\nB\nc\n",
+		},
+		{
+			name: "only theirs changed",
+			base: "a\nb\nc\n", ours: "a\nb\nc\n", theirs: "a\nb\nC\n",
+			want: "a\nb\nC\n",
+		},
+		{
+			name: "changes in different places",
+			base: "a\nb\nc\nd\ne\n", ours: "A\nb\nc\nd\ne\n", theirs: "a\nb\nc\nd\nE\n",
+			want: "A\nb\nc\nd\nE\n",
+		},
+		{
+			name: "the same change on both sides",
+			base: "a\nb\nc\n", ours: "a\nX\nc\n", theirs: "a\nX\nc\n",
+			want: "a\nX\nc\n",
+		},
+		{
+			name: "one side deletes, the other leaves alone",
+			base: "a\nb\nc\n", ours: "a\nc\n", theirs: "a\nb\nc\n",
+			want: "a\nc\n",
+		},
+		{
+			name: "one side inserts, the other deletes elsewhere",
+			base: "a\nb\nc\nd\n", ours: "a\nnew\nb\nc\nd\n", theirs: "a\nb\nc\n",
+			want: "a\nnew\nb\nc\n",
+		},
+		{
+			name: "conflicting changes to the same line",
+			base: "a\nb\nc\n", ours: "a\nX\nc\n", theirs: "a\nY\nc\n",
+			want:      "a\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name: "conflicting additions at the end",
+			base: "a\n", ours: "a\nours\n", theirs: "a\ntheirs\n",
+			want:      "a\n<<<<<<< ours\nours\n=======\ntheirs\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+		{
+			name: "edit against delete",
+			base: "a\nb\nc\n", ours: "a\nB\nc\n", theirs: "a\nc\n",
+			want:      "a\n<<<<<<< ours\nB\n=======\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name: "two separate conflicts",
+			base: "a\nb\nc\nd\ne\n", ours: "A1\nb\nc\nd\nE1\n", theirs: "A2\nb\nc\nd\nE2\n",
+			want: "<<<<<<< ours\nA1\n=======\nA2\n>>>>>>> theirs\nb\nc\nd\n" +
+				"<<<<<<< ours\nE1\n=======\nE2\n>>>>>>> theirs\n",
+			conflicts: 2,
+		},
+		{
+			name: "both added from nothing",
+			base: "", ours: "x\n", theirs: "y\n",
+			want:      "<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+		{
+			name: "a conflicted side without a final newline",
+			base: "a\nb", ours: "a\nX", theirs: "a\nY",
+			want:      "a\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts := Lines(diff.SplitLines(tt.base), diff.SplitLines(tt.ours), diff.SplitLines(tt.theirs), testLabels)
+			if got := strings.Join(merged, ""); got != tt.want {
+				t.Errorf("merged =\n%q\nwant\n%q", got, tt.want)
+			}
+			if conflicts != tt.conflicts {
+				t.Errorf("conflicts = %d, want %d", conflicts, tt.conflicts)
+			}
+		})
+	}
+}
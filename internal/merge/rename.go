@@ -0,0 +1,113 @@
+package merge
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ayushsarode/orb/internal/diff"
+	"github.com/ayushsarode/orb/internal/index"
+)
+
+// followRenames lines up a file one side renamed with the other side's
+// version under the old name, so that both sides' changes meet at the new
+// path. A file the two sides renamed differently is a conflict: both new
+// names are kept in result and taken out of the maps. The maps passed in
+// are left alone.
+func followRenames(base, ours, theirs map[string]string, labels Labels, opts diff.RenameOptions, result *Result) (map[string]string, map[string]string, map[string]string, error) {
+	opts.Copies = false
+	ourRenames, err := renamesBetween(base, ours, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	theirRenames, err := renamesBetween(base, theirs, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(ourRenames) == 0 && len(theirRenames) == 0 {
+		return base, ours, theirs, nil
+	}
+
+	base, ours, theirs = copyFiles(base), copyFiles(ours), copyFiles(theirs)
+
+	var paths []string
+	for path := range base {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		ourPath, oursRenamed := ourRenames[path]
+		theirPath, theirsRenamed := theirRenames[path]
+
+		switch {
+		case oursRenamed && theirsRenamed && ourPath == theirPath:
+			moveFile(base, path, ourPath)
+
+		case oursRenamed && theirsRenamed:
+			result.keep(ourPath, ours[ourPath])
+			result.keep(theirPath, theirs[theirPath])
+			result.Conflicts[ourPath] = index.Conflict{Base: base[path], Ours: ours[ourPath]}
+			result.Conflicts[theirPath] = index.Conflict{Base: base[path], Theirs: theirs[theirPath]}
+			result.Messages = append(result.Messages, fmt.Sprintf(
+				"CONFLICT (rename/rename): %s renamed to %s in %s and to %s in %s.",
+				path, ourPath, labels.Ours, theirPath, labels.Theirs))
+			delete(base, path)
+			delete(ours, ourPath)
+			delete(theirs, theirPath)
+
+		case oursRenamed:
+			if follow(theirs, path, ourPath) {
+				moveFile(base, path, ourPath)
+			}
+
+		case theirsRenamed:
+			if follow(ours, path, theirPath) {
+				moveFile(base, path, theirPath)
+			}
+		}
+	}
+	return base, ours, theirs, nil
+}
+
+// renamesBetween finds the files a side renamed, from old path to new
+func renamesBetween(base, side map[string]string, opts diff.RenameOptions) (map[string]string, error) {
+	changes, _, err := diff.DetectRenames(diff.Files(base, side), opts)
+	if err != nil {
+		return nil, err
+	}
+	renames := make(map[string]string)
+	for _, change := range changes {
+		if change.Status == diff.Renamed {
+			renames[change.OldPath] = change.Path
+		}
+	}
+	return renames, nil
+}
+
+// follow moves a side's file to where the other side renamed it, as long
+// as the side still has the file and nothing at the new path yet
+func follow(files map[string]string, from, to string) bool {
+	if _, ok := files[from]; !ok {
+		return false
+	}
+	if _, taken := files[to]; taken {
+		return false
+	}
+	moveFile(files, from, to)
+	return true
+}
+
+// moveFile moves a file to another path
+func moveFile(files map[string]string, from, to string) {
+	files[to] = files[from]
+	delete(files, from)
+}
+
+// copyFiles returns a copy of a set of files
+func copyFiles(files map[string]string) map[string]string {
+	copied := make(map[string]string, len(files))
+	for path, hash := range files {
+		copied[path] = hash
+	}
+	return copied
+}
@@ -0,0 +1,225 @@
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/diff"
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/worktree"
+)
+
+// Result is the outcome of merging three sets of files
+type Result struct {
+	// Files is the merged content of every path, as a blob hash. A
+	// conflicted file holds its content with conflict markers.
+	Files map[string]string
+
+	// Conflicts are the paths that need a person to decide, with what
+	// each side had
+	Conflicts map[string]index.Conflict
+
+	// Messages describe what happened, e.g. "CONFLICT (content): Merge
+	// conflict in a.txt", in path order
+	Messages []string
+}
+
+// Clean reports whether the merge needs no help
+func (r *Result) Clean() bool {
+	return len(r.Conflicts) == 0
+}
+
+// Trees merges the changes ours and theirs made to base; "" is an empty
+// tree. Renames are followed as renames says; nil doesn't follow them.
+func Trees(base, ours, theirs string, labels Labels, renames *diff.RenameOptions) (*Result, error) {
+	var sides [3]map[string]string
+	for i, tree := range []string{base, ours, theirs} {
+		files, err := worktree.Files(tree)
+		if err != nil {
+			return nil, err
+		}
+		sides[i] = files
+	}
+	return Files(sides[0], sides[1], sides[2], labels, renames)
+}
+
+// Files merges three sets of files, each a map from path to blob hash.
+// A path one side left alone takes the other side's version; a path both
+// sides changed has its contents merged line by line. With renames, a
+// file one side renamed takes the other side's changes along to its new
+// name.
+func Files(base, ours, theirs map[string]string, labels Labels, renames *diff.RenameOptions) (*Result, error) {
+	result := &Result{
+		Files:     make(map[string]string),
+		Conflicts: make(map[string]index.Conflict),
+	}
+
+	if renames != nil {
+		var err error
+		if base, ours, theirs, err = followRenames(base, ours, theirs, labels, *renames, result); err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make(map[string]bool)
+	for _, files := range []map[string]string{base, ours, theirs} {
+		for path := range files {
+			paths[path] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	for _, path := range sorted {
+		b, o, t := base[path], ours[path], theirs[path]
+
+		switch {
+		case o == t, t == b:
+			result.keep(path, o)
+			continue
+		case o == b:
+			result.keep(path, t)
+			continue
+		}
+
+		conflict := index.Conflict{Base: b, Ours: o, Theirs: t}
+
+		// one side deleted what the other changed: keep the changed file
+		// so nothing is lost, and let a person decide
+		if o == "" || t == "" {
+			deleted, modified, kept := labels.Ours, labels.Theirs, t
+			if t == "" {
+				deleted, modified, kept = labels.Theirs, labels.Ours, o
+			}
+			result.keep(path, kept)
+			result.Conflicts[path] = conflict
+			result.Messages = append(result.Messages, fmt.Sprintf(
+				"CONFLICT (modify/delete): %s deleted in %s and modified in %s. Version %s of %s left in tree.",
+				path, deleted, modified, modified, path))
+			continue
+		}
+
+		merged, conflicts, err := mergeContents(b, o, t, path, labels)
+		if err != nil {
+			return nil, err
+		}
+		result.keep(path, merged)
+		result.Messages = append(result.Messages, "Auto-merging "+path)
+		if conflicts {
+			kind := "content"
+			if b == "" {
+				kind = "add/add"
+			}
+			result.Conflicts[path] = conflict
+			result.Messages = append(result.Messages, fmt.Sprintf("CONFLICT (%s): Merge conflict in %s", kind, path))
+		}
+	}
+	return result, nil
+}
+
+// keep records a path's merged hash; "" means the path is gone
+func (r *Result) keep(path, hash string) {
+	if hash != "" {
+		r.Files[path] = hash
+	}
+}
+
+// mergeContents merges three versions of a file and stores the result as
+// a blob. Binary files can't be merged, so ours is kept as a conflict.
+func mergeContents(base, ours, theirs, path string, labels Labels) (string, bool, error) {
+	var contents [3][]byte
+	for i, hash := range []string{base, ours, theirs} {
+		content, err := diff.ReadBlob(hash)
+		if err != nil {
+			return "", false, err
+		}
+		if diff.IsBinary(content) {
+			return ours, true, nil
+		}
+		contents[i] = content
+	}
+
+	merged, conflicts := Lines(
+		diff.SplitLines(string(contents[0])),
+		diff.SplitLines(string(contents[1])),
+		diff.SplitLines(string(contents[2])),
+		labels)
+
+	hash, err := objects.WriteObject(objects.BlobType, []byte(strings.Join(merged, "")))
+	if err != nil {
+		return "", false, fmt.Errorf("writing merged %s: %w", path, err)
+	}
+	return hash, conflicts > 0, nil
+}
+
+// Apply writes a merge result into the index and working tree, which hold
+// ours plus whatever local changes there are. Paths the merge changes
+// must have no local changes, and nothing is written if one does.
+// Conflicted files get their content with markers, and the index records
+// them as unmerged.
+func (r *Result) Apply(idx *index.Index, ours map[string]string) error {
+	if idx.HasConflicts() {
+		return fmt.Errorf("you need to resolve your current index first:\n\t%s", strings.Join(idx.ConflictPaths(), "\n\t"))
+	}
+
+	paths := make(map[string]bool)
+	for path := range ours {
+		paths[path] = true
+	}
+	for path := range r.Files {
+		paths[path] = true
+	}
+
+	var changed, dirty []string
+	for path := range paths {
+		if ours[path] == r.Files[path] {
+			continue
+		}
+		changed = append(changed, path)
+
+		// the file must be exactly what is staged, or absent from both
+		working, err := worktree.HashFile(path)
+		if err != nil {
+			return err
+		}
+		staged := ""
+		if entry, ok := idx.Entries[path]; ok {
+			staged = entry.ObjectHash
+		}
+		if working != staged || staged != ours[path] {
+			dirty = append(dirty, path)
+		}
+	}
+	sort.Strings(changed)
+	if len(dirty) > 0 {
+		sort.Strings(dirty)
+		return &worktree.LocalChangesError{Paths: dirty, Merge: true}
+	}
+
+	for _, path := range changed {
+		hash := r.Files[path]
+		if hash == "" {
+			if err := worktree.RemoveFile(path); err != nil {
+				return err
+			}
+			idx.Remove(path)
+			continue
+		}
+		if err := worktree.WriteFile(path, hash); err != nil {
+			return err
+		}
+		if _, conflicted := r.Conflicts[path]; !conflicted {
+			idx.SetEntry(path, hash)
+		}
+	}
+
+	for path, conflict := range r.Conflicts {
+		idx.AddConflict(path, conflict)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package merge
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ayushsarode/orb/internal/diff"
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// inTempRepo runs the test in an empty repository, so blobs have
+// somewhere to go
+func inTempRepo(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.MkdirAll(".orb/objects", 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// blobs stores the contents of a set of files and maps each path to its
+// blob hash
+func blobs(t *testing.T, files map[string]string) map[string]string {
+	t.Helper()
+	hashes := make(map[string]string)
+	for path, content := range files {
+		hash, err := objects.WriteObject(objects.BlobType, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[path] = hash
+	}
+	return hashes
+}
+
+// contents reads back the merged files
+func contents(t *testing.T, files map[string]string) map[string]string {
+	t.Helper()
+	read := make(map[string]string)
+	for path, hash := range files {
+		content, err := diff.ReadBlob(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read[path] = string(content)
+	}
+	return read
+}
+
+// body is a file long enough for rename detection to pair up its versions
+const body = "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n"
+
+func TestFiles(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs map[string]string
+		renames            bool
+		want               map[string]string
+		conflicts          []string
+		messages           []string
+	}{
+		{
+			name:   "changes to different files",
+			base:   map[string]string{"a": "a\n", "b": "b\n"},
+			ours:   map[string]string{"a": "A\n", "b": "b\n"},
+			theirs: map[string]string{"a": "a\n", "b": "B\n", "c": "c\n"},
+			want:   map[string]string{"a": "A\n", "b": "B\n", "c": "c\n"},
+		},
+		{
+			name:     "changes to different lines of a file",
+			base:     map[string]string{"f": "a\nb\nc\nd\n"},
+			ours:     map[string]string{"f": "A\nb\nc\nd\n"},
+			theirs:   map[string]string{"f": "a\nb\nc\nD\n"},
+			want:     map[string]string{"f": "A\nb\nc\nD\n"},
+			messages: []string{"Auto-merging f"},
+		},
+		{
+			name:      "conflicting changes",
+			base:      map[string]string{"f": "a\nb\nc\n"},
+			ours:      map[string]string{"f": "a\nX\nc\n"},
+			theirs:    map[string]string{"f": "a\nY\nc\n"},
+			want:      map[string]string{"f": "a\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\nc\n"},
+			conflicts: []string{"f"},
+			messages:  []string{"Auto-merging f", "CONFLICT (content): Merge conflict in f"},
+		},
+		{
+			name:      "added differently on both sides",
+			base:      map[string]string{},
+			ours:      map[string]string{"f": "x\n"},
+			theirs:    map[string]string{"f": "y\n"},
+			want:      map[string]string{"f": "<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\n"},
+			conflicts: []string{"f"},
+			messages:  []string{"Auto-merging f", "CONFLICT (add/add): Merge conflict in f"},
+		},
+		{
+			name:   "deleted on one side, untouched on the other",
+			base:   map[string]string{"f": "a\n", "g": "g\n"},
+			ours:   map[string]string{"g": "g\n"},
+			theirs: map[string]string{"f": "a\n", "g": "g\n"},
+			want:   map[string]string{"g": "g\n"},
+		},
+		{
+			name:      "modified on one side, deleted on the other",
+			base:      map[string]string{"f": "a\n"},
+			ours:      map[string]string{"f": "changed\n"},
+			theirs:    map[string]string{},
+			want:      map[string]string{"f": "changed\n"},
+			conflicts: []string{"f"},
+			messages:  []string{"CONFLICT (modify/delete): f deleted in theirs and modified in ours. Version ours of f left in tree."},
+		},
+		{
+			name:    "a change follows the other side's rename",
+			base:    map[string]string{"old": body},
+			ours:    map[string]string{"new": body},
+			theirs:  map[string]string{"old": strings.Replace(body, "five", "FIVE", 1)},
+			renames: true,
+			want:    map[string]string{"new": strings.Replace(body, "five", "FIVE", 1)},
+		},
+		{
+			name:   "without rename detection the rename is a delete",
+			base:   map[string]string{"old": body},
+			ours:   map[string]string{"new": body},
+			theirs: map[string]string{"old": strings.Replace(body, "five", "FIVE", 1)},
+			want: map[string]string{
+				"new": body,
+				"old": strings.Replace(body, "five", "FIVE", 1),
+			},
+			conflicts: []string{"old"},
+			messages:  []string{"CONFLICT (modify/delete): old deleted in ours and modified in theirs. Version theirs of old left in tree."},
+		},
+		{
+			name:      "renamed differently on both sides",
+			base:      map[string]string{"old": body},
+			ours:      map[string]string{"a": body},
+			theirs:    map[string]string{"b": body},
+			renames:   true,
+			want:      map[string]string{"a": body, "b": body},
+			conflicts: []string{"a", "b"},
+			messages:  []string{"CONFLICT (rename/rename): old renamed to a in ours and to b in theirs."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTempRepo(t)
+
+			var renames *diff.RenameOptions
+			if tt.renames {
+				renames = &diff.RenameOptions{Threshold: diff.DefaultRenameThreshold, Limit: diff.DefaultRenameLimit}
+			}
+			result, err := Files(blobs(t, tt.base), blobs(t, tt.ours), blobs(t, tt.theirs), testLabels, renames)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := contents(t, result.Files)
+			if len(got) != len(tt.want) {
+				t.Errorf("files = %q, want %q", got, tt.want)
+			}
+			for path, want := range tt.want {
+				if got[path] != want {
+					t.Errorf("%s =\n%q\nwant\n%q", path, got[path], want)
+				}
+			}
+
+			if len(result.Conflicts) != len(tt.conflicts) {
+				t.Errorf("conflicts = %v, want %v", result.Conflicts, tt.conflicts)
+			}
+			for _, path := range tt.conflicts {
+				if _, ok := result.Conflicts[path]; !ok {
+					t.Errorf("%s isn't conflicted", path)
+				}
+			}
+			if result.Clean() != (len(tt.conflicts) == 0) {
+				t.Errorf("Clean() = %v", result.Clean())
+			}
+
+			if strings.Join(result.Messages, "\n") != strings.Join(tt.messages, "\n") {
+				t.Errorf("messages =\n%s\nwant\n%s", strings.Join(result.Messages, "\n"), strings.Join(tt.messages, "\n"))
+			}
+		})
+	}
+}
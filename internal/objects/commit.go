@@ -0,0 +1,161 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Header is a commit header that has no field of its own, such as
+// encoding, gpgsig or mergetag. Multi-line values are stored with plain
+// newlines; the leading space of continuation lines is added back when
+// the commit is serialized.
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Commit is a parsed commit object. Serialize gives back exactly the bytes
+// that were parsed as long as nothing was changed, so commits that came
+// from git keep their hashes.
+type Commit struct {
+	Tree      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	Headers   []Header // every other header, in the order it appeared
+	Message   string   // everything after the blank line, including the final newline
+
+	// noBlankLine is set for a parsed commit whose headers run to the end
+	// of the object, with no blank line for a message to follow
+	noBlankLine bool
+}
+
+// ParseCommit parses the content of a commit object
+func ParseCommit(data []byte) (*Commit, error) {
+	commit := &Commit{}
+
+	headers, message, found := bytes.Cut(data, []byte("\n\n"))
+	if !found {
+		// no message at all, only headers
+		headers = bytes.TrimSuffix(headers, []byte("\n"))
+		commit.noBlankLine = true
+	}
+	commit.Message = string(message)
+
+	var last *Header
+	for _, line := range strings.Split(string(headers), "\n") {
+		// a leading space continues the previous header (gpgsig, mergetag)
+		if strings.HasPrefix(line, " ") {
+			if last == nil {
+				return nil, fmt.Errorf("commit header continuation without a header")
+			}
+			last.Value += "\n" + line[1:]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("malformed commit header: %q", line)
+		}
+
+		last = nil
+		switch key {
+		case "tree":
+			commit.Tree = value
+		case "parent":
+			commit.Parents = append(commit.Parents, value)
+		case "author":
+			sig, err := ParseSignature(value)
+			if err != nil {
+				return nil, fmt.Errorf("parsing author: %w", err)
+			}
+			commit.Author = sig
+		case "committer":
+			sig, err := ParseSignature(value)
+			if err != nil {
+				return nil, fmt.Errorf("parsing committer: %w", err)
+			}
+			commit.Committer = sig
+		default:
+			commit.Headers = append(commit.Headers, Header{Key: key, Value: value})
+			last = &commit.Headers[len(commit.Headers)-1]
+		}
+	}
+
+	if commit.Tree == "" {
+		return nil, fmt.Errorf("commit has no tree")
+	}
+	return commit, nil
+}
+
+// Serialize returns the commit in the object format, with the headers in
+// git's order: tree, parents, author, committer, then the rest
+func (c *Commit) Serialize() []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "tree %s\n", c.Tree)
+	for _, parent := range c.Parents {
+		fmt.Fprintf(&buf, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&buf, "author %s\n", c.Author)
+	fmt.Fprintf(&buf, "committer %s\n", c.Committer)
+	for _, header := range c.Headers {
+		fmt.Fprintf(&buf, "%s %s\n", header.Key, strings.ReplaceAll(header.Value, "\n", "\n "))
+	}
+
+	// a parsed commit without the blank line gets none back, unless it
+	// has been given a message since
+	if !c.noBlankLine || c.Message != "" {
+		buf.WriteString("\n")
+	}
+	buf.WriteString(c.Message)
+	return buf.Bytes()
+}
+
+// Header returns the value of the first header with the given key
+func (c *Commit) Header(key string) (string, bool) {
+	for _, header := range c.Headers {
+		if header.Key == key {
+			return header.Value, true
+		}
+	}
+	return "", false
+}
+
+// Encoding returns the encoding the message is in, UTF-8 unless the
+// encoding header says otherwise
+func (c *Commit) Encoding() string {
+	if encoding, ok := c.Header("encoding"); ok {
+		return encoding
+	}
+	return "UTF-8"
+}
+
+// Subject returns the first line of the message
+func (c *Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
+
+// ReadCommit reads and parses a commit object
+func ReadCommit(hash string) (*Commit, error) {
+	objType, content, err := ReadObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("reading commit %s: %w", hash, err)
+	}
+	if objType != CommitType {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", hash, objType)
+	}
+
+	commit, err := ParseCommit(content)
+	if err != nil {
+		return nil, fmt.Errorf("parsing commit %s: %w", hash, err)
+	}
+	return commit, nil
+}
+
+// WriteCommit stores a commit object and returns its hash
+func WriteCommit(commit *Commit) (string, error) {
+	return WriteObject(CommitType, commit.Serialize())
+}
@@ -0,0 +1,52 @@
+package objects
+
+import "testing"
+
+func TestCommitRoundTrip(t *testing.T) {
+	const (
+		tree      = "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"
+		parent    = "parent 3f786850e387550fdab836ed7e6dc881de23001b\n"
+		author    = "author A U Thor <author@example.com> 1700000000 +0100\n"
+		committer = "committer C O Mitter <committer@example.com> 1700000060 -0500\n"
+	)
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"message", tree + parent + author + committer + "\nsubject\n\nbody\n"},
+		{"root commit", tree + author + committer + "\ninitial\n"},
+		{"empty message", tree + author + committer + "\n"},
+		{"no blank line", tree + author + committer},
+		{"message without final newline", tree + author + committer + "\nsubject"},
+		{"extra headers", tree + parent + parent + author + committer +
+			"encoding ISO-8859-1\ngpgsig -----BEGIN PGP SIGNATURE-----\n \n line\n -----END PGP SIGNATURE-----\n\nsigned\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit, err := ParseCommit([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(commit.Serialize()); got != tt.data {
+				t.Errorf("Serialize() =\n%q\nwant\n%q", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestCommitWithoutBlankLineGainsMessage(t *testing.T) {
+	data := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author A <a@example.com> 1700000000 +0000\n" +
+		"committer A <a@example.com> 1700000000 +0000\n"
+
+	commit, err := ParseCommit([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit.Message = "reworded\n"
+	if got, want := string(commit.Serialize()), data+"\nreworded\n"; got != want {
+		t.Errorf("Serialize() =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -17,18 +17,25 @@ const (
 	BlobType   = "blob"
 	TreeType   = "tree"
 	CommitType = "commit"
+	TagType    = "tag"
 )
 
-func WriteObject(objType string, content []byte) (string, error) {
-	// <type> of Object, <size> of content
-	header := fmt.Sprintf("%s %d\x00", objType, len(content))
-
-	data := append([]byte(header), content...)
+// HashObject returns the hash an object would be stored under, without
+// writing it
+func HashObject(objType string, content []byte) string {
+	hash := sha1.Sum(objectData(objType, content))
+	return fmt.Sprintf("%x", hash)
+}
 
-	//calculate SHA-1 hash
-	hash := sha1.Sum(data)
+// objectData is what gets hashed and stored: "<type> <size>\0" then the content
+func objectData(objType string, content []byte) []byte {
+	header := fmt.Sprintf("%s %d\x00", objType, len(content))
+	return append([]byte(header), content...)
+}
 
-	hashStr := fmt.Sprintf("%x", hash)
+func WriteObject(objType string, content []byte) (string, error) {
+	data := objectData(objType, content)
+	hashStr := HashObject(objType, content)
 
 	// stores objects in subdir based on their hash, first 2 char as a dir name
 	objDir := filepath.Join(objectsDir, hashStr[:2])
@@ -109,6 +116,51 @@ func WriteBlob(filePath string) (string, error) {
 	return WriteObject(BlobType, content)
 }
 
+// Exists reports whether an object with the given full hash is stored locally
+func Exists(hash string) bool {
+	if len(hash) < 3 {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(objectsDir, hash[:2], hash[2:]))
+	return err == nil
+}
+
+// ResolvePrefix expands an abbreviated hash (at least 4 hex characters)
+// into the full hash of the single object it matches
+func ResolvePrefix(prefix string) (string, error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < 4 || len(prefix) > 40 {
+		return "", fmt.Errorf("invalid object name: %s", prefix)
+	}
+	if len(prefix) == 40 {
+		if !Exists(prefix) {
+			return "", fmt.Errorf("object %s not found", prefix)
+		}
+		return prefix, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(objectsDir, prefix[:2]))
+	if err != nil {
+		return "", fmt.Errorf("object %s not found", prefix)
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix[2:]) {
+			matches = append(matches, prefix[:2]+entry.Name())
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("object %s not found", prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("short object ID %s is ambiguous", prefix)
+	}
+}
+
 // FindMissingObjects identifies objects needed from a remote repository
 func FindMissingObjects(wantHash string, haveHash string) ([]string, error) {
 	// In a full implementation, this would:
@@ -198,29 +250,11 @@ func CheckoutCommit(commitHash string) error {
 	// 3. Update the working directory to match the tree
 
 	// Read the commit object to get the tree hash
-	objType, content, err := ReadObject(commitHash)
+	commit, err := ReadCommit(commitHash)
 	if err != nil {
-		return fmt.Errorf("reading commit object: %w", err)
-	}
-
-	if objType != CommitType {
-		return fmt.Errorf("expected commit object, got %s", objType)
-	}
-
-	// Parse the commit content to get the tree hash
-	lines := strings.Split(string(content), "\n")
-	var treeHash string
-
-	for _, line := range lines {
-		if strings.HasPrefix(line, "tree ") {
-			treeHash = strings.TrimPrefix(line, "tree ")
-			break
-		}
-	}
-
-	if treeHash == "" {
-		return fmt.Errorf("no tree found in commit")
+		return err
 	}
+	treeHash := commit.Tree
 
 	fmt.Printf("Found tree %s in commit %s\n", treeHash, commitHash)
 	fmt.Println("Checkout complete (placeholder)")
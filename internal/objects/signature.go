@@ -0,0 +1,89 @@
+package objects
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature is the identity and time recorded on author, committer and tagger lines
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+
+	// raw is the text the signature was parsed from; it is written back
+	// unchanged so odd spacing or a "-0000" zone survive a round trip
+	raw string
+}
+
+// String formats the signature the way it is stored: "Name <email> 1700000000 +0100"
+func (s Signature) String() string {
+	if s.raw != "" {
+		if parsed, err := ParseSignature(s.raw); err == nil && parsed.sameAs(s) {
+			return s.raw
+		}
+	}
+	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, s.When.Unix(), s.When.Format("-0700"))
+}
+
+// sameAs reports whether two signatures record the same identity and moment,
+// including the time zone offset
+func (s Signature) sameAs(other Signature) bool {
+	_, offset := s.When.Zone()
+	_, otherOffset := other.When.Zone()
+	return s.Name == other.Name && s.Email == other.Email &&
+		s.When.Equal(other.When) && offset == otherOffset
+}
+
+// ParseSignature parses "Name <email> <unix> <tz>", keeping the time zone offset
+func ParseSignature(value string) (Signature, error) {
+	sig := Signature{raw: value}
+
+	open := strings.Index(value, "<")
+	close := strings.LastIndex(value, ">")
+	if open < 0 || close < open {
+		return sig, fmt.Errorf("malformed signature: %q", value)
+	}
+	sig.Name = strings.TrimSpace(value[:open])
+	sig.Email = value[open+1 : close]
+
+	fields := strings.Fields(value[close+1:])
+	if len(fields) == 0 {
+		return sig, nil
+	}
+
+	unix, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return sig, fmt.Errorf("invalid timestamp in signature: %w", err)
+	}
+
+	zone := time.UTC
+	if len(fields) > 1 {
+		if zone, err = ParseTimezone(fields[1]); err != nil {
+			return sig, err
+		}
+	}
+	sig.When = time.Unix(unix, 0).In(zone)
+
+	return sig, nil
+}
+
+// ParseTimezone turns a "+0530" style offset into a fixed time zone
+func ParseTimezone(tz string) (*time.Location, error) {
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') {
+		return nil, fmt.Errorf("invalid time zone offset %q", tz)
+	}
+	hours, err1 := strconv.Atoi(tz[1:3])
+	minutes, err2 := strconv.Atoi(tz[3:5])
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("invalid time zone offset %q", tz)
+	}
+
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone("", offset), nil
+}
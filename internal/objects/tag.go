@@ -0,0 +1,126 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// maxPeelDepth stops a chain of tags pointing at tags from looping forever
+const maxPeelDepth = 10
+
+// Tag is a parsed annotated tag object
+type Tag struct {
+	Object  string // what the tag points at
+	Type    string // the type of that object, usually commit
+	Name    string
+	Tagger  Signature
+	Headers []Header // any other headers, in order
+	Message string   // everything after the blank line, including any signature
+}
+
+// ParseTag parses the content of a tag object
+func ParseTag(data []byte) (*Tag, error) {
+	tag := &Tag{}
+
+	headers, message, found := bytes.Cut(data, []byte("\n\n"))
+	if !found {
+		headers = bytes.TrimSuffix(headers, []byte("\n"))
+	}
+	tag.Message = string(message)
+
+	var last *Header
+	for _, line := range strings.Split(string(headers), "\n") {
+		if strings.HasPrefix(line, " ") && last != nil {
+			last.Value += "\n" + line[1:]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("malformed tag header: %q", line)
+		}
+
+		last = nil
+		switch key {
+		case "object":
+			tag.Object = value
+		case "type":
+			tag.Type = value
+		case "tag":
+			tag.Name = value
+		case "tagger":
+			sig, err := ParseSignature(value)
+			if err != nil {
+				return nil, fmt.Errorf("parsing tagger: %w", err)
+			}
+			tag.Tagger = sig
+		default:
+			tag.Headers = append(tag.Headers, Header{Key: key, Value: value})
+			last = &tag.Headers[len(tag.Headers)-1]
+		}
+	}
+
+	if tag.Object == "" || tag.Type == "" {
+		return nil, fmt.Errorf("tag has no object")
+	}
+	return tag, nil
+}
+
+// Serialize returns the tag in the object format
+func (t *Tag) Serialize() []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "object %s\n", t.Object)
+	fmt.Fprintf(&buf, "type %s\n", t.Type)
+	fmt.Fprintf(&buf, "tag %s\n", t.Name)
+	// very old tags have no tagger
+	if t.Tagger.Name != "" || t.Tagger.Email != "" {
+		fmt.Fprintf(&buf, "tagger %s\n", t.Tagger)
+	}
+	for _, header := range t.Headers {
+		fmt.Fprintf(&buf, "%s %s\n", header.Key, strings.ReplaceAll(header.Value, "\n", "\n "))
+	}
+
+	buf.WriteString("\n")
+	buf.WriteString(t.Message)
+	return buf.Bytes()
+}
+
+// ReadTag reads and parses a tag object
+func ReadTag(hash string) (*Tag, error) {
+	objType, content, err := ReadObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("reading tag %s: %w", hash, err)
+	}
+	if objType != TagType {
+		return nil, fmt.Errorf("object %s is a %s, not a tag", hash, objType)
+	}
+
+	tag, err := ParseTag(content)
+	if err != nil {
+		return nil, fmt.Errorf("parsing tag %s: %w", hash, err)
+	}
+	return tag, nil
+}
+
+// Peel follows annotated tags until it reaches an object that is not a
+// tag, returning that object's hash and type
+func Peel(hash string) (string, string, error) {
+	for depth := 0; depth <= maxPeelDepth; depth++ {
+		objType, content, err := ReadObject(hash)
+		if err != nil {
+			return "", "", fmt.Errorf("reading object %s: %w", hash, err)
+		}
+		if objType != TagType {
+			return hash, objType, nil
+		}
+
+		tag, err := ParseTag(content)
+		if err != nil {
+			return "", "", fmt.Errorf("parsing tag %s: %w", hash, err)
+		}
+		hash = tag.Object
+	}
+	return "", "", fmt.Errorf("tag %s nested too deeply", hash)
+}
@@ -0,0 +1,86 @@
+package objects
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TreeEntry is one line of a tree object: a file or a subdirectory
+type TreeEntry struct {
+	Mode string // "100644" for files, "040000" for directories
+	Type string // BlobType or TreeType
+	Hash string
+	Name string
+}
+
+// IsTree reports whether the entry is a subdirectory
+func (e TreeEntry) IsTree() bool {
+	return e.Type == TreeType
+}
+
+// ParseTree parses the content of a tree object, one
+// "mode type hash\tname" line per entry
+func ParseTree(data []byte) ([]TreeEntry, error) {
+	var entries []TreeEntry
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+
+		header, name, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(header)
+		if !ok || len(fields) != 3 {
+			return nil, fmt.Errorf("malformed tree entry: %q", line)
+		}
+		entries = append(entries, TreeEntry{Mode: fields[0], Type: fields[1], Hash: fields[2], Name: name})
+	}
+	return entries, nil
+}
+
+// ReadTree reads and parses a tree object
+func ReadTree(hash string) ([]TreeEntry, error) {
+	objType, content, err := ReadObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("reading tree %s: %w", hash, err)
+	}
+	if objType != TreeType {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", hash, objType)
+	}
+	return ParseTree(content)
+}
+
+// LookupPath finds the entry at a slash-separated path inside a tree. The
+// second result is false when nothing exists at that path.
+func LookupPath(treeHash, path string) (TreeEntry, bool, error) {
+	entry := TreeEntry{Mode: "040000", Type: TreeType, Hash: treeHash}
+
+	path = strings.Trim(path, "/")
+	if path == "" || path == "." {
+		return entry, true, nil
+	}
+
+	for _, name := range strings.Split(path, "/") {
+		if !entry.IsTree() {
+			return TreeEntry{}, false, nil
+		}
+
+		entries, err := ReadTree(entry.Hash)
+		if err != nil {
+			return TreeEntry{}, false, err
+		}
+
+		found := false
+		for _, e := range entries {
+			if e.Name == name {
+				entry = e
+				found = true
+				break
+			}
+		}
+		if !found {
+			return TreeEntry{}, false, nil
+		}
+	}
+	return entry, true, nil
+}
@@ -0,0 +1,118 @@
+package refs
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// DecorationKind says what sort of ref a decoration is
+type DecorationKind int
+
+// Kinds in the order git lists them next to a commit
+const (
+	DecorationHead DecorationKind = iota
+	DecorationTag
+	DecorationBranch
+	DecorationRemote
+)
+
+// Decoration is a ref pointing at a commit
+type Decoration struct {
+	Ref  string // full name, e.g. refs/tags/v1.0, or HEAD
+	Kind DecorationKind
+
+	// Current marks the branch HEAD is on, shown as "HEAD -> main"
+	Current bool
+}
+
+// Decorations knows which refs point at which commits. All refs are read
+// once, so looking up a commit is just a map access.
+type Decorations struct {
+	byCommit map[string][]Decoration
+	hashes   map[string]string // full ref name -> hash it holds
+}
+
+// LoadDecorations reads every branch, remote-tracking branch and tag,
+// peeling annotated tags to the commit they name, and HEAD
+func LoadDecorations() (*Decorations, error) {
+	d := &Decorations{
+		byCommit: make(map[string][]Decoration),
+		hashes:   make(map[string]string),
+	}
+
+	currentBranch := ""
+	if target, err := ReadSymbolicRef("HEAD"); err == nil {
+		currentBranch = target
+	}
+
+	names, err := ListRefs("refs/")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		hash, err := ReadRef(name)
+		if err != nil {
+			continue
+		}
+		d.hashes[name] = hash
+
+		var kind DecorationKind
+		switch {
+		case strings.HasPrefix(name, "refs/heads/"):
+			kind = DecorationBranch
+		case strings.HasPrefix(name, "refs/remotes/"):
+			kind = DecorationRemote
+		case strings.HasPrefix(name, "refs/tags/"):
+			kind = DecorationTag
+			// an annotated tag decorates the commit it was made for
+			if peeled, _, err := objects.Peel(hash); err == nil {
+				hash = peeled
+			}
+		default:
+			continue
+		}
+
+		d.byCommit[hash] = append(d.byCommit[hash], Decoration{Ref: name, Kind: kind, Current: name == currentBranch})
+	}
+
+	// a detached HEAD gets a decoration of its own
+	if currentBranch == "" {
+		if head, err := GetHead(); err == nil {
+			d.hashes["HEAD"] = head
+			d.byCommit[head] = append(d.byCommit[head], Decoration{Ref: "HEAD", Kind: DecorationHead})
+		}
+	}
+
+	for hash := range d.byCommit {
+		sortDecorations(d.byCommit[hash])
+	}
+	return d, nil
+}
+
+// sortDecorations puts HEAD's branch first, then orders by kind and name
+func sortDecorations(decorations []Decoration) {
+	sort.SliceStable(decorations, func(i, j int) bool {
+		a, b := decorations[i], decorations[j]
+		if a.Current != b.Current {
+			return a.Current
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Ref < b.Ref
+	})
+}
+
+// For returns the refs pointing at a commit
+func (d *Decorations) For(hash string) []Decoration {
+	return d.byCommit[hash]
+}
+
+// Hash returns the hash a ref held when the decorations were loaded
+func (d *Decorations) Hash(ref string) (string, bool) {
+	hash, ok := d.hashes[ref]
+	return hash, ok
+}
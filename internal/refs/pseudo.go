@@ -0,0 +1,52 @@
+package refs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// pseudoRefName matches names like ORIG_HEAD and CHERRY_PICK_HEAD
+var pseudoRefName = regexp.MustCompile(`^[A-Z][A-Z_]*_HEAD$`)
+
+// IsPseudoRef reports whether name is a pseudo-ref: an all-caps file at
+// the top of .orb, like ORIG_HEAD, that remembers a commit for one
+// operation. Pseudo-refs have no reflog and never go through refs/.
+func IsPseudoRef(name string) bool {
+	return pseudoRefName.MatchString(name)
+}
+
+// ReadPseudoRef returns the hash a pseudo-ref holds
+func ReadPseudoRef(name string) (string, error) {
+	if !IsPseudoRef(name) {
+		return "", fmt.Errorf("'%s' is not a pseudo-ref", name)
+	}
+	hash, err := readRefFile(filepath.Join(".orb", name))
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", name, err)
+	}
+	return hash, nil
+}
+
+// WritePseudoRef points a pseudo-ref at a commit
+func WritePseudoRef(name, hash string) error {
+	if !IsPseudoRef(name) {
+		return fmt.Errorf("'%s' is not a pseudo-ref", name)
+	}
+	if err := os.WriteFile(filepath.Join(".orb", name), []byte(hash+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeletePseudoRef removes a pseudo-ref; a missing one is not an error
+func DeletePseudoRef(name string) error {
+	if !IsPseudoRef(name) {
+		return fmt.Errorf("'%s' is not a pseudo-ref", name)
+	}
+	if err := os.Remove(filepath.Join(".orb", name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package refs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ayushsarode/orb/internal/ident"
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// LogsDir is where the reflogs live, mirroring the layout of .orb/HEAD and .orb/refs
+const LogsDir = ".orb/logs"
+
+// ZeroHash stands in for "no commit" on either side of a reflog entry
+const ZeroHash = "0000000000000000000000000000000000000000"
+
+// ReflogEntry is a single line of a reflog: one move of a ref
+type ReflogEntry struct {
+	OldHash string
+	NewHash string
+	Name    string
+	Email   string
+	When    time.Time
+	Message string
+}
+
+// reflogPath returns the log file for a full ref name like HEAD or refs/heads/main
+func reflogPath(name string) string {
+	return filepath.Join(LogsDir, name)
+}
+
+// AppendReflog records that ref moved from oldHash to newHash and why
+func AppendReflog(ref, oldHash, newHash, reason string) error {
+	name := fullRefName(ref)
+
+	if oldHash == "" {
+		oldHash = ZeroHash
+	}
+	if newHash == "" {
+		newHash = ZeroHash
+	}
+
+	// ref moves are recorded under the committer's identity
+	committer, err := ident.Committer()
+	if err != nil {
+		return err
+	}
+
+	// the reason has to stay on one line, otherwise the log can't be parsed back
+	reason = strings.ReplaceAll(strings.TrimSpace(reason), "\n", " ")
+
+	line := fmt.Sprintf("%s %s %s\t%s\n", oldHash, newHash, committer, reason)
+
+	path := reflogPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating reflog directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening reflog: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(line); err != nil {
+		return fmt.Errorf("writing reflog: %w", err)
+	}
+	return nil
+}
+
+// ReadReflog returns the entries of a ref's reflog, oldest first
+func ReadReflog(ref string) ([]ReflogEntry, error) {
+	file, err := os.Open(reflogPath(fullRefName(ref)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("opening reflog: %w", err)
+	}
+	defer file.Close()
+
+	var entries []ReflogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry, err := parseReflogLine(scanner.Text())
+		if err != nil {
+			// skip lines we can't understand rather than losing the whole log
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading reflog: %w", err)
+	}
+	return entries, nil
+}
+
+// HasReflog reports whether a reflog exists for the ref
+func HasReflog(ref string) bool {
+	_, err := os.Stat(reflogPath(fullRefName(ref)))
+	return err == nil
+}
+
+// parseReflogLine parses "<old> <new> <name> <<email>> <unix> <tz>\t<message>"
+func parseReflogLine(line string) (ReflogEntry, error) {
+	var entry ReflogEntry
+
+	header, message, _ := strings.Cut(line, "\t")
+	entry.Message = message
+
+	if len(header) < 82 {
+		return entry, fmt.Errorf("reflog line too short")
+	}
+	entry.OldHash = header[:40]
+	entry.NewHash = header[41:81]
+
+	sig, err := objects.ParseSignature(header[82:])
+	if err != nil {
+		return entry, err
+	}
+	entry.Name = sig.Name
+	entry.Email = sig.Email
+	entry.When = sig.When
+
+	return entry, nil
+}
+
+// DropReflogEntry removes entry n of a ref's reflog, counting from the
+// newest the way @{n} does. The entry after it takes over its old hash so
+// the log still chains. With updateRef the ref follows the log, which makes
+// it a stack like refs/stash: it moves to the newest remaining entry without
+// a log entry of its own, and goes away together with an emptied log. Both
+// change in one transaction, which fails if the ref moved meanwhile.
+func DropReflogEntry(ref string, n int, updateRef bool) error {
+	name := fullRefName(ref)
+
+	// read the ref before its log: a log entry is added only after the ref
+	// moved, so the transaction's check catches anything added since
+	current, err := ReadRef(name)
+	if err != nil {
+		return fmt.Errorf("reading ref '%s': %w", name, err)
+	}
+
+	content, err := os.ReadFile(reflogPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("log for '%s' is empty", name)
+		}
+		return fmt.Errorf("reading reflog: %w", err)
+	}
+
+	var lines []string
+	var entries []ReflogEntry
+	for _, line := range strings.Split(string(content), "\n") {
+		entry, err := parseReflogLine(line)
+		if err != nil {
+			continue
+		}
+		lines = append(lines, line)
+		entries = append(entries, entry)
+	}
+	if n < 0 || n >= len(entries) {
+		return fmt.Errorf("log for '%s' only has %d entries", name, len(entries))
+	}
+
+	i := len(entries) - 1 - n
+	if i+1 < len(lines) {
+		lines[i+1] = entries[i].OldHash + lines[i+1][40:]
+	}
+	lines = append(lines[:i], lines[i+1:]...)
+	entries = append(entries[:i], entries[i+1:]...)
+
+	newHash := ""
+	if updateRef {
+		newHash = ZeroHash
+		if len(entries) > 0 {
+			newHash = entries[len(entries)-1].NewHash
+		}
+	}
+
+	tx := NewTransaction()
+	tx.RewriteReflog(name, lines, newHash, current)
+	return tx.Commit()
+}
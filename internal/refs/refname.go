@@ -0,0 +1,134 @@
+package refs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RefFormatOptions relaxes the ref name rules the same way the
+// flags of git check-ref-format do
+type RefFormatOptions struct {
+	AllowOneLevel  bool // accept names without a "/", like "main"
+	RefspecPattern bool // accept a single "*" wildcard
+	Normalize      bool // drop a leading "/" and collapse repeated slashes first
+}
+
+// NormalizeRefName removes a leading slash and collapses runs of slashes
+func NormalizeRefName(name string) string {
+	parts := strings.Split(name, "/")
+	kept := parts[:0]
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	normalized := strings.Join(kept, "/")
+	if strings.HasSuffix(name, "/") && normalized != "" {
+		// a trailing slash is still an error, keep it so the check reports it
+		normalized += "/"
+	}
+	return normalized
+}
+
+// CheckRefFormat reports whether name is a valid ref name, following the
+// rules of git check-ref-format:
+//
+//   - no component may begin with "." or end with ".lock"
+//   - no "..", "@{", "\", control characters, space, or any of ~ ^ : ? * [
+//   - no leading or trailing "/", no empty components, and no trailing "."
+//   - it can't be the single character "@"
+//   - it needs at least two components unless AllowOneLevel is set
+func CheckRefFormat(name string, opts RefFormatOptions) error {
+	if opts.Normalize {
+		name = NormalizeRefName(name)
+	}
+
+	invalid := func(why string) error {
+		return fmt.Errorf("'%s' is not a valid ref name: %s", name, why)
+	}
+
+	if name == "" {
+		return invalid("it is empty")
+	}
+	if name == "@" {
+		return invalid("it can't be '@'")
+	}
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return invalid("it can't begin or end with '/'")
+	}
+	if strings.HasSuffix(name, ".") {
+		return invalid("it can't end with '.'")
+	}
+	if strings.Contains(name, "..") {
+		return invalid("it can't contain '..'")
+	}
+	if strings.Contains(name, "@{") {
+		return invalid("it can't contain '@{'")
+	}
+
+	stars := 0
+	for _, c := range name {
+		switch {
+		case c < 0x20 || c == 0x7f:
+			return invalid("it can't contain control characters")
+		case c == ' ', c == '~', c == '^', c == ':', c == '?', c == '[', c == '\\':
+			return invalid(fmt.Sprintf("it can't contain '%c'", c))
+		case c == '*':
+			stars++
+		}
+	}
+	if stars > 0 && (!opts.RefspecPattern || stars > 1) {
+		return invalid("it can't contain '*'")
+	}
+
+	components := strings.Split(name, "/")
+	for _, component := range components {
+		if component == "" {
+			return invalid("it can't contain consecutive slashes")
+		}
+		if strings.HasPrefix(component, ".") {
+			return invalid("a component can't begin with '.'")
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return invalid("a component can't end with '.lock'")
+		}
+	}
+
+	if len(components) < 2 && !opts.AllowOneLevel {
+		return invalid("it needs at least one '/'")
+	}
+
+	return nil
+}
+
+// CheckBranchName reports whether name can be used as a branch name
+func CheckBranchName(name string) error {
+	if name == "HEAD" || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+	if err := CheckRefFormat("refs/heads/"+name, RefFormatOptions{}); err != nil {
+		return fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+	return nil
+}
+
+// checkWritableRef guards every ref write: pseudo refs such as HEAD or
+// ORIG_HEAD live directly in .orb, everything else must be a valid name under refs/
+func checkWritableRef(name string) error {
+	if !strings.Contains(name, "/") {
+		for _, c := range name {
+			if !(c >= 'A' && c <= 'Z') && c != '_' {
+				return fmt.Errorf("'%s' is not a valid ref name", name)
+			}
+		}
+		if name == "" {
+			return fmt.Errorf("empty ref name")
+		}
+		return nil
+	}
+
+	if !strings.HasPrefix(name, "refs/") {
+		return fmt.Errorf("'%s' is not a valid ref name: it must be under refs/", name)
+	}
+	return CheckRefFormat(name, RefFormatOptions{})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -15,7 +16,7 @@ const (
 	HeadFile = ".orb/HEAD"       // special pointer to current location
 )
 
-// ReadRef reads the commit hash that a ref points to
+// ReadRef reads the commit hash that a ref points to, following symbolic refs
 func ReadRef(ref string) (string, error) {
 	var path string
 
@@ -29,39 +30,39 @@ func ReadRef(ref string) (string, error) {
 		path = filepath.Join(HeadsDir, ref)
 	}
 
-	return readRefFile(path)
-}
-
-// ReadHead reads the current HEAD reference
-func ReadHead() (string, error) {
-	content, err := os.ReadFile(HeadFile)
+	content, err := readRefFile(path)
 	if err != nil {
-		return "", fmt.Errorf("reading HEAD file: %w", err)
+		return "", err
 	}
+	return resolveRefContent(content)
+}
 
-	head := strings.TrimSpace(string(content))
-
+// ReadHead reads the current HEAD reference: the ref it points to,
+// or the commit hash when HEAD is detached
+func ReadHead() (string, error) {
 	// If HEAD points to a ref (normal case)
-	if strings.HasPrefix(head, "ref: ") {
-		return strings.TrimPrefix(head, "ref: "), nil
+	if target, err := ReadSymbolicRef("HEAD"); err == nil {
+		return target, nil
 	}
 
 	// Detached HEAD (points directly to a commit)
+	head, err := readRefFile(HeadFile)
+	if err != nil {
+		return "", fmt.Errorf("reading HEAD file: %w", err)
+	}
 	return head, nil
 }
 
 // GetCurrentBranch returns the name of the current branch
 func GetCurrentBranch() string {
-	content, err := os.ReadFile(HeadFile)
+	target, err := ReadSymbolicRef("HEAD")
 	if err != nil {
 		return ""
 	}
 
-	head := strings.TrimSpace(string(content))
-
 	// If HEAD points to a branch
-	if strings.HasPrefix(head, "ref: refs/heads/") {
-		return strings.TrimPrefix(head, "ref: refs/heads/")
+	if strings.HasPrefix(target, "refs/heads/") {
+		return strings.TrimPrefix(target, "refs/heads/")
 	}
 
 	// Detached HEAD state
@@ -70,111 +71,181 @@ func GetCurrentBranch() string {
 
 func GetRef(ref string) (string, error) {
 	// First check if this is a full ref path
-	var path string
+	name := ref
 
-	if ref == "HEAD" {
-		// Special case for HEAD - read the HEAD file directly first
-		headContent, err := os.ReadFile(HeadFile)
-		if err != nil {
-			return "", fmt.Errorf("reading HEAD file: %w", err)
-		}
-
-		head := strings.TrimSpace(string(headContent))
-		if strings.HasPrefix(head, "ref: ") {
-			refPath := strings.TrimPrefix(head, "ref: ")
-			return GetRef(refPath)
-		}
-		return head, nil
-	} else if strings.HasPrefix(ref, "refs/") {
-		path = filepath.Join(".orb", ref)
+	if ref == "HEAD" || strings.HasPrefix(ref, "refs/") {
+		name = ref
 	} else if strings.HasPrefix(ref, "heads/") || strings.HasPrefix(ref, "tags/") {
-		path = filepath.Join(RefsDir, ref)
+		name = "refs/" + ref
 	} else {
 		// Try as a branch name first
-		path = filepath.Join(HeadsDir, ref)
-		if _, err := os.Stat(path); os.IsNotExist(err) {
+		name = "refs/heads/" + ref
+		if _, err := os.Stat(filepath.Join(".orb", name)); os.IsNotExist(err) {
 			// If not a branch, try as a tag name
-			path = filepath.Join(TagsDir, ref)
+			name = "refs/tags/" + ref
 		}
 	}
 
-	return readRefFile(path)
+	_, hash, err := ResolveRef(name)
+	return hash, err
 }
 
-// changes where a reference points
-func UpdateRef(ref, hash string) error {
+// ExpandRef finds the full name of an existing ref from a short name,
+// trying branches first, then tags and remote-tracking refs
+func ExpandRef(ref string) (string, error) {
 	if ref == "HEAD" {
-		return fmt.Errorf("cannot update HEAD directly; use UpdateHead instead")
+		return ref, nil
 	}
 
-	var path string
+	// don't go looking for names that could never be refs, e.g. "../config"
+	if err := CheckRefFormat(ref, RefFormatOptions{AllowOneLevel: true}); err != nil {
+		return "", err
+	}
 
-	// For branches: writes the commit hash to a file in heads
-	if strings.HasPrefix(ref, "refs/") {
-		if !strings.HasPrefix(ref, ".orb/") { //.orb/ prefix
-			path = filepath.Join(".orb", ref)
-		} else {
-			path = ref
+	candidates := []string{
+		ref,
+		"refs/" + ref,
+		"refs/heads/" + ref,
+		"refs/tags/" + ref,
+		"refs/remotes/" + ref,
+		"refs/remotes/" + ref + "/HEAD",
+	}
+	for _, name := range candidates {
+		if !strings.HasPrefix(name, "refs/") {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(".orb", name)); err == nil && !info.IsDir() {
+			return name, nil
 		}
-		// For tags: writes the commit hash to a file in tags
-	} else if strings.HasPrefix(ref, "heads/") || strings.HasPrefix(ref, "tags/") {
-		path = filepath.Join(RefsDir, ref)
-	} else {
-		path = filepath.Join(HeadsDir, ref)
 	}
+	return "", fmt.Errorf("ref '%s' not found", ref)
+}
 
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("creating ref directory: %w", err)
-	}
+// ListRefs returns the full names of all refs under prefix (e.g. "refs/heads/"), sorted
+func ListRefs(prefix string) ([]string, error) {
+	root := filepath.Join(".orb", prefix)
+	var names []string
 
-	if err := os.WriteFile(path, []byte(hash+"\n"), 0644); err != nil {
-		return fmt.Errorf("writing ref file: %w", err)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		// lock files belong to a transaction in progress, not to a ref
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(".orb", path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing refs: %w", err)
 	}
-	return nil
+
+	sort.Strings(names)
+	return names, nil
 }
 
-func GetHead() (string, error) {
-	content, err := os.ReadFile(HeadFile)
+// RenameRef moves a ref to a new name, carrying its reflog along.
+// Unless force is set, the new name must not exist yet.
+func RenameRef(oldRef, newRef string, force bool, reason string) error {
+	oldName, newName := fullRefName(oldRef), fullRefName(newRef)
 
+	hash, err := ReadRef(oldName)
 	if err != nil {
-		return "", fmt.Errorf("reading HEAD file: %w", err)
+		return fmt.Errorf("ref '%s' not found", oldName)
 	}
 
-	head := strings.TrimSpace(string(content))
+	// keep the history before the transaction deletes it with the old ref
+	history, _ := os.ReadFile(reflogPath(oldName))
 
-	if strings.HasPrefix(head, "ref: ") {
-		refPath := strings.TrimPrefix(head, "ref: ")
-		return GetRef(refPath)
+	tx := NewTransaction()
+	tx.Delete(oldName, hash, reason)
+	if force {
+		tx.Update(newName, hash, "", reason)
+	} else {
+		tx.Create(newName, hash, reason)
+	}
+	if head, err := ReadHead(); err == nil && head == oldName {
+		tx.SetSymbolic("HEAD", newName, reason)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 
-	return head, nil
+	if len(history) > 0 {
+		entry, _ := os.ReadFile(reflogPath(newName))
+		if err := os.WriteFile(reflogPath(newName), append(history, entry...), 0644); err != nil {
+			return fmt.Errorf("moving reflog: %w", err)
+		}
+	}
+	return nil
 }
 
-func UpdateHead(target string) error {
+// changes where a reference points, recording the move in the reflog
+func UpdateRef(ref, hash, reason string) error {
+	if ref == "HEAD" {
+		return fmt.Errorf("cannot update HEAD directly; use UpdateHead instead")
+	}
+
+	tx := NewTransaction()
+	tx.Update(ref, hash, "", reason)
+	return tx.Commit()
+}
+
+func GetHead() (string, error) {
+	return GetRef("HEAD")
+}
+
+// UpdateHead points HEAD at a branch (by name or full ref) or, given a
+// commit hash, detaches it at that commit
+func UpdateHead(target, reason string) error {
+	tx := NewTransaction()
+
 	// If target has the format of a hash (40 hex characters),
 	// we're in detached HEAD mode
 	if len(target) == 40 && isValidHash(target) {
-		content := target + "\n"
-		if err := os.WriteFile(HeadFile, []byte(content), 0644); err != nil {
-			return fmt.Errorf("writing HEAD file: %w", err)
-		}
-		return nil
+		tx.Update("HEAD", target, "", reason)
+		return tx.Commit()
+	}
+
+	branchRef := target
+	if !strings.HasPrefix(branchRef, "refs/") {
+		branchRef = "refs/heads/" + target
 	}
 
 	// Check if target exists as a branch
-	branchPath := filepath.Join(HeadsDir, target)
-	if _, err := os.Stat(branchPath); err != nil && os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join(".orb", branchRef)); err != nil && os.IsNotExist(err) {
 		return fmt.Errorf("checking branch existence: %w", err)
 	}
 
 	// Set HEAD to point to the branch
-	content := fmt.Sprintf("ref: refs/heads/%s\n", target)
-	if err := os.WriteFile(HeadFile, []byte(content), 0644); err != nil {
-		return fmt.Errorf("writing HEAD file: %w", err)
-	}
+	tx.SetSymbolic("HEAD", branchRef, reason)
+	return tx.Commit()
+}
 
-	return nil
+// fullRefName expands a short ref name into its full "refs/..." form,
+// following the same rules ReadRef and UpdateRef use for locating ref files
+func fullRefName(ref string) string {
+	switch {
+	case ref == "HEAD":
+		return ref
+	case strings.HasPrefix(ref, ".orb/"):
+		return strings.TrimPrefix(ref, ".orb/")
+	case strings.HasPrefix(ref, "refs/"):
+		return ref
+	case strings.HasPrefix(ref, "heads/") || strings.HasPrefix(ref, "tags/"):
+		return "refs/" + ref
+	default:
+		return "refs/heads/" + ref
+	}
 }
 
 // check if a string is a valid hex hash
@@ -0,0 +1,102 @@
+package refs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymrefDepth limits how many symbolic refs are followed, like git's SYMREF_MAXDEPTH
+const maxSymrefDepth = 5
+
+// ReadSymbolicRef returns the ref that a symbolic ref such as HEAD points to.
+// It fails if the ref holds a hash instead.
+func ReadSymbolicRef(ref string) (string, error) {
+	name := fullRefName(ref)
+
+	content, err := readRefFile(filepath.Join(".orb", name))
+	if err != nil {
+		return "", fmt.Errorf("reading ref %s: %w", name, err)
+	}
+	if !strings.HasPrefix(content, "ref: ") {
+		return "", fmt.Errorf("ref %s is not a symbolic ref", name)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(content, "ref: ")), nil
+}
+
+// ResolveRef follows a chain of symbolic refs, returning the name of the
+// last ref in the chain and the hash it holds. The hash is empty (with an
+// error) when the chain ends in a ref that doesn't exist yet, e.g. HEAD
+// on a branch without commits.
+func ResolveRef(ref string) (string, string, error) {
+	name := fullRefName(ref)
+	seen := make(map[string]bool)
+
+	for depth := 0; ; depth++ {
+		if seen[name] {
+			return name, "", fmt.Errorf("symbolic ref loop at %s", name)
+		}
+		if depth > maxSymrefDepth {
+			return name, "", fmt.Errorf("symbolic ref %s nested too deeply", ref)
+		}
+		seen[name] = true
+
+		content, err := readRefFile(filepath.Join(".orb", name))
+		if err != nil {
+			return name, "", err
+		}
+
+		if !strings.HasPrefix(content, "ref: ") {
+			return name, content, nil
+		}
+		name = strings.TrimSpace(strings.TrimPrefix(content, "ref: "))
+	}
+}
+
+// WriteSymbolicRef points ref at target, refusing to create a loop
+func WriteSymbolicRef(ref, target, reason string) error {
+	name, target := fullRefName(ref), fullRefName(target)
+
+	// walk the target's chain to make sure it doesn't lead back to us
+	current := target
+	for depth := 0; depth <= maxSymrefDepth; depth++ {
+		if current == name {
+			return fmt.Errorf("refusing to point %s at %s: it would create a symbolic ref loop", name, target)
+		}
+		next, err := ReadSymbolicRef(current)
+		if err != nil {
+			break
+		}
+		current = next
+	}
+
+	tx := NewTransaction()
+	tx.SetSymbolic(name, target, reason)
+	return tx.Commit()
+}
+
+// DeleteSymbolicRef removes a symbolic ref (but not the ref it points to)
+func DeleteSymbolicRef(ref string) error {
+	name := fullRefName(ref)
+	if name == "HEAD" {
+		return fmt.Errorf("refusing to delete HEAD")
+	}
+	if _, err := ReadSymbolicRef(name); err != nil {
+		return err
+	}
+
+	tx := NewTransaction()
+	tx.Delete(name, "", "symbolic-ref: deleted")
+	return tx.Commit()
+}
+
+// ShortenRef turns refs/heads/main into main, refs/remotes/origin/main into
+// origin/main and refs/tags/v1 into v1
+func ShortenRef(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return strings.TrimPrefix(name, "refs/")
+}
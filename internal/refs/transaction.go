@@ -0,0 +1,367 @@
+package refs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// kinds of change a transaction can make to a ref
+const (
+	opUpdate   = "update"
+	opCreate   = "create"
+	opDelete   = "delete"
+	opVerify   = "verify"
+	opSymbolic = "symbolic"
+)
+
+// refUpdate is one queued change in a Transaction
+type refUpdate struct {
+	op      string
+	name    string // full ref name, e.g. refs/heads/main or HEAD
+	newHash string // new value for create/update
+	target  string // ref to point at for symbolic updates
+	oldHash string // expected current value; "" means don't check
+	reason  string
+
+	// rewriteLog replaces the ref's reflog with log instead of adding an
+	// entry for the change
+	rewriteLog bool
+	log        []string
+
+	// filled in while committing
+	path       string
+	lockPath   string
+	hadFile    bool
+	oldContent []byte
+	prevHash   string
+}
+
+// Transaction groups several ref changes so that either all of them
+// happen or none do. Every ref is locked with a "<ref>.lock" file first,
+// expected old values are checked, and only then are the new values
+// moved into place.
+type Transaction struct {
+	updates []*refUpdate
+}
+
+// NewTransaction starts an empty ref transaction
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// Create queues creating ref at newHash; it fails if the ref already exists
+func (tx *Transaction) Create(ref, newHash, reason string) {
+	tx.updates = append(tx.updates, &refUpdate{op: opCreate, name: fullRefName(ref), newHash: newHash, oldHash: ZeroHash, reason: reason})
+}
+
+// Update queues moving ref to newHash. When oldHash is not empty the update
+// only happens if the ref currently points there; ZeroHash means the ref
+// must not exist yet.
+func (tx *Transaction) Update(ref, newHash, oldHash, reason string) {
+	tx.updates = append(tx.updates, &refUpdate{op: opUpdate, name: fullRefName(ref), newHash: newHash, oldHash: oldHash, reason: reason})
+}
+
+// Delete queues removing ref, optionally only if it points at oldHash
+func (tx *Transaction) Delete(ref, oldHash, reason string) {
+	tx.updates = append(tx.updates, &refUpdate{op: opDelete, name: fullRefName(ref), oldHash: oldHash, reason: reason})
+}
+
+// Verify makes the transaction fail unless ref currently points at oldHash
+func (tx *Transaction) Verify(ref, oldHash string) {
+	tx.updates = append(tx.updates, &refUpdate{op: opVerify, name: fullRefName(ref), oldHash: oldHash})
+}
+
+// SetSymbolic queues pointing ref (usually HEAD) at another ref
+func (tx *Transaction) SetSymbolic(ref, target, reason string) {
+	tx.updates = append(tx.updates, &refUpdate{op: opSymbolic, name: fullRefName(ref), target: fullRefName(target), reason: reason})
+}
+
+// RewriteReflog queues replacing ref's reflog with lines, as long as the
+// ref still points at oldHash. The ref moves to newHash without a log
+// entry of its own; "" leaves it where it is and ZeroHash deletes it. The
+// log is written while the ref is locked, so it can't lose an entry to a
+// concurrent update.
+func (tx *Transaction) RewriteReflog(ref string, lines []string, newHash, oldHash string) {
+	u := &refUpdate{op: opUpdate, name: fullRefName(ref), newHash: newHash, oldHash: oldHash, rewriteLog: true, log: lines}
+	switch newHash {
+	case "":
+		u.op = opVerify
+	case ZeroHash:
+		u.op = opDelete
+	}
+	tx.updates = append(tx.updates, u)
+}
+
+// Commit applies every queued change, or none of them if any fails
+func (tx *Transaction) Commit() error {
+	if len(tx.updates) == 0 {
+		return nil
+	}
+
+	// a ref may only appear once, otherwise the outcome would depend on order
+	seen := make(map[string]bool)
+	for _, u := range tx.updates {
+		if seen[u.name] {
+			return fmt.Errorf("multiple updates for ref '%s' not allowed", u.name)
+		}
+		seen[u.name] = true
+
+		// never let a bad name (e.g. "../../config") escape .orb/refs
+		if err := checkWritableRef(u.name); err != nil {
+			return err
+		}
+		if u.op == opSymbolic {
+			if err := checkWritableRef(u.target); err != nil {
+				return err
+			}
+		}
+	}
+
+	// lock refs in a stable order
+	sort.SliceStable(tx.updates, func(i, j int) bool {
+		return tx.updates[i].name < tx.updates[j].name
+	})
+
+	var locked []*refUpdate
+	release := func() {
+		for _, u := range locked {
+			os.Remove(u.lockPath)
+		}
+	}
+
+	for _, u := range tx.updates {
+		if err := u.lock(); err != nil {
+			release()
+			return err
+		}
+		locked = append(locked, u)
+	}
+
+	// with everything locked, nobody else can move these refs under us
+	for _, u := range tx.updates {
+		if err := u.check(); err != nil {
+			release()
+			return err
+		}
+	}
+
+	// stage the new contents in the lock files
+	for _, u := range tx.updates {
+		if err := u.stage(); err != nil {
+			release()
+			return err
+		}
+	}
+
+	// move everything into place, undoing earlier steps if one fails
+	var done []*refUpdate
+	for _, u := range tx.updates {
+		if err := u.apply(); err != nil {
+			for _, d := range done {
+				d.rollback()
+			}
+			release()
+			return err
+		}
+		done = append(done, u)
+	}
+
+	// rewritten logs go in before the locks are given up
+	for _, u := range tx.updates {
+		if !u.rewriteLog {
+			continue
+		}
+		if err := u.writeLog(); err != nil {
+			for _, d := range done {
+				d.rollback()
+			}
+			release()
+			return err
+		}
+	}
+	release()
+
+	return tx.writeReflogs()
+}
+
+// writeLog replaces the ref's reflog with the queued lines, going through
+// a lock file of its own so that readers never see half a log; no lines
+// remove the log
+func (u *refUpdate) writeLog() error {
+	path := reflogPath(u.name)
+	if len(u.log) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing reflog: %w", err)
+		}
+		return nil
+	}
+
+	lockPath := path + ".lock"
+	if err := os.WriteFile(lockPath, []byte(strings.Join(u.log, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing reflog: %w", err)
+	}
+	if err := os.Rename(lockPath, path); err != nil {
+		os.Remove(lockPath)
+		return fmt.Errorf("writing reflog: %w", err)
+	}
+	return nil
+}
+
+// lock takes the ref's lock file, failing if someone else holds it
+func (u *refUpdate) lock() error {
+	u.path = filepath.Join(".orb", u.name)
+	u.lockPath = u.path + ".lock"
+
+	if err := os.MkdirAll(filepath.Dir(u.path), 0755); err != nil {
+		return fmt.Errorf("cannot lock ref '%s': %w", u.name, err)
+	}
+
+	file, err := os.OpenFile(u.lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("cannot lock ref '%s': '%s' exists; another orb process may be running", u.name, u.lockPath)
+		}
+		return fmt.Errorf("cannot lock ref '%s': %w", u.name, err)
+	}
+	return file.Close()
+}
+
+// check compares the ref's current value against what the caller expected
+func (u *refUpdate) check() error {
+	content, err := os.ReadFile(u.path)
+	switch {
+	case err == nil:
+		u.hadFile = true
+		u.oldContent = content
+	case os.IsNotExist(err):
+		u.hadFile = false
+	default:
+		return fmt.Errorf("reading ref '%s': %w", u.name, err)
+	}
+
+	// resolve through symbolic refs so reflogs and checks see commits
+	if u.hadFile {
+		u.prevHash, _ = resolveRefContent(strings.TrimSpace(string(content)))
+	}
+
+	if u.oldHash == "" {
+		if u.op == opDelete && !u.hadFile {
+			return fmt.Errorf("cannot delete ref '%s': it does not exist", u.name)
+		}
+		return nil
+	}
+
+	if u.oldHash == ZeroHash {
+		if u.hadFile {
+			return fmt.Errorf("cannot create ref '%s': it already exists", u.name)
+		}
+		return nil
+	}
+
+	if !u.hadFile {
+		return fmt.Errorf("cannot update ref '%s': expected %s but it does not exist", u.name, u.oldHash)
+	}
+	if u.prevHash != u.oldHash {
+		return fmt.Errorf("cannot update ref '%s': is at %s but expected %s", u.name, u.prevHash, u.oldHash)
+	}
+	return nil
+}
+
+// stage writes the new value into the lock file
+func (u *refUpdate) stage() error {
+	var content string
+	switch u.op {
+	case opCreate, opUpdate:
+		content = u.newHash + "\n"
+	case opSymbolic:
+		content = "ref: " + u.target + "\n"
+	default:
+		return nil
+	}
+
+	if err := os.WriteFile(u.lockPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing ref '%s': %w", u.name, err)
+	}
+	return nil
+}
+
+// apply moves the staged value into place
+func (u *refUpdate) apply() error {
+	switch u.op {
+	case opCreate, opUpdate, opSymbolic:
+		if err := os.Rename(u.lockPath, u.path); err != nil {
+			return fmt.Errorf("updating ref '%s': %w", u.name, err)
+		}
+	case opDelete:
+		if err := os.Remove(u.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("deleting ref '%s': %w", u.name, err)
+		}
+	}
+	return nil
+}
+
+// rollback puts back the value the ref had before apply
+func (u *refUpdate) rollback() {
+	if u.op == opVerify {
+		return
+	}
+	if u.hadFile {
+		os.WriteFile(u.path, u.oldContent, 0644)
+	} else {
+		os.Remove(u.path)
+	}
+}
+
+// writeReflogs records every applied change, including HEAD moving with its branch
+func (tx *Transaction) writeReflogs() error {
+	head, _ := ReadHead()
+
+	// if HEAD itself is part of the transaction it gets its own entry
+	headUpdated := false
+	for _, u := range tx.updates {
+		if u.name == "HEAD" && u.op != opVerify {
+			headUpdated = true
+		}
+	}
+
+	for _, u := range tx.updates {
+		if u.rewriteLog {
+			continue
+		}
+
+		var newHash string
+		switch u.op {
+		case opVerify:
+			continue
+		case opDelete:
+			// like git, a deleted ref takes its log with it
+			os.Remove(reflogPath(u.name))
+			continue
+		case opSymbolic:
+			newHash, _ = GetRef(u.target)
+		default:
+			newHash = u.newHash
+		}
+
+		if err := AppendReflog(u.name, u.prevHash, newHash, u.reason); err != nil {
+			return err
+		}
+		if !headUpdated && u.name != "HEAD" && u.name == head {
+			if err := AppendReflog("HEAD", u.prevHash, newHash, u.reason); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveRefContent follows the content of a ref file to a commit hash
+func resolveRefContent(content string) (string, error) {
+	if strings.HasPrefix(content, "ref: ") {
+		return GetRef(strings.TrimPrefix(content, "ref: "))
+	}
+	return content, nil
+}
@@ -0,0 +1,132 @@
+package revision
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// BisectStep is where a bisection stands after the commits marked so far
+type BisectStep struct {
+	// Next is the commit to test next, or "" when there is nothing to test
+	Next string
+
+	// Remaining is how many commits may still need testing after Next,
+	// and Steps roughly how many tests that takes
+	Remaining int
+	Steps     int
+
+	// FirstBad is set once the first bad commit is known
+	FirstBad string
+
+	// Skipped lists the commits the first bad one could be when only
+	// skipped commits are left to test
+	Skipped []string
+}
+
+// Bisect picks the next commit to test when bad is known to be bad and
+// every commit reachable from good is known to be good. The candidates are
+// the commits reachable from bad and not from good. For each candidate it
+// counts the candidates it can reach, and picks the one whose answer cuts
+// the candidates closest to half either way; that works for merges as
+// well as for a straight line. Skipped commits are never picked.
+func Bisect(bad string, good, skip []string) (*BisectStep, error) {
+	goodReach, err := Reachable(good...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the candidates and their parents among the candidates
+	parents := make(map[string][]string)
+	queue := []string{bad}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if _, seen := parents[hash]; seen || goodReach[hash] {
+			continue
+		}
+		commitParents, err := Parents(hash)
+		if err != nil {
+			return nil, err
+		}
+		parents[hash] = nil
+		for _, parent := range commitParents {
+			if !goodReach[parent] {
+				parents[hash] = append(parents[hash], parent)
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	all := len(parents)
+	if all <= 1 {
+		return &BisectStep{FirstBad: bad}, nil
+	}
+
+	skipped := make(map[string]bool)
+	for _, hash := range skip {
+		skipped[hash] = true
+	}
+
+	var testable []string
+	for hash := range parents {
+		if hash != bad && !skipped[hash] {
+			testable = append(testable, hash)
+		}
+	}
+	if len(testable) == 0 {
+		var left []string
+		for hash := range parents {
+			left = append(left, hash)
+		}
+		sort.Strings(left)
+		return &BisectStep{Skipped: left}, nil
+	}
+	sort.Strings(testable)
+
+	best, bestScore, bestReach := "", -1, 0
+	for _, hash := range testable {
+		reach := countReachable(hash, parents)
+		score := min(reach, all-reach)
+		if score > bestScore {
+			best, bestScore, bestReach = hash, score, reach
+		}
+	}
+
+	return &BisectStep{
+		Next:      best,
+		Remaining: all - bestReach - 1,
+		Steps:     estimateBisectSteps(all),
+	}, nil
+}
+
+// countReachable counts the candidates reachable from hash, itself included
+func countReachable(hash string, parents map[string][]string) int {
+	seen := map[string]bool{hash: true}
+	queue := []string{hash}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, parent := range parents[current] {
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return len(seen)
+}
+
+// estimateBisectSteps guesses how many more tests it takes to get through
+// all candidates, the way git does: about log2(all), minus one when all is
+// close to a power of two from above
+func estimateBisectSteps(all int) int {
+	if all < 3 {
+		return 0
+	}
+	n := bits.Len(uint(all)) - 1
+	e := 1 << n
+	if e < 3*(all-e) {
+		return n
+	}
+	return n - 1
+}
@@ -0,0 +1,97 @@
+package revision
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// absolute date layouts accepted in @{<date>} and date filters
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon Jan 2 15:04:05 2006 -0700", // the format git prints dates in
+	time.UnixDate,
+	time.ANSIC,
+}
+
+// units understood by relative dates such as "3 days ago"
+var dateUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// ParseDate understands the date forms people type on the command line:
+// absolute dates ("2024-05-01", RFC 2822, ...), "@<unix>", "now", "today",
+// "yesterday" and relative dates like "2 weeks ago" or "2.weeks.ago"
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	now := time.Now()
+
+	switch strings.ToLower(s) {
+	case "now":
+		return now, nil
+	case "today":
+		y, m, d := now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+
+	if strings.HasPrefix(s, "@") {
+		unix, err := strconv.ParseInt(s[1:], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date '%s'", s)
+		}
+		return time.Unix(unix, 0), nil
+	}
+
+	if t, ok := parseRelativeDate(s, now); ok {
+		return t, nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date '%s'", s)
+}
+
+// parseRelativeDate handles "<n> <unit>[s] ago" with spaces or dots between words
+func parseRelativeDate(s string, now time.Time) (time.Time, bool) {
+	fields := strings.Fields(strings.ReplaceAll(strings.ToLower(s), ".", " "))
+	if len(fields) != 3 || fields[2] != "ago" {
+		return time.Time{}, false
+	}
+
+	n, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return time.Time{}, false
+	}
+	unit := strings.TrimSuffix(fields[1], "s")
+
+	switch unit {
+	case "month":
+		return now.AddDate(0, -n, 0), true
+	case "year":
+		return now.AddDate(-n, 0, 0), true
+	}
+
+	d, ok := dateUnits[unit]
+	if !ok {
+		return time.Time{}, false
+	}
+	return now.Add(-time.Duration(n) * d), true
+}
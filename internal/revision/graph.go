@@ -0,0 +1,121 @@
+package revision
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// Parents returns the parent hashes of a commit, in order
+func Parents(hash string) ([]string, error) {
+	commit, err := objects.ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Parents, nil
+}
+
+// Reachable returns every commit reachable from the given commits, including themselves
+func Reachable(starts ...string) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	queue := append([]string{}, starts...)
+
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if hash == "" || seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		parents, err := Parents(hash)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, parents...)
+	}
+	return seen, nil
+}
+
+// IsAncestor reports whether ancestor can be reached from descendant
+func IsAncestor(ancestor, descendant string) (bool, error) {
+	if ancestor == descendant {
+		return true, nil
+	}
+	reachable, err := Reachable(descendant)
+	if err != nil {
+		return false, err
+	}
+	return reachable[ancestor], nil
+}
+
+// AheadBehind counts the commits only reachable from local (ahead)
+// and only reachable from upstream (behind)
+func AheadBehind(local, upstream string) (int, int, error) {
+	fromLocal, err := Reachable(local)
+	if err != nil {
+		return 0, 0, err
+	}
+	fromUpstream, err := Reachable(upstream)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ahead, behind := 0, 0
+	for hash := range fromLocal {
+		if !fromUpstream[hash] {
+			ahead++
+		}
+	}
+	for hash := range fromUpstream {
+		if !fromLocal[hash] {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// MergeBases returns the best common ancestors of two commits: the common
+// ancestors that are not themselves ancestors of another common ancestor
+func MergeBases(a, b string) ([]string, error) {
+	fromA, err := Reachable(a)
+	if err != nil {
+		return nil, err
+	}
+	fromB, err := Reachable(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var common []string
+	for hash := range fromA {
+		if fromB[hash] {
+			common = append(common, hash)
+		}
+	}
+
+	// anything reachable from the parents of a common ancestor is an older
+	// common ancestor, so it isn't a best one
+	var parents []string
+	for _, hash := range common {
+		p, err := Parents(hash)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, p...)
+	}
+	older, err := Reachable(parents...)
+	if err != nil {
+		return nil, fmt.Errorf("finding merge bases: %w", err)
+	}
+
+	var bases []string
+	for _, hash := range common {
+		if !older[hash] {
+			bases = append(bases, hash)
+		}
+	}
+	sort.Strings(bases)
+	return bases, nil
+}
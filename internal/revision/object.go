@@ -0,0 +1,72 @@
+package revision
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// ResolveObject resolves anything that names an object: a revision,
+// "<rev>:<path>" for a file or directory in a commit's tree, or ":<path>"
+// for a file staged in the index
+func ResolveObject(spec string) (string, error) {
+	rev, path, ok := strings.Cut(spec, ":")
+	if !ok {
+		return Resolve(spec)
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	if rev == "" {
+		idx, err := index.LoadIndex()
+		if err != nil {
+			return "", fmt.Errorf("loading index: %w", err)
+		}
+		entry, ok := idx.Entries[path]
+		if !ok {
+			return "", fmt.Errorf("path '%s' is not in the index", path)
+		}
+		return entry.ObjectHash, nil
+	}
+
+	hash, err := Resolve(rev)
+	if err != nil {
+		return "", err
+	}
+	tree, err := PeelToTree(hash)
+	if err != nil {
+		return "", err
+	}
+
+	entry, found, err := objects.LookupPath(tree, path)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("path '%s' does not exist in '%s'", path, rev)
+	}
+	return entry.Hash, nil
+}
+
+// PeelToTree returns the tree of a commit, following annotated tags first;
+// a tree is returned as it is
+func PeelToTree(hash string) (string, error) {
+	peeled, objType, err := objects.Peel(hash)
+	if err != nil {
+		return "", err
+	}
+
+	switch objType {
+	case objects.TreeType:
+		return peeled, nil
+	case objects.CommitType:
+		commit, err := objects.ReadCommit(peeled)
+		if err != nil {
+			return "", err
+		}
+		return commit.Tree, nil
+	}
+	return "", fmt.Errorf("object %s is a %s, not a tree", hash, objType)
+}
@@ -0,0 +1,220 @@
+package revision
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayushsarode/orb/internal/objects"
+	"github.com/ayushsarode/orb/internal/refs"
+)
+
+// Resolve turns a revision like "main", "HEAD~2", "a1b2c3d^2" or "main@{2}"
+// into the full hash of the object it names
+func Resolve(rev string) (string, error) {
+	rev = strings.TrimSpace(rev)
+	if rev == "" {
+		return "", fmt.Errorf("empty revision")
+	}
+
+	// split off ancestry suffixes like ~2 or ^2 and walk them after resolving the base
+	base, suffix := splitAncestry(rev)
+	hash, err := resolveBase(base)
+	if err != nil {
+		return "", err
+	}
+	return walkAncestry(hash, suffix, rev)
+}
+
+// splitAncestry splits "main~2^2" into "main" and "~2^2"; anything inside
+// @{...} belongs to the base
+func splitAncestry(rev string) (string, string) {
+	start := 0
+	if i := strings.LastIndex(rev, "}"); i >= 0 {
+		start = i + 1
+	}
+	if i := strings.IndexAny(rev[start:], "~^"); i >= 0 {
+		return rev[:start+i], rev[start+i:]
+	}
+	return rev, ""
+}
+
+// walkAncestry follows "~<n>" (n first parents back) and "^<n>" (the n-th parent)
+func walkAncestry(hash, suffix, rev string) (string, error) {
+	// "v1.0~1" walks from the commit the tag names
+	if suffix != "" {
+		var err error
+		if hash, err = PeelToCommit(hash); err != nil {
+			return "", err
+		}
+	}
+
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+
+		digits := 0
+		for digits < len(suffix) && suffix[digits] >= '0' && suffix[digits] <= '9' {
+			digits++
+		}
+		n := 1
+		if digits > 0 {
+			n, _ = strconv.Atoi(suffix[:digits])
+		}
+		suffix = suffix[digits:]
+
+		if op == '~' {
+			for ; n > 0; n-- {
+				parents, err := Parents(hash)
+				if err != nil {
+					return "", err
+				}
+				if len(parents) == 0 {
+					return "", fmt.Errorf("revision '%s' goes past the root commit", rev)
+				}
+				hash = parents[0]
+			}
+			continue
+		}
+
+		// ^0 is the commit itself
+		if n == 0 {
+			continue
+		}
+		parents, err := Parents(hash)
+		if err != nil {
+			return "", err
+		}
+		if n > len(parents) {
+			return "", fmt.Errorf("revision '%s' names a parent that does not exist", rev)
+		}
+		hash = parents[n-1]
+	}
+	return hash, nil
+}
+
+// resolveBase resolves a revision without ancestry suffixes
+func resolveBase(rev string) (string, error) {
+	// "@" on its own is a shortcut for HEAD
+	if rev == "@" {
+		rev = "HEAD"
+	}
+
+	// <ref>@{<n>} and <ref>@{<date>} look up the ref's reflog
+	if i := strings.Index(rev, "@{"); i >= 0 && strings.HasSuffix(rev, "}") {
+		return resolveReflog(rev[:i], rev[i+2:len(rev)-1])
+	}
+
+	return resolveName(rev)
+}
+
+// resolveName looks a name up as a ref first, then as a (possibly abbreviated) hash
+func resolveName(name string) (string, error) {
+	if name == "HEAD" {
+		hash, err := refs.GetHead()
+		if err != nil || hash == "" {
+			return "", fmt.Errorf("HEAD does not point to a commit yet")
+		}
+		return hash, nil
+	}
+
+	// ORIG_HEAD and friends live next to HEAD
+	if refs.IsPseudoRef(name) {
+		return refs.ReadPseudoRef(name)
+	}
+
+	if full, err := refs.ExpandRef(name); err == nil {
+		hash, err := refs.GetRef(full)
+		if err != nil {
+			return "", fmt.Errorf("reading ref %s: %w", full, err)
+		}
+		return hash, nil
+	}
+
+	if isHex(name) {
+		return objects.ResolvePrefix(name)
+	}
+
+	return "", fmt.Errorf("unknown revision '%s'", name)
+}
+
+// resolveReflog handles the part inside @{...}: either how many moves back
+// to go, or a date to find the ref's value at
+func resolveReflog(refName, selector string) (string, error) {
+	name, err := reflogRef(refName)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := refs.ReadReflog(name)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("log for '%s' is empty", name)
+	}
+
+	// @{n}: the value the ref had n moves ago
+	if n, err := strconv.Atoi(selector); err == nil {
+		if n < 0 {
+			return "", fmt.Errorf("invalid reflog selector '@{%s}'", selector)
+		}
+		if n >= len(entries) {
+			return "", fmt.Errorf("log for '%s' only has %d entries", name, len(entries))
+		}
+		return entries[len(entries)-1-n].NewHash, nil
+	}
+
+	// @{<date>}: the value the ref had at that point in time
+	when, err := ParseDate(selector)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].When.After(when) {
+			return entries[i].NewHash, nil
+		}
+	}
+
+	// the date is older than the log itself, so the best answer is where it started
+	if oldest := entries[0].OldHash; oldest != refs.ZeroHash {
+		return oldest, nil
+	}
+	return "", fmt.Errorf("log for '%s' only goes back to %s", name, entries[0].When.Format(time.RFC1123Z))
+}
+
+// reflogRef works out which reflog a selector refers to; a bare "@{n}"
+// means the current branch, as in git
+func reflogRef(refName string) (string, error) {
+	switch refName {
+	case "":
+		head, err := refs.ReadHead()
+		if err == nil && strings.HasPrefix(head, "refs/") {
+			return head, nil
+		}
+		return "HEAD", nil
+	case "HEAD", "@":
+		return "HEAD", nil
+	}
+
+	name, err := refs.ExpandRef(refName)
+	if err != nil {
+		return "", fmt.Errorf("unknown revision '%s'", refName)
+	}
+	return name, nil
+}
+
+// isHex reports whether s looks like a (possibly abbreviated) object hash
+func isHex(s string) bool {
+	if len(s) < 4 || len(s) > 40 {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')) {
+			return false
+		}
+	}
+	return true
+}
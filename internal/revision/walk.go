@@ -0,0 +1,327 @@
+package revision
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// WalkCommit is a commit produced by a Walker
+type WalkCommit struct {
+	Hash string
+	*objects.Commit
+
+	// Parents are the parents the walk went on to, which differ from the
+	// commit's own parents when history is limited to paths or ranges
+	Parents []string
+}
+
+// Walker lists commits newest first by committer date, the way git log
+// does, following every parent so branches interleave by time
+type Walker struct {
+	// Paths limits the walk to commits that change one of these paths
+	Paths []string
+
+	// Missing collects parents that were not found locally, e.g. after a
+	// partial clone; the walk carries on without them
+	Missing []string
+
+	queue   commitQueue
+	queued  map[string]bool
+	hide    []string
+	hidden  map[string]bool
+	started bool
+	seq     int
+}
+
+// NewWalker returns a walker with nothing to walk yet
+func NewWalker() *Walker {
+	return &Walker{queued: make(map[string]bool)}
+}
+
+// Push starts the walk from a commit, or the commit an annotated tag names
+func (w *Walker) Push(hash string) error {
+	commit, err := PeelToCommit(hash)
+	if err != nil {
+		return err
+	}
+	return w.push(commit)
+}
+
+// Hide leaves out a commit and everything reachable from it
+func (w *Walker) Hide(hash string) error {
+	commit, err := PeelToCommit(hash)
+	if err != nil {
+		return err
+	}
+	w.hide = append(w.hide, commit)
+	return nil
+}
+
+// PeelToCommit follows annotated tags to a commit
+func PeelToCommit(hash string) (string, error) {
+	peeled, objType, err := objects.Peel(hash)
+	if err != nil {
+		return "", err
+	}
+	if objType != objects.CommitType {
+		return "", fmt.Errorf("object %s is a %s, not a commit", hash, objType)
+	}
+	return peeled, nil
+}
+
+// AddRevision adds a revision argument as git log understands it:
+// "A..B" is B without A, "A...B" is what either has but not both, "^X"
+// hides X and anything else is a starting point. An empty side of a
+// range means HEAD.
+func (w *Walker) AddRevision(arg string) error {
+	if left, right, ok := strings.Cut(arg, "..."); ok {
+		a, b, err := resolvePair(left, right)
+		if err != nil {
+			return err
+		}
+		bases, err := MergeBases(a, b)
+		if err != nil {
+			return err
+		}
+		for _, base := range bases {
+			if err := w.Hide(base); err != nil {
+				return err
+			}
+		}
+		if err := w.Push(a); err != nil {
+			return err
+		}
+		return w.Push(b)
+	}
+
+	if left, right, ok := strings.Cut(arg, ".."); ok {
+		a, b, err := resolvePair(left, right)
+		if err != nil {
+			return err
+		}
+		if err := w.Hide(a); err != nil {
+			return err
+		}
+		return w.Push(b)
+	}
+
+	if name, ok := strings.CutPrefix(arg, "^"); ok {
+		hash, err := Resolve(name)
+		if err != nil {
+			return err
+		}
+		return w.Hide(hash)
+	}
+
+	hash, err := Resolve(arg)
+	if err != nil {
+		return err
+	}
+	return w.Push(hash)
+}
+
+// resolvePair resolves both ends of a range to commits, defaulting either to HEAD
+func resolvePair(left, right string) (string, string, error) {
+	if left == "" {
+		left = "HEAD"
+	}
+	if right == "" {
+		right = "HEAD"
+	}
+
+	var hashes [2]string
+	for i, rev := range []string{left, right} {
+		hash, err := Resolve(rev)
+		if err != nil {
+			return "", "", err
+		}
+		if hashes[i], err = PeelToCommit(hash); err != nil {
+			return "", "", err
+		}
+	}
+	return hashes[0], hashes[1], nil
+}
+
+// Next returns the next commit of the walk, or nil once it is over
+func (w *Walker) Next() (*WalkCommit, error) {
+	if !w.started {
+		w.started = true
+		hidden, err := w.reachableLocally(w.hide)
+		if err != nil {
+			return nil, err
+		}
+		w.hidden = hidden
+	}
+
+	for w.queue.Len() > 0 {
+		item := heap.Pop(&w.queue).(*queueItem)
+		if w.hidden[item.hash] {
+			continue
+		}
+
+		parents := w.presentParents(item.commit)
+		show := true
+		if len(w.Paths) > 0 {
+			var err error
+			if show, parents, err = w.simplify(item.commit, parents); err != nil {
+				return nil, err
+			}
+		}
+
+		var followed []string
+		for _, parent := range parents {
+			if w.hidden[parent] {
+				continue
+			}
+			followed = append(followed, parent)
+			if err := w.push(parent); err != nil {
+				return nil, err
+			}
+		}
+
+		if show {
+			return &WalkCommit{Hash: item.hash, Commit: item.commit, Parents: followed}, nil
+		}
+	}
+	return nil, nil
+}
+
+// push queues a commit once, ordered by its committer date
+func (w *Walker) push(hash string) error {
+	if w.queued[hash] {
+		return nil
+	}
+	w.queued[hash] = true
+
+	commit, err := objects.ReadCommit(hash)
+	if err != nil {
+		return err
+	}
+
+	w.seq++
+	heap.Push(&w.queue, &queueItem{hash: hash, commit: commit, seq: w.seq})
+	return nil
+}
+
+// presentParents drops parents that are not stored locally, noting them
+func (w *Walker) presentParents(commit *objects.Commit) []string {
+	var parents []string
+	for _, parent := range commit.Parents {
+		if !objects.Exists(parent) {
+			w.Missing = append(w.Missing, parent)
+			continue
+		}
+		parents = append(parents, parent)
+	}
+	return parents
+}
+
+// reachableLocally is Reachable, except that missing parents end the walk
+// instead of failing it
+func (w *Walker) reachableLocally(starts []string) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	queue := append([]string{}, starts...)
+
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		commit, err := objects.ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, w.presentParents(commit)...)
+	}
+	return seen, nil
+}
+
+// simplify decides whether a commit touches the walked paths and which
+// parents to follow. Like git's default history simplification, a commit
+// that matches one of its parents at those paths is left out, and only
+// that parent is followed.
+func (w *Walker) simplify(commit *objects.Commit, parents []string) (bool, []string, error) {
+	if len(parents) == 0 {
+		same, err := w.sameAtPaths(commit.Tree, "")
+		return !same, nil, err
+	}
+
+	for _, parent := range parents {
+		parentCommit, err := objects.ReadCommit(parent)
+		if err != nil {
+			return false, nil, err
+		}
+		same, err := w.sameAtPaths(commit.Tree, parentCommit.Tree)
+		if err != nil {
+			return false, nil, err
+		}
+		if same {
+			return false, []string{parent}, nil
+		}
+	}
+	return true, parents, nil
+}
+
+// sameAtPaths reports whether two trees have the same content at every
+// walked path; "" is the empty tree
+func (w *Walker) sameAtPaths(treeA, treeB string) (bool, error) {
+	for _, path := range w.Paths {
+		a, inA, err := lookupInTree(treeA, path)
+		if err != nil {
+			return false, err
+		}
+		b, inB, err := lookupInTree(treeB, path)
+		if err != nil {
+			return false, err
+		}
+		if inA != inB || a.Hash != b.Hash {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lookupInTree is objects.LookupPath that treats "" as the empty tree
+func lookupInTree(tree, path string) (objects.TreeEntry, bool, error) {
+	if tree == "" {
+		return objects.TreeEntry{}, false, nil
+	}
+	return objects.LookupPath(tree, path)
+}
+
+// queueItem is a commit waiting in the walker's priority queue
+type queueItem struct {
+	hash   string
+	commit *objects.Commit
+	seq    int // commits with the same date come out in the order they went in
+}
+
+// commitQueue is a max-heap on committer date
+type commitQueue []*queueItem
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	ti, tj := q[i].commit.Committer.When, q[j].commit.Committer.When
+	if !ti.Equal(tj) {
+		return ti.After(tj)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x any) { *q = append(*q, x.(*queueItem)) }
+
+func (q *commitQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
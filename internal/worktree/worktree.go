@@ -0,0 +1,244 @@
+// Package worktree moves the index and the working files from one tree to
+// another: what checkout, reset and friends do to the files on disk.
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ayushsarode/orb/internal/index"
+	"github.com/ayushsarode/orb/internal/objects"
+)
+
+// Files flattens a tree into a map from file path to blob hash. The empty
+// string stands for an empty tree.
+func Files(tree string) (map[string]string, error) {
+	files := make(map[string]string)
+	if tree == "" {
+		return files, nil
+	}
+	if err := collectFiles(tree, "", files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// collectFiles adds the files below a tree to files, prefixing their paths
+func collectFiles(tree, prefix string, files map[string]string) error {
+	entries, err := objects.ReadTree(tree)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsTree() {
+			if err := collectFiles(entry.Hash, prefix+entry.Name+"/", files); err != nil {
+				return err
+			}
+			continue
+		}
+		files[prefix+entry.Name] = entry.Hash
+	}
+	return nil
+}
+
+// IndexFiles returns the staged files as a map from path to blob hash
+func IndexFiles(idx *index.Index) map[string]string {
+	files := make(map[string]string)
+	for path, entry := range idx.Entries {
+		files[path] = entry.ObjectHash
+	}
+	return files
+}
+
+// HashFile returns the blob hash of a working file without storing it, or
+// "" when the file doesn't exist
+func HashFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	return objects.HashObject(objects.BlobType, content), nil
+}
+
+// WriteFile replaces a working file with the content of a blob, creating
+// its directories as needed
+func WriteFile(path, hash string) error {
+	objType, content, err := objects.ReadObject(hash)
+	if err != nil {
+		return fmt.Errorf("reading blob for %s: %w", path, err)
+	}
+	if objType != objects.BlobType {
+		return fmt.Errorf("object %s for %s is a %s, not a blob", hash, path, objType)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating directory for %s: %w", path, err)
+		}
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// RemoveFile deletes a working file and any directories it leaves empty
+func RemoveFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", path, err)
+	}
+	for dir := filepath.Dir(path); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		// Remove fails on a directory that still has files, which ends the climb
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// ResetIndex makes the index hold exactly the given files, leaving the
+// working tree alone. Unmerged paths are forgotten.
+func ResetIndex(idx *index.Index, files map[string]string) {
+	idx.ClearConflicts()
+	for path := range idx.Entries {
+		if _, ok := files[path]; !ok {
+			idx.Remove(path)
+		}
+	}
+	for path, hash := range files {
+		if entry, ok := idx.Entries[path]; !ok || entry.ObjectHash != hash {
+			idx.SetEntry(path, hash)
+		}
+	}
+}
+
+// LocalChangesError lists the files whose uncommitted changes a checkout
+// would throw away
+type LocalChangesError struct {
+	Paths     []string
+	Untracked bool // the files aren't tracked at all
+	Merge     bool // a merge is in the way rather than a checkout
+}
+
+func (e *LocalChangesError) Error() string {
+	op, action := "checkout", "switch branches"
+	if e.Merge {
+		op, action = "merge", "merge"
+	}
+
+	what := "Your local changes to the following files"
+	hint := "Please commit your changes or stash them before you " + action + "."
+	if e.Untracked {
+		what = "The following untracked working tree files"
+		hint = "Please move or remove them before you " + action + "."
+	}
+	return fmt.Sprintf("%s would be overwritten by %s:\n\t%s\n%s", what, op, strings.Join(e.Paths, "\n\t"), hint)
+}
+
+// Checkout moves the index and working tree from the files of one tree
+// (usually HEAD's) to those of another. Paths the two trees agree on keep
+// whatever is staged or changed in them. Unless force is set, a path that
+// has to change must not carry local changes, and nothing is touched if
+// one does. With force, the index and tracked files simply end up
+// matching target.
+func Checkout(idx *index.Index, from, target map[string]string, force bool) error {
+	if force {
+		idx.ClearConflicts()
+	} else if idx.HasConflicts() {
+		return fmt.Errorf("you need to resolve your current index first:\n\t%s", strings.Join(idx.ConflictPaths(), "\n\t"))
+	}
+	staged := IndexFiles(idx)
+
+	paths := make(map[string]bool)
+	for _, files := range []map[string]string{from, target, staged} {
+		for path := range files {
+			paths[path] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	// first decide what changes, so that a refusal leaves everything as it was
+	type update struct {
+		path string
+		hash string // "" removes the file
+	}
+	var updates []update
+	var dirty, untracked []string
+
+	for _, path := range sorted {
+		oldHash, newHash, stagedHash := from[path], target[path], staged[path]
+
+		if force {
+			working, err := HashFile(path)
+			if err != nil {
+				return err
+			}
+			if working != newHash || stagedHash != newHash {
+				updates = append(updates, update{path, newHash})
+			}
+			continue
+		}
+
+		// a path both trees agree on keeps its local state
+		if oldHash == newHash {
+			continue
+		}
+
+		working, err := HashFile(path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case stagedHash == "" && oldHash == "" && working != "" && working != newHash:
+			untracked = append(untracked, path)
+		case stagedHash != oldHash && stagedHash != newHash:
+			dirty = append(dirty, path)
+		case working != stagedHash && working != newHash:
+			dirty = append(dirty, path)
+		default:
+			updates = append(updates, update{path, newHash})
+		}
+	}
+
+	if len(untracked) > 0 {
+		return &LocalChangesError{Paths: untracked, Untracked: true}
+	}
+	if len(dirty) > 0 {
+		return &LocalChangesError{Paths: dirty}
+	}
+
+	for _, u := range updates {
+		if u.hash == "" {
+			// with force, files that were never tracked stay where they are
+			if from[u.path] == "" && staged[u.path] == "" {
+				continue
+			}
+			if err := RemoveFile(u.path); err != nil {
+				return err
+			}
+			idx.Remove(u.path)
+			continue
+		}
+
+		if working, err := HashFile(u.path); err != nil {
+			return err
+		} else if working != u.hash {
+			if err := WriteFile(u.path, u.hash); err != nil {
+				return err
+			}
+		}
+		idx.SetEntry(u.path, u.hash)
+	}
+	return nil
+}